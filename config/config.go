@@ -7,11 +7,17 @@ import (
 	"io/ioutil"
 	"os"
 	"sync"
+	"time"
 )
 
 // MissyConfigFile holds the default config file name
 const MissyConfigFile = ".missy.yml"
 
+// DefaultShutdownGracePeriod is the time readers get on SIGTERM to finish and commit the messages being processed,
+// below the 30 seconds Kubernetes waits before it kills a pod. It is used by service.Service and
+// messaging.ShutdownOnSignal.
+const DefaultShutdownGracePeriod = 25 * time.Second
+
 var config *Config
 var once sync.Once
 
@@ -105,3 +111,14 @@ func (c *Config) Get(internalName string) string {
 	log.Warnf("You're trying to get unknown config value: %s", internalName)
 	return ""
 }
+
+// Lookup returns the value of a config parameter by internal name and whether the parameter is configured. Unlike Get
+// it does not warn about unknown parameters, so it can be used for optional values.
+func (c *Config) Lookup(internalName string) (string, bool) {
+	for _, ep := range c.Environment {
+		if ep.InternalName == internalName {
+			return ep.Value, true
+		}
+	}
+	return "", false
+}
@@ -91,3 +91,17 @@ func TestParseEnvironment(t *testing.T) {
 		t.Error("ENVVAR_B was not set to default valule")
 	}
 }
+
+func TestLookup(t *testing.T) {
+	c := &Config{Environment: []EnvParameter{{EnvName: "ENVVAR_A", InternalName: "var.a", Value: "a"}}}
+
+	if v, ok := c.Lookup("var.a"); !ok || v != "a" {
+		t.Errorf("expected var.a to be a, got %q %v", v, ok)
+	}
+	if _, ok := c.Lookup("ENVVAR_A"); ok {
+		t.Error("parameters should only be found by their internal name")
+	}
+	if _, ok := c.Lookup("var.unknown"); ok {
+		t.Error("unknown parameters should not be found")
+	}
+}
@@ -1,7 +1,9 @@
 package log
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	l "github.com/sirupsen/logrus"
 )
@@ -10,18 +12,8 @@ import (
 func init() {
 	// set log format
 	format := os.Getenv("LOG_FORMAT")
-	switch format {
-	case "json":
-		l.SetFormatter(&l.JSONFormatter{})
-		break
-	case "text":
-		l.SetFormatter(&l.TextFormatter{})
-		break
-	case "":
-		// nothing
-		break
-	default:
-		Fatalf("Unknown log format \"%s\"", format)
+	if err := SetFormat(format); err != nil {
+		Fatalf("Unknown log format \"%s\", allowed formats: text, json", format)
 	}
 
 	// setting level, default debug
@@ -29,15 +21,55 @@ func init() {
 	if loglevel == "" {
 		loglevel = "debug"
 	}
-	level, error := l.ParseLevel(loglevel)
-	if error != nil {
+	if err := SetLevel(loglevel); err != nil {
 		Fatalf("Unknown log level %s, allowed levels: debug, info, warn, error, fatal, panic", loglevel)
 	}
-	l.SetLevel(level)
-	l.Debugf("Setting log level to %s", level.String())
+	l.Debugf("Setting log level to %s", GetLevel())
+
+}
 
+// SetLevel sets the level of the standard logger, one of debug, info, warn, error, fatal or panic. Messages below
+// the level are discarded. It can be called at any time, also while other goroutines are logging.
+func SetLevel(level string) error {
+	lvl, err := l.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.SetLevel(lvl)
+	return nil
 }
 
+// SetFormat sets the output format of the standard logger. "text", the default, is meant to be read by humans,
+// "json" writes every entry as a JSON object with timestamp, level, message and the fields of the entry, e.g. for log
+// aggregation. An empty format keeps the current format.
+func SetFormat(format string) error {
+	switch format {
+	case "json":
+		l.SetFormatter(&l.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			FieldMap: l.FieldMap{
+				l.FieldKeyTime: "timestamp",
+				l.FieldKeyMsg:  "message",
+			},
+		})
+	case "text":
+		l.SetFormatter(&l.TextFormatter{})
+	case "":
+		// nothing
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// GetLevel returns the level of the standard logger
+func GetLevel() string {
+	return l.GetLevel().String()
+}
+
+// Fields are key/value pairs attached to a log entry, e.g. to filter or index log messages by them
+type Fields map[string]interface{}
+
 // Debug logs a message at level Debug on the standard logger.
 func Debug(args ...interface{}) {
 	l.Debug(args...)
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	l "github.com/sirupsen/logrus"
+)
+
+func TestSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	defer l.SetOutput(os.Stderr)
+	defer SetLevel(GetLevel())
+
+	if err := SetLevel("warn"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if level := GetLevel(); level != "warning" {
+		t.Errorf("expected level warning, got %s", level)
+	}
+
+	Debug("debug message")
+	Info("info message")
+	Warn("warn message")
+	Error("error message")
+
+	out := buf.String()
+	for _, msg := range []string{"debug message", "info message"} {
+		if strings.Contains(out, msg) {
+			t.Errorf("%q is below the level and should not be logged", msg)
+		}
+	}
+	for _, msg := range []string{"warn message", "error message"} {
+		if !strings.Contains(out, msg) {
+			t.Errorf("%q should be logged", msg)
+		}
+	}
+}
+
+func TestSetLevel_Invalid(t *testing.T) {
+	defer SetLevel(GetLevel())
+	SetLevel("info")
+
+	if err := SetLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if level := GetLevel(); level != "info" {
+		t.Errorf("an unknown level should not change the level, got %s", level)
+	}
+}
+
+func TestSetLevel_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	defer l.SetOutput(os.Stderr)
+	defer SetLevel(GetLevel())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				Debug("message")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, level := range []string{"debug", "info", "error"} {
+				SetLevel(level)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSetFormat_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	defer l.SetOutput(os.Stderr)
+	defer l.SetFormatter(l.StandardLogger().Formatter)
+	SetLevel("debug")
+
+	if err := SetFormat("json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	WithFields(Fields{"topic": "orders", "partition": 2, "offset": int64(42), "key": "order-\x00\"1\""}).Info("new message")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON object, got %s: %v", buf.String(), err)
+	}
+	if entry["message"] != "new message" || entry["level"] != "info" {
+		t.Errorf("unexpected message or level in %v", entry)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string)); err != nil {
+		t.Errorf("unexpected timestamp %v: %v", entry["timestamp"], err)
+	}
+	if entry["topic"] != "orders" || entry["partition"] != float64(2) || entry["offset"] != float64(42) || entry["key"] != "order-\x00\"1\"" {
+		t.Errorf("the fields should be kept with their types, got %v", entry)
+	}
+}
+
+func TestSetFormat_Invalid(t *testing.T) {
+	if err := SetFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
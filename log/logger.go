@@ -0,0 +1,90 @@
+package log
+
+import (
+	"context"
+
+	l "github.com/sirupsen/logrus"
+)
+
+// Logger logs messages with the fields it has been created with. Loggers are cheap to create, e.g. one per request or
+// message, and safe to use from several goroutines.
+type Logger interface {
+	WithFields(fields Fields) Logger
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// fieldsKey is the context key of the fields added with NewContext
+type fieldsKey struct{}
+
+// WithFields returns a logger of the standard logger attaching fields to every message logged with it.
+func WithFields(fields Fields) Logger {
+	return entryLogger{l.WithFields(l.Fields(fields))}
+}
+
+// NewContext returns a copy of ctx carrying fields in addition to the fields already added to ctx, loggers returned by
+// WithContext log them. Fields with the same key replace the ones of ctx.
+func NewContext(ctx context.Context, fields Fields) context.Context {
+	parent, _ := ctx.Value(fieldsKey{}).(Fields)
+	merged := make(Fields, len(parent)+len(fields))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// WithContext returns a logger of the standard logger attaching the fields added to ctx with NewContext
+func WithContext(ctx context.Context) Logger {
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return WithFields(fields)
+}
+
+// entryLogger is a Logger logging with a logrus entry
+type entryLogger struct {
+	entry *l.Entry
+}
+
+func (el entryLogger) WithFields(fields Fields) Logger {
+	return entryLogger{el.entry.WithFields(l.Fields(fields))}
+}
+
+func (el entryLogger) Debug(args ...interface{}) {
+	el.entry.Debug(args...)
+}
+
+func (el entryLogger) Info(args ...interface{}) {
+	el.entry.Info(args...)
+}
+
+func (el entryLogger) Warn(args ...interface{}) {
+	el.entry.Warn(args...)
+}
+
+func (el entryLogger) Error(args ...interface{}) {
+	el.entry.Error(args...)
+}
+
+func (el entryLogger) Debugf(format string, args ...interface{}) {
+	el.entry.Debugf(format, args...)
+}
+
+func (el entryLogger) Infof(format string, args ...interface{}) {
+	el.entry.Infof(format, args...)
+}
+
+func (el entryLogger) Warnf(format string, args ...interface{}) {
+	el.entry.Warnf(format, args...)
+}
+
+func (el entryLogger) Errorf(format string, args ...interface{}) {
+	el.entry.Errorf(format, args...)
+}
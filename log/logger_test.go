@@ -0,0 +1,100 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	l "github.com/sirupsen/logrus"
+)
+
+// captureJSON logs as JSON into a buffer while f runs and returns the decoded entries
+func captureJSON(t *testing.T, f func()) []map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	l.SetOutput(&buf)
+	defer l.SetOutput(os.Stderr)
+	defer l.SetFormatter(l.StandardLogger().Formatter)
+	defer SetLevel(GetLevel())
+	SetFormat("json")
+	SetLevel("debug")
+
+	f()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("cannot decode log entry %s: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestWithFields(t *testing.T) {
+	entries := captureJSON(t, func() {
+		logger := WithFields(Fields{"topic": "orders", "offset": 1})
+		logger.Info("first")
+		logger.WithFields(Fields{"offset": 2, "retry": 1}).Errorf("second %d", 2)
+		logger.Warn("third")
+	})
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[0]["topic"] != "orders" || entries[0]["offset"] != float64(1) {
+		t.Errorf("expected the fields on the first entry, got %v", entries[0])
+	}
+	if entries[1]["message"] != "second 2" || entries[1]["topic"] != "orders" || entries[1]["offset"] != float64(2) || entries[1]["retry"] != float64(1) {
+		t.Errorf("expected the fields of both loggers on the second entry, got %v", entries[1])
+	}
+	if _, ok := entries[2]["retry"]; ok || entries[2]["offset"] != float64(1) {
+		t.Errorf("a derived logger should not change its parent, got %v", entries[2])
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	ctx := NewContext(context.Background(), Fields{"request_id": "abc", "user": "alice"})
+	ctx = NewContext(ctx, Fields{"user": "bob"})
+
+	entries := captureJSON(t, func() {
+		WithContext(ctx).Info("with fields")
+		WithContext(context.Background()).Info("without fields")
+	})
+
+	if entries[0]["request_id"] != "abc" || entries[0]["user"] != "bob" {
+		t.Errorf("expected the fields of the context, got %v", entries[0])
+	}
+	if _, ok := entries[1]["request_id"]; ok {
+		t.Errorf("expected no fields, got %v", entries[1])
+	}
+}
+
+func TestWithFields_Concurrent(t *testing.T) {
+	entries := captureJSON(t, func() {
+		logger := WithFields(Fields{"topic": "orders"})
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				logger.WithFields(Fields{"worker": i}).Info("message")
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	if len(entries) != 10 {
+		t.Fatalf("expected 10 entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry["topic"] != "orders" {
+			t.Errorf("expected the fields of the logger, got %v", entry)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package messaging
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// confluentMagicByte starts every message value in the Confluent wire format, followed by the 4 byte schema id
+const confluentMagicByte = 0
+
+// ErrNoSchemaID is returned when deserializing a value which is not in the Confluent wire format
+var ErrNoSchemaID = errors.New("message value does not start with the schema registry magic byte and schema id")
+
+// AvroCodec encodes Go values to Avro binary data and back for a schema. The package messaging/avro implements it with
+// github.com/hamba/avro, so this package does not depend on an Avro library.
+type AvroCodec interface {
+	Marshal(schema string, v interface{}) ([]byte, error)
+	Unmarshal(schema string, data []byte, v interface{}) error
+}
+
+// AvroSerializer is a Serializer and Deserializer for Avro values in the Confluent wire format. Writers register the
+// schema under the subject "<topic>-value", readers decode values with the schema they have been written with.
+type AvroSerializer struct {
+	registry *SchemaRegistry
+	schema   string
+	codec    AvroCodec
+}
+
+// NewAvroSerializer returns an AvroSerializer writing values with schema. The schema is only used for writing, it can
+// be empty for readers.
+func NewAvroSerializer(registry *SchemaRegistry, schema string, codec AvroCodec) *AvroSerializer {
+	return &AvroSerializer{registry: registry, schema: schema, codec: codec}
+}
+
+// Serialize implements Serializer
+func (as *AvroSerializer) Serialize(topic string, v interface{}) ([]byte, error) {
+	id, err := as.registry.Register(topic+"-value", as.schema)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := as.codec.Marshal(as.schema, v)
+	if err != nil {
+		return nil, err
+	}
+	return AppendSchemaID(id, payload), nil
+}
+
+// Deserialize implements Deserializer
+func (as *AvroSerializer) Deserialize(topic string, data []byte, v interface{}) error {
+	id, payload, err := SplitSchemaID(data)
+	if err != nil {
+		return err
+	}
+	schema, err := as.registry.Schema(id)
+	if err != nil {
+		return err
+	}
+	return as.codec.Unmarshal(schema, payload, v)
+}
+
+// AppendSchemaID prefixes payload with the magic byte and schema id of the Confluent wire format, it is used by
+// serializers of other formats as well
+func AppendSchemaID(id int, payload []byte) []byte {
+	data := make([]byte, 5, 5+len(payload))
+	data[0] = confluentMagicByte
+	binary.BigEndian.PutUint32(data[1:5], uint32(id))
+	return append(data, payload...)
+}
+
+// SplitSchemaID returns the schema id and payload of a value in the Confluent wire format, ErrNoSchemaID if it is not
+func SplitSchemaID(data []byte) (int, []byte, error) {
+	if len(data) < 5 || data[0] != confluentMagicByte {
+		return 0, nil, ErrNoSchemaID
+	}
+	return int(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
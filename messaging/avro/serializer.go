@@ -0,0 +1,63 @@
+// Package avro provides a messaging Serializer and Deserializer for Avro values in the Confluent wire format, encoded
+// with github.com/hamba/avro. It is a separate package, so only users of Avro depend on it.
+package avro
+
+import (
+	"sync"
+
+	"github.com/hamba/avro"
+	"github.com/microdevs/missy/messaging"
+)
+
+// Codec implements messaging.AvroCodec with github.com/hamba/avro. Go structs map to Avro records by their `avro`
+// field tags, e.g. `avro:"id"`. Parsed schemas are cached, so every schema is parsed once.
+type Codec struct {
+	mu      sync.Mutex
+	schemas map[string]avro.Schema
+}
+
+// NewCodec returns a Codec with an empty schema cache
+func NewCodec() *Codec {
+	return &Codec{schemas: make(map[string]avro.Schema)}
+}
+
+// NewAvroSerializer returns a messaging.AvroSerializer encoding values with a Codec. Writers register schema under the
+// subject "<topic>-value", readers decode values with the schema they have been written with, fetched from the
+// registry by its id, into the struct passed to Reader.Decode. The schema can be empty for readers.
+func NewAvroSerializer(registry *messaging.SchemaRegistry, schema string) *messaging.AvroSerializer {
+	return messaging.NewAvroSerializer(registry, schema, NewCodec())
+}
+
+// Marshal implements messaging.AvroCodec
+func (c *Codec) Marshal(schema string, v interface{}) ([]byte, error) {
+	s, err := c.parse(schema)
+	if err != nil {
+		return nil, err
+	}
+	return avro.Marshal(s, v)
+}
+
+// Unmarshal implements messaging.AvroCodec
+func (c *Codec) Unmarshal(schema string, data []byte, v interface{}) error {
+	s, err := c.parse(schema)
+	if err != nil {
+		return err
+	}
+	return avro.Unmarshal(s, data, v)
+}
+
+// parse returns the parsed schema from the cache or parses and caches it
+func (c *Codec) parse(schema string) (avro.Schema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.schemas[schema]; ok {
+		return s, nil
+	}
+	s, err := avro.Parse(schema)
+	if err != nil {
+		return nil, err
+	}
+	c.schemas[schema] = s
+	return s, nil
+}
@@ -0,0 +1,114 @@
+package avro
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/microdevs/missy/messaging"
+)
+
+const orderSchema = `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"},{"name":"amount","type":"double"}]}`
+
+type order struct {
+	ID     string  `avro:"id"`
+	Amount float64 `avro:"amount"`
+}
+
+// newTestSchemaRegistry returns a schema registry server which registers the schema of orders-value as schema 5
+func newTestSchemaRegistry(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/subjects/orders-value/versions":
+			body, _ := ioutil.ReadAll(r.Body)
+			var req struct{ Schema string }
+			if err := json.Unmarshal(body, &req); err != nil || req.Schema != orderSchema {
+				t.Errorf("unexpected schema registration %s", body)
+			}
+			_, _ = w.Write([]byte(`{"id":5}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/5":
+			res, _ := json.Marshal(map[string]string{"schema": orderSchema})
+			_, _ = w.Write(res)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+}
+
+func TestAvroSerializer_RoundTrip(t *testing.T) {
+	server := newTestSchemaRegistry(t)
+	defer server.Close()
+
+	writer := NewAvroSerializer(messaging.NewSchemaRegistry(server.URL), orderSchema)
+	data, err := writer.Serialize("orders", order{ID: "order-1", Amount: 1.5})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if id, _, err := messaging.SplitSchemaID(data); err != nil || id != 5 {
+		t.Errorf("expected schema 5, got %d %v", id, err)
+	}
+
+	reader := NewAvroSerializer(messaging.NewSchemaRegistry(server.URL), "")
+	var decoded order
+	if err := reader.Deserialize("orders", data, &decoded); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if decoded != (order{ID: "order-1", Amount: 1.5}) {
+		t.Errorf("expected the serialized order, got %+v", decoded)
+	}
+}
+
+func TestAvroSerializer_WriteAndDecode(t *testing.T) {
+	server := newTestSchemaRegistry(t)
+	defer server.Close()
+
+	im := messaging.NewInMemory()
+	w := im.NewWriter("orders", messaging.WithSerializer(NewAvroSerializer(messaging.NewSchemaRegistry(server.URL), orderSchema)))
+	r := im.NewReader("group", "orders", messaging.WithDeserializer(NewAvroSerializer(messaging.NewSchemaRegistry(server.URL), "")))
+	defer r.Close()
+
+	if err := w.WriteValue([]byte("order-1"), order{ID: "order-1", Amount: 1.5}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan order, 1)
+	go r.ReadContext(ctx, func(m messaging.Message) error {
+		var o order
+		if err := r.Decode(m, &o); err != nil {
+			t.Errorf("unexpected error decoding: %v", err)
+		}
+		received <- o
+		return nil
+	})
+
+	select {
+	case o := <-received:
+		if o != (order{ID: "order-1", Amount: 1.5}) {
+			t.Errorf("expected the written order, got %+v", o)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message was not delivered")
+	}
+}
+
+func TestCodec_Errors(t *testing.T) {
+	codec := NewCodec()
+
+	if _, err := codec.Marshal(`{"type":"unknown"}`, order{}); err == nil {
+		t.Error("invalid schemas should fail")
+	}
+	if _, err := codec.Marshal(orderSchema, "order"); err == nil {
+		t.Error("values not matching the schema should fail")
+	}
+	var decoded order
+	if err := codec.Unmarshal(orderSchema, []byte{0xff}, &decoded); err == nil {
+		t.Error("malformed values should fail")
+	}
+}
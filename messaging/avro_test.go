@@ -0,0 +1,115 @@
+package messaging
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// jsonAvroCodec stands in for an Avro library, it records the schema it has been called with
+type jsonAvroCodec struct {
+	schema string
+}
+
+func (c *jsonAvroCodec) Marshal(schema string, v interface{}) ([]byte, error) {
+	c.schema = schema
+	return json.Marshal(v)
+}
+
+func (c *jsonAvroCodec) Unmarshal(schema string, data []byte, v interface{}) error {
+	c.schema = schema
+	return json.Unmarshal(data, v)
+}
+
+// newTestSchemaRegistry returns a schema registry server which knows schema 7 and counts the requests
+func newTestSchemaRegistry(t *testing.T, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/subjects/orders-value/versions":
+			body, _ := ioutil.ReadAll(r.Body)
+			var req struct{ Schema string }
+			if err := json.Unmarshal(body, &req); err != nil || req.Schema != `{"type":"record"}` {
+				t.Errorf("unexpected schema registration %s", body)
+			}
+			_, _ = w.Write([]byte(`{"id":7}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/schemas/ids/7":
+			_, _ = w.Write([]byte(`{"schema":"{\"type\":\"record\"}"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+}
+
+func TestAvroSerializer_RoundTrip(t *testing.T) {
+	requests := 0
+	server := newTestSchemaRegistry(t, &requests)
+	defer server.Close()
+
+	codec := &jsonAvroCodec{}
+	serializer := NewAvroSerializer(NewSchemaRegistry(server.URL), `{"type":"record"}`, codec)
+
+	data, err := serializer.Serialize("orders", order{ID: "order-1", Amount: 1.5})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if data[0] != 0 || data[1] != 0 || data[2] != 0 || data[3] != 0 || data[4] != 7 {
+		t.Errorf("expected the magic byte and schema id 7, got %v", data[:5])
+	}
+	if _, err := serializer.Serialize("orders", order{ID: "order-2"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// a reader without the schema fetches it from the registry
+	deserializer := NewAvroSerializer(NewSchemaRegistry(server.URL), "", codec)
+	var decoded order
+	if err := deserializer.Deserialize("orders", data, &decoded); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if decoded != (order{ID: "order-1", Amount: 1.5}) {
+		t.Errorf("expected the serialized order, got %+v", decoded)
+	}
+	if codec.schema != `{"type":"record"}` {
+		t.Error(expected(codec.schema, `{"type":"record"}`))
+	}
+	if requests != 2 {
+		t.Errorf("expected the registered and fetched schema to be cached, got %d requests", requests)
+	}
+}
+
+func TestAvroSerializer_Errors(t *testing.T) {
+	requests := 0
+	server := newTestSchemaRegistry(t, &requests)
+	defer server.Close()
+
+	serializer := NewAvroSerializer(NewSchemaRegistry(server.URL), "", &jsonAvroCodec{})
+
+	var decoded order
+	if err := serializer.Deserialize("orders", []byte(`{"id":"order-1"}`), &decoded); err != ErrNoSchemaID {
+		t.Errorf("expected %v, got %v", ErrNoSchemaID, err)
+	}
+	if err := serializer.Deserialize("orders", AppendSchemaID(8, []byte(`{}`)), &decoded); err == nil {
+		t.Error("unknown schemas should fail")
+	}
+	if _, err := serializer.Serialize("invoices", order{}); err == nil {
+		t.Error("a failed schema registration should fail")
+	}
+}
+
+func TestSplitSchemaID(t *testing.T) {
+	id, payload, err := SplitSchemaID(AppendSchemaID(258, []byte("payload")))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if id != 258 || string(payload) != "payload" {
+		t.Errorf("expected schema 258 with the payload, got %d %q", id, payload)
+	}
+	if _, _, err := SplitSchemaID([]byte{1, 0, 0, 0, 1}); !errors.Is(err, ErrNoSchemaID) {
+		t.Errorf("expected %v, got %v", ErrNoSchemaID, err)
+	}
+}
@@ -7,7 +7,9 @@ package messaging
 import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
+	kafka "github.com/segmentio/kafka-go"
 	reflect "reflect"
+	time "time"
 )
 
 // MockReader is a mock of Reader interface
@@ -35,6 +37,7 @@ func (m *MockReader) EXPECT() *MockReaderMockRecorder {
 
 // Read mocks base method
 func (m *MockReader) Read(msgFunc ReadMessageFunc) error {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Read", msgFunc)
 	ret0, _ := ret[0].(error)
 	return ret0
@@ -42,11 +45,285 @@ func (m *MockReader) Read(msgFunc ReadMessageFunc) error {
 
 // Read indicates an expected call of Read
 func (mr *MockReaderMockRecorder) Read(msgFunc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockReader)(nil).Read), msgFunc)
 }
 
+// ReadContext mocks base method
+func (m *MockReader) ReadContext(ctx context.Context, msgFunc ReadMessageFunc) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadContext", ctx, msgFunc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadContext indicates an expected call of ReadContext
+func (mr *MockReaderMockRecorder) ReadContext(ctx, msgFunc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadContext", reflect.TypeOf((*MockReader)(nil).ReadContext), ctx, msgFunc)
+}
+
+// ReadCtx mocks base method
+func (m *MockReader) ReadCtx(ctx context.Context, msgFunc ReadMessageFuncCtx) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadCtx", ctx, msgFunc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadCtx indicates an expected call of ReadCtx
+func (mr *MockReaderMockRecorder) ReadCtx(ctx, msgFunc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadCtx", reflect.TypeOf((*MockReader)(nil).ReadCtx), ctx, msgFunc)
+}
+
+// ReadN mocks base method
+func (m *MockReader) ReadN(ctx context.Context, n int, msgFunc ReadMessageFunc) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadN", ctx, n, msgFunc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadN indicates an expected call of ReadN
+func (mr *MockReaderMockRecorder) ReadN(ctx, n, msgFunc interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadN", reflect.TypeOf((*MockReader)(nil).ReadN), ctx, n, msgFunc)
+}
+
+// Done mocks base method
+func (m *MockReader) Done() <-chan error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Done")
+	ret0, _ := ret[0].(<-chan error)
+	return ret0
+}
+
+// Done indicates an expected call of Done
+func (mr *MockReaderMockRecorder) Done() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Done", reflect.TypeOf((*MockReader)(nil).Done))
+}
+
+// CommitMessages mocks base method
+func (m *MockReader) CommitMessages(msgs ...Message) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range msgs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CommitMessages", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CommitMessages indicates an expected call of CommitMessages
+func (mr *MockReaderMockRecorder) CommitMessages(msgs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitMessages", reflect.TypeOf((*MockReader)(nil).CommitMessages), msgs...)
+}
+
+// CommitOffset mocks base method
+func (m *MockReader) CommitOffset(topic string, partition int, offset int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CommitOffset", topic, partition, offset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CommitOffset indicates an expected call of CommitOffset
+func (mr *MockReaderMockRecorder) CommitOffset(topic, partition, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitOffset", reflect.TypeOf((*MockReader)(nil).CommitOffset), topic, partition, offset)
+}
+
+// Errors mocks base method
+func (m *MockReader) Errors() <-chan error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Errors")
+	ret0, _ := ret[0].(<-chan error)
+	return ret0
+}
+
+// Errors indicates an expected call of Errors
+func (mr *MockReaderMockRecorder) Errors() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Errors", reflect.TypeOf((*MockReader)(nil).Errors))
+}
+
+// Lag mocks base method
+func (m *MockReader) Lag() (map[int]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lag")
+	ret0, _ := ret[0].(map[int]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Lag indicates an expected call of Lag
+func (mr *MockReaderMockRecorder) Lag() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lag", reflect.TypeOf((*MockReader)(nil).Lag))
+}
+
+// CaughtUp mocks base method
+func (m *MockReader) CaughtUp() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaughtUp")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CaughtUp indicates an expected call of CaughtUp
+func (mr *MockReaderMockRecorder) CaughtUp() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaughtUp", reflect.TypeOf((*MockReader)(nil).CaughtUp))
+}
+
+// SetOffset mocks base method
+func (m *MockReader) SetOffset(partition int, offset int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetOffset", partition, offset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetOffset indicates an expected call of SetOffset
+func (mr *MockReaderMockRecorder) SetOffset(partition, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetOffset", reflect.TypeOf((*MockReader)(nil).SetOffset), partition, offset)
+}
+
+// SeekToTime mocks base method
+func (m *MockReader) SeekToTime(t time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SeekToTime", t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SeekToTime indicates an expected call of SeekToTime
+func (mr *MockReaderMockRecorder) SeekToTime(t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SeekToTime", reflect.TypeOf((*MockReader)(nil).SeekToTime), t)
+}
+
+// Shutdown mocks base method
+func (m *MockReader) Shutdown(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Shutdown", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Shutdown indicates an expected call of Shutdown
+func (mr *MockReaderMockRecorder) Shutdown(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shutdown", reflect.TypeOf((*MockReader)(nil).Shutdown), ctx)
+}
+
+// Stats mocks base method
+func (m *MockReader) Stats() kafka.ReaderStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(kafka.ReaderStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockReaderMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockReader)(nil).Stats))
+}
+
+// Peek mocks base method
+func (m *MockReader) Peek(ctx context.Context, n int) ([]Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Peek", ctx, n)
+	ret0, _ := ret[0].([]Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Peek indicates an expected call of Peek
+func (mr *MockReaderMockRecorder) Peek(ctx, n interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Peek", reflect.TypeOf((*MockReader)(nil).Peek), ctx, n)
+}
+
+// Decode mocks base method
+func (m_2 *MockReader) Decode(m Message, v interface{}) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "Decode", m, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Decode indicates an expected call of Decode
+func (mr *MockReaderMockRecorder) Decode(m, v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockReader)(nil).Decode), m, v)
+}
+
+// Ping mocks base method
+func (m *MockReader) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockReaderMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockReader)(nil).Ping), ctx)
+}
+
+// Topic mocks base method
+func (m *MockReader) Topic() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Topic")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Topic indicates an expected call of Topic
+func (mr *MockReaderMockRecorder) Topic() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Topic", reflect.TypeOf((*MockReader)(nil).Topic))
+}
+
+// GroupID mocks base method
+func (m *MockReader) GroupID() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GroupID")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GroupID indicates an expected call of GroupID
+func (mr *MockReaderMockRecorder) GroupID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GroupID", reflect.TypeOf((*MockReader)(nil).GroupID))
+}
+
+// Brokers mocks base method
+func (m *MockReader) Brokers() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Brokers")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Brokers indicates an expected call of Brokers
+func (mr *MockReaderMockRecorder) Brokers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Brokers", reflect.TypeOf((*MockReader)(nil).Brokers))
+}
+
 // Close mocks base method
 func (m *MockReader) Close() error {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
 	return ret0
@@ -54,6 +331,7 @@ func (m *MockReader) Close() error {
 
 // Close indicates an expected call of Close
 func (mr *MockReaderMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockReader)(nil).Close))
 }
 
@@ -82,6 +360,7 @@ func (m *MockBrokerReader) EXPECT() *MockBrokerReaderMockRecorder {
 
 // FetchMessage mocks base method
 func (m *MockBrokerReader) FetchMessage(ctx context.Context) (Message, error) {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "FetchMessage", ctx)
 	ret0, _ := ret[0].(Message)
 	ret1, _ := ret[1].(error)
@@ -90,11 +369,13 @@ func (m *MockBrokerReader) FetchMessage(ctx context.Context) (Message, error) {
 
 // FetchMessage indicates an expected call of FetchMessage
 func (mr *MockBrokerReaderMockRecorder) FetchMessage(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchMessage", reflect.TypeOf((*MockBrokerReader)(nil).FetchMessage), ctx)
 }
 
 // CommitMessages mocks base method
 func (m *MockBrokerReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	m.ctrl.T.Helper()
 	varargs := []interface{}{ctx}
 	for _, a := range msgs {
 		varargs = append(varargs, a)
@@ -106,12 +387,14 @@ func (m *MockBrokerReader) CommitMessages(ctx context.Context, msgs ...Message)
 
 // CommitMessages indicates an expected call of CommitMessages
 func (mr *MockBrokerReaderMockRecorder) CommitMessages(ctx interface{}, msgs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	varargs := append([]interface{}{ctx}, msgs...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitMessages", reflect.TypeOf((*MockBrokerReader)(nil).CommitMessages), varargs...)
 }
 
 // ReadMessage mocks base method
 func (m *MockBrokerReader) ReadMessage(ctx context.Context) (Message, error) {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ReadMessage", ctx)
 	ret0, _ := ret[0].(Message)
 	ret1, _ := ret[1].(error)
@@ -120,11 +403,27 @@ func (m *MockBrokerReader) ReadMessage(ctx context.Context) (Message, error) {
 
 // ReadMessage indicates an expected call of ReadMessage
 func (mr *MockBrokerReaderMockRecorder) ReadMessage(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadMessage", reflect.TypeOf((*MockBrokerReader)(nil).ReadMessage), ctx)
 }
 
+// Stats mocks base method
+func (m *MockBrokerReader) Stats() kafka.ReaderStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(kafka.ReaderStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockBrokerReaderMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockBrokerReader)(nil).Stats))
+}
+
 // Close mocks base method
 func (m *MockBrokerReader) Close() error {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
 	return ret0
@@ -132,5 +431,6 @@ func (m *MockBrokerReader) Close() error {
 
 // Close indicates an expected call of Close
 func (mr *MockBrokerReaderMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockBrokerReader)(nil).Close))
 }
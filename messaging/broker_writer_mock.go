@@ -6,9 +6,9 @@ package messaging
 
 import (
 	context "context"
-	reflect "reflect"
-
 	gomock "github.com/golang/mock/gomock"
+	kafka "github.com/segmentio/kafka-go"
+	reflect "reflect"
 )
 
 // MockWriter is a mock of Writer interface
@@ -36,6 +36,7 @@ func (m *MockWriter) EXPECT() *MockWriterMockRecorder {
 
 // Write mocks base method
 func (m *MockWriter) Write(key, value []byte) error {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Write", key, value)
 	ret0, _ := ret[0].(error)
 	return ret0
@@ -43,11 +44,216 @@ func (m *MockWriter) Write(key, value []byte) error {
 
 // Write indicates an expected call of Write
 func (mr *MockWriterMockRecorder) Write(key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockWriter)(nil).Write), key, value)
 }
 
+// WriteContext mocks base method
+func (m *MockWriter) WriteContext(ctx context.Context, key, value []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteContext", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteContext indicates an expected call of WriteContext
+func (mr *MockWriterMockRecorder) WriteContext(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteContext", reflect.TypeOf((*MockWriter)(nil).WriteContext), ctx, key, value)
+}
+
+// WriteWithRetryCounter mocks base method
+func (m *MockWriter) WriteWithRetryCounter(key, value []byte, retryCounter int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteWithRetryCounter", key, value, retryCounter)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteWithRetryCounter indicates an expected call of WriteWithRetryCounter
+func (mr *MockWriterMockRecorder) WriteWithRetryCounter(key, value, retryCounter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteWithRetryCounter", reflect.TypeOf((*MockWriter)(nil).WriteWithRetryCounter), key, value, retryCounter)
+}
+
+// WriteWithHeaders mocks base method
+func (m *MockWriter) WriteWithHeaders(key, value []byte, headers ...Header) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{key, value}
+	for _, a := range headers {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "WriteWithHeaders", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteWithHeaders indicates an expected call of WriteWithHeaders
+func (mr *MockWriterMockRecorder) WriteWithHeaders(key, value interface{}, headers ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{key, value}, headers...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteWithHeaders", reflect.TypeOf((*MockWriter)(nil).WriteWithHeaders), varargs...)
+}
+
+// WriteTo mocks base method
+func (m *MockWriter) WriteTo(topic string, key, value []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTo", topic, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteTo indicates an expected call of WriteTo
+func (mr *MockWriterMockRecorder) WriteTo(topic, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTo", reflect.TypeOf((*MockWriter)(nil).WriteTo), topic, key, value)
+}
+
+// WriteValue mocks base method
+func (m *MockWriter) WriteValue(key []byte, v interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteValue", key, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteValue indicates an expected call of WriteValue
+func (mr *MockWriterMockRecorder) WriteValue(key, v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteValue", reflect.TypeOf((*MockWriter)(nil).WriteValue), key, v)
+}
+
+// WriteString mocks base method
+func (m *MockWriter) WriteString(key string, value []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteString", key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteString indicates an expected call of WriteString
+func (mr *MockWriterMockRecorder) WriteString(key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteString", reflect.TypeOf((*MockWriter)(nil).WriteString), key, value)
+}
+
+// WriteJSON mocks base method
+func (m *MockWriter) WriteJSON(key string, v interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteJSON", key, v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteJSON indicates an expected call of WriteJSON
+func (mr *MockWriterMockRecorder) WriteJSON(key, v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteJSON", reflect.TypeOf((*MockWriter)(nil).WriteJSON), key, v)
+}
+
+// WriteMessage mocks base method
+func (m_2 *MockWriter) WriteMessage(m Message) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "WriteMessage", m)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteMessage indicates an expected call of WriteMessage
+func (mr *MockWriterMockRecorder) WriteMessage(m interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteMessage", reflect.TypeOf((*MockWriter)(nil).WriteMessage), m)
+}
+
+// WriteBatch mocks base method
+func (m *MockWriter) WriteBatch(msgs []Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBatch", msgs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteBatch indicates an expected call of WriteBatch
+func (mr *MockWriterMockRecorder) WriteBatch(msgs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBatch", reflect.TypeOf((*MockWriter)(nil).WriteBatch), msgs)
+}
+
+// WriteBatchContext mocks base method
+func (m *MockWriter) WriteBatchContext(ctx context.Context, msgs []Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBatchContext", ctx, msgs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteBatchContext indicates an expected call of WriteBatchContext
+func (mr *MockWriterMockRecorder) WriteBatchContext(ctx, msgs interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBatchContext", reflect.TypeOf((*MockWriter)(nil).WriteBatchContext), ctx, msgs)
+}
+
+// ProduceSync mocks base method
+func (m *MockWriter) ProduceSync(ctx context.Context, msg Message) (int, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceSync", ctx, msg)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ProduceSync indicates an expected call of ProduceSync
+func (mr *MockWriterMockRecorder) ProduceSync(ctx, msg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceSync", reflect.TypeOf((*MockWriter)(nil).ProduceSync), ctx, msg)
+}
+
+// Flush mocks base method
+func (m *MockWriter) Flush(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Flush", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Flush indicates an expected call of Flush
+func (mr *MockWriterMockRecorder) Flush(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Flush", reflect.TypeOf((*MockWriter)(nil).Flush), ctx)
+}
+
+// Stats mocks base method
+func (m *MockWriter) Stats() kafka.WriterStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(kafka.WriterStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockWriterMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockWriter)(nil).Stats))
+}
+
+// Ping mocks base method
+func (m *MockWriter) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockWriterMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockWriter)(nil).Ping), ctx)
+}
+
 // Close mocks base method
 func (m *MockWriter) Close() error {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
 	return ret0
@@ -55,6 +261,7 @@ func (m *MockWriter) Close() error {
 
 // Close indicates an expected call of Close
 func (mr *MockWriterMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockWriter)(nil).Close))
 }
 
@@ -83,6 +290,7 @@ func (m *MockBrokerWriter) EXPECT() *MockBrokerWriterMockRecorder {
 
 // WriteMessages mocks base method
 func (m *MockBrokerWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	m.ctrl.T.Helper()
 	varargs := []interface{}{ctx}
 	for _, a := range msgs {
 		varargs = append(varargs, a)
@@ -94,12 +302,28 @@ func (m *MockBrokerWriter) WriteMessages(ctx context.Context, msgs ...Message) e
 
 // WriteMessages indicates an expected call of WriteMessages
 func (mr *MockBrokerWriterMockRecorder) WriteMessages(ctx interface{}, msgs ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	varargs := append([]interface{}{ctx}, msgs...)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteMessages", reflect.TypeOf((*MockBrokerWriter)(nil).WriteMessages), varargs...)
 }
 
+// Stats mocks base method
+func (m *MockBrokerWriter) Stats() kafka.WriterStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(kafka.WriterStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockBrokerWriterMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockBrokerWriter)(nil).Stats))
+}
+
 // Close mocks base method
 func (m *MockBrokerWriter) Close() error {
+	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
 	return ret0
@@ -107,5 +331,6 @@ func (m *MockBrokerWriter) Close() error {
 
 // Close indicates an expected call of Close
 func (mr *MockBrokerWriterMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockBrokerWriter)(nil).Close))
 }
@@ -0,0 +1,154 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// breakerState is the state of a circuitBreaker
+type breakerState int
+
+const (
+	// breakerClosed lets messages be fetched
+	breakerClosed breakerState = iota
+	// breakerOpen pauses fetching until the cooldown has passed
+	breakerOpen
+	// breakerHalfOpen lets a single message be fetched to probe whether msgFunc succeeds again
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker pauses fetching after threshold consecutive failures of msgFunc. Once cooldown has passed a single
+// message is fetched as probe, it closes the breaker if it succeeds and opens it for another cooldown if it fails.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	// changed is closed and replaced whenever the state changes, fetches waiting for the breaker wait for it
+	changed  chan struct{}
+	onChange func(state breakerState)
+}
+
+// newCircuitBreaker returns a closed circuitBreaker calling onChange after every change of its state
+func newCircuitBreaker(threshold int, cooldown time.Duration, onChange func(state breakerState)) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, changed: make(chan struct{}), onChange: onChange}
+}
+
+// wait blocks while the breaker is open or a probe is processed, it returns the error of ctx if ctx is done first
+func (cb *circuitBreaker) wait(ctx context.Context) error {
+	for {
+		cb.mu.Lock()
+		state := cb.state
+		changed := cb.changed
+		var delay time.Duration
+		if state == breakerOpen {
+			delay = cb.cooldown - time.Since(cb.openedAt)
+			if delay <= 0 {
+				cb.setState(breakerHalfOpen)
+				state = breakerHalfOpen
+			}
+		}
+		if state == breakerHalfOpen && !cb.probing {
+			// this fetch is the probe
+			cb.probing = true
+			cb.mu.Unlock()
+			return nil
+		}
+		cb.mu.Unlock()
+
+		if state == breakerClosed {
+			return nil
+		}
+
+		// half-open waits for the result of the probe, open for the end of the cooldown as well
+		var timer *time.Timer
+		var cooldownDone <-chan time.Time
+		if state == breakerOpen {
+			timer = time.NewTimer(delay)
+			cooldownDone = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-cooldownDone:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// record counts a failure of msgFunc or resets the failures after a success
+func (cb *circuitBreaker) record(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !failed {
+		cb.failures = 0
+		cb.probing = false
+		if cb.state != breakerClosed {
+			cb.setState(breakerClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == breakerHalfOpen || (cb.state == breakerClosed && cb.failures >= cb.threshold) {
+		cb.openedAt = time.Now()
+		cb.setState(breakerOpen)
+	}
+}
+
+// skipped lets the next fetch probe if the probe has been skipped without calling msgFunc
+func (cb *circuitBreaker) skipped() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == breakerHalfOpen && cb.probing {
+		cb.probing = false
+		close(cb.changed)
+		cb.changed = make(chan struct{})
+	}
+}
+
+// setState changes the state and wakes up waiting fetches, cb.mu must be held
+func (cb *circuitBreaker) setState(state breakerState) {
+	cb.state = state
+	close(cb.changed)
+	cb.changed = make(chan struct{})
+	if cb.onChange != nil {
+		cb.onChange(state)
+	}
+}
+
+// breakerChanged logs and counts the state changes of the circuit breaker of the reader
+func (mr *missyReader) breakerChanged(state breakerState) {
+	switch state {
+	case breakerOpen:
+		mr.getLogger().Warnf("# messaging # circuit breaker of group %s open, pausing fetching for %s", mr.groupID, mr.breaker.cooldown)
+	case breakerHalfOpen:
+		mr.getLogger().Infof("# messaging # circuit breaker of group %s half-open, probing with the next message", mr.groupID)
+	default:
+		mr.getLogger().Infof("# messaging # circuit breaker of group %s closed, fetching continues", mr.groupID)
+	}
+	getReaderMetrics().onBreakerChanged(mr.groupID, mr.topic, state)
+}
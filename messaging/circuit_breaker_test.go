@@ -0,0 +1,158 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// breakerRecorder records the state changes of a circuit breaker
+type breakerRecorder struct {
+	mu     sync.Mutex
+	states []breakerState
+}
+
+func (br *breakerRecorder) onChange(state breakerState) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.states = append(br.states, state)
+}
+
+func (br *breakerRecorder) get() []breakerState {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return append([]breakerState(nil), br.states...)
+}
+
+func TestCircuitBreaker_Transitions(t *testing.T) {
+	br := &breakerRecorder{}
+	cb := newCircuitBreaker(2, 50*time.Millisecond, br.onChange)
+	ctx := context.Background()
+
+	cb.record(true)
+	cb.record(false)
+	cb.record(true)
+	if cb.state != breakerClosed {
+		t.Fatalf("a success should reset the failures, got state %s", cb.state)
+	}
+	cb.record(true)
+	if cb.state != breakerOpen {
+		t.Fatalf("expected the breaker to open after 2 consecutive failures, got %s", cb.state)
+	}
+
+	start := time.Now()
+	if err := cb.wait(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("fetching should be paused for the cooldown, waited %s", elapsed)
+	}
+	if cb.state != breakerHalfOpen {
+		t.Fatalf("expected the breaker to be half-open after the cooldown, got %s", cb.state)
+	}
+
+	// only the probe is fetched while the breaker is half-open
+	probeCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := cb.wait(probeCtx); err != context.DeadlineExceeded {
+		t.Errorf("expected a second fetch to wait for the probe, got %v", err)
+	}
+
+	// a failed probe opens the breaker for another cooldown
+	cb.record(true)
+	if cb.state != breakerOpen {
+		t.Fatalf("expected a failed probe to open the breaker, got %s", cb.state)
+	}
+	if err := cb.wait(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cb.record(false)
+	if cb.state != breakerClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %s", cb.state)
+	}
+	if err := cb.wait(ctx); err != nil {
+		t.Errorf("a closed breaker should not wait: %v", err)
+	}
+
+	expectedStates := []breakerState{breakerOpen, breakerHalfOpen, breakerOpen, breakerHalfOpen, breakerClosed}
+	if states := br.get(); !reflect.DeepEqual(states, expectedStates) {
+		t.Errorf("expected states %v, got %v", expectedStates, states)
+	}
+}
+
+func TestCircuitBreaker_SkippedProbe(t *testing.T) {
+	cb := newCircuitBreaker(1, 0, nil)
+	cb.record(true)
+	if err := cb.wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cb.skipped()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := cb.wait(ctx); err != nil {
+		t.Errorf("a skipped probe should let the next message probe, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_WaitStopsWithContext(t *testing.T) {
+	cb := newCircuitBreaker(1, time.Hour, nil)
+	cb.record(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := cb.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for i := 0; i < 5; i++ {
+		if err := w.Write(nil, []byte("value")); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	r := im.NewReader("group", "orders", WithCircuitBreaker(3, 200*time.Millisecond))
+	defer r.Close()
+
+	var down int32 = 1
+	var calls, succeeded int32
+	r.Read(func(m Message) error {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			return errors.New("downstream unavailable")
+		}
+		atomic.AddInt32(&succeeded, 1)
+		return nil
+	})
+
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 3 })
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("fetching should be paused after 3 failures, msgFunc was called %d times", n)
+	}
+
+	atomic.StoreInt32(&down, 0)
+	// the 2 messages which have not been fetched yet and the retries of the 3 failed ones
+	waitFor(t, func() bool { return atomic.LoadInt32(&succeeded) == 5 })
+	if dlq := im.Messages("orders.dlq"); len(dlq) != 0 {
+		t.Errorf("no message should end up in the DLQ, got %d", len(dlq))
+	}
+}
+
+func TestWithCircuitBreaker_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("a threshold of 0 should panic")
+		}
+	}()
+	WithCircuitBreaker(0, time.Second)(&missyReader{})
+}
@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"sync"
+)
+
+// ContentTypeRaw is the content type of the raw codec, it is used for messages without content type header
+const ContentTypeRaw = "application/octet-stream"
+
+// ErrUnknownContentType is returned by Message.Decode if no codec is registered for the content type of the message
+var ErrUnknownContentType = errors.New("no codec registered for the content type of the message")
+
+// Codec encodes values into message values and back
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+// serializerCodec adapts a Serializer and Deserializer, which do not depend on the topic, to a Codec
+type serializerCodec struct {
+	s interface {
+		Serializer
+		Deserializer
+	}
+}
+
+// Encode implements Codec
+func (c serializerCodec) Encode(v interface{}) ([]byte, error) {
+	return c.s.Serialize("", v)
+}
+
+// Decode implements Codec
+func (c serializerCodec) Decode(data []byte, v interface{}) error {
+	return c.s.Deserialize("", data, v)
+}
+
+var codecsMu sync.RWMutex
+
+// codecs are the registered codecs by content type
+var codecs = map[string]Codec{
+	ContentTypeJSON: serializerCodec{JSONSerializer{}},
+	ContentTypeRaw:  serializerCodec{RawSerializer{}},
+}
+
+// RegisterCodec registers codec for messages with the given content type header, replacing a codec already registered
+// for it. JSON and raw codecs are registered by default.
+func RegisterCodec(contentType string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[mediaType(contentType)] = codec
+}
+
+// Decode decodes the value of the message into v with the codec registered for its content type header, parameters
+// like the charset are ignored. Messages without content type header are decoded with the raw codec.
+func (m Message) Decode(v interface{}) error {
+	contentType := ContentTypeRaw
+	if header, ok := m.GetHeader(ContentTypeHeader); ok && len(header) > 0 {
+		contentType = mediaType(string(header))
+	}
+
+	codecsMu.RLock()
+	codec, ok := codecs[contentType]
+	codecsMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownContentType, contentType)
+	}
+	return codec.Decode(m.Value, v)
+}
+
+// mediaType returns the content type without parameters, e.g. application/json for application/json; charset=utf-8
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return t
+}
@@ -0,0 +1,65 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// upperCodec decodes values into upper case strings
+type upperCodec struct{}
+
+func (upperCodec) Encode(v interface{}) ([]byte, error) {
+	return []byte(strings.ToLower(v.(string))), nil
+}
+
+func (upperCodec) Decode(data []byte, v interface{}) error {
+	*v.(*string) = strings.ToUpper(string(data))
+	return nil
+}
+
+func TestMessage_DecodeSelectsCodec(t *testing.T) {
+	jsonMessage, err := NewJSONMessage("key", order{ID: "order-1"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var decoded order
+	if err := jsonMessage.Decode(&decoded); err != nil || decoded.ID != "order-1" {
+		t.Errorf("expected the JSON codec to be used, got %+v, %v", decoded, err)
+	}
+
+	withCharset := Message{Value: []byte(`{"id":"order-2"}`), Headers: []Header{{Key: ContentTypeHeader, Value: []byte("application/json; charset=utf-8")}}}
+	if err := withCharset.Decode(&decoded); err != nil || decoded.ID != "order-2" {
+		t.Errorf("expected content type parameters to be ignored, got %+v, %v", decoded, err)
+	}
+
+	var raw []byte
+	if err := (Message{Value: []byte("raw")}).Decode(&raw); err != nil || string(raw) != "raw" {
+		t.Errorf("expected messages without content type to be decoded raw, got %q, %v", raw, err)
+	}
+}
+
+func TestMessage_DecodeUnknownContentType(t *testing.T) {
+	m := Message{Value: []byte("value"), Headers: []Header{{Key: ContentTypeHeader, Value: []byte("application/x-unknown")}}}
+
+	var v string
+	if err := m.Decode(&v); !errors.Is(err, ErrUnknownContentType) {
+		t.Errorf("expected %v, got %v", ErrUnknownContentType, err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("text/x-upper; charset=utf-8", upperCodec{})
+	defer func() {
+		codecsMu.Lock()
+		delete(codecs, "text/x-upper")
+		codecsMu.Unlock()
+	}()
+
+	m := Message{Value: []byte("missy"), Headers: []Header{{Key: ContentTypeHeader, Value: []byte("text/x-upper")}}}
+	var v string
+	if err := m.Decode(&v); err != nil || v != "MISSY" {
+		t.Errorf("expected the registered codec to be used, got %q, %v", v, err)
+	}
+}
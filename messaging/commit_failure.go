@@ -0,0 +1,55 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CommitFailurePolicy decides what a reader does when committing handled messages fails. Uncommitted messages are
+// delivered again after a restart or rebalance.
+type CommitFailurePolicy int
+
+const (
+	// CommitFailureRetry retries the commit up to 3 times with a backoff starting at 100ms and reports the error on the
+	// errors channel if all of them fail. It is the default.
+	CommitFailureRetry CommitFailurePolicy = iota
+	// CommitFailureReport reports the error on the errors channel right away, reading continues
+	CommitFailureReport
+	// CommitFailurePanic panics, crashing the process, for deployments preferring a restart over reprocessing
+	CommitFailurePanic
+)
+
+const (
+	// commitRetries is how often CommitFailureRetry retries a failed commit
+	commitRetries = 3
+	// commitRetryBackoff is the delay before the first commit retry, it doubles with every retry
+	commitRetryBackoff = 100 * time.Millisecond
+)
+
+// commitWithPolicy commits msgs and handles a failure according to the commit failure policy, it returns the error
+// of the last attempt. Retries stop early on Close or once the deadline of Shutdown expired.
+func (mr *missyReader) commitWithPolicy(msgs []Message) error {
+	err := mr.brokerReader.CommitMessages(context.Background(), msgs...)
+	if err == nil {
+		return nil
+	}
+
+	switch mr.commitFailurePolicy {
+	case CommitFailurePanic:
+		panic(fmt.Sprintf("# messaging # cannot commit %d messages: %v", len(msgs), err))
+	case CommitFailureRetry:
+		backoff := commitRetryBackoff
+		for attempt := 1; attempt <= commitRetries && err != nil; attempt++ {
+			mr.getLogger().Warnf("# messaging # cannot commit %d messages, retrying in %s (%d/%d): %v", len(msgs), backoff, attempt, commitRetries, err)
+			select {
+			case <-time.After(backoff):
+			case <-mr.stopping:
+				return err
+			}
+			backoff *= 2
+			err = mr.brokerReader.CommitMessages(context.Background(), msgs...)
+		}
+	}
+	return err
+}
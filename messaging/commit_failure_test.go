@@ -0,0 +1,116 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+)
+
+func TestCommitFailureRetry(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Partition: 1, Offset: 2}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Times(2).Return(errors.New("commit error")),
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil),
+	)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	reader.commit(msg)
+
+	select {
+	case err := <-reader.Errors():
+		t.Errorf("a commit succeeding on retry should not be reported, got %v", err)
+	default:
+	}
+	mockCtrl.Finish()
+}
+
+func TestCommitFailureRetry_Exhausted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Partition: 1, Offset: 2}
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Times(1 + commitRetries).Return(errors.New("commit error"))
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithCommitFailurePolicy(CommitFailureRetry)(&reader)
+	reader.commit(msg)
+
+	select {
+	case err := <-reader.Errors():
+		if readErr, ok := err.(*ReadError); !ok || readErr.Kind != CommitError {
+			t.Errorf("expected a commit error, got %v", err)
+		}
+	default:
+		t.Error("the failed commit should be reported once the retries are exhausted")
+	}
+	mockCtrl.Finish()
+}
+
+func TestCommitFailureRetry_Closed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Partition: 1, Offset: 2}
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(errors.New("commit error"))
+
+	stopping := make(chan struct{})
+	close(stopping)
+	reader := missyReader{brokerReader: brokerReaderMock, stopping: stopping}
+
+	start := time.Now()
+	reader.commit(msg)
+	if elapsed := time.Since(start); elapsed >= commitRetryBackoff {
+		t.Errorf("a closed reader should not wait for the commit retry backoff, took %s", elapsed)
+	}
+
+	select {
+	case err := <-reader.Errors():
+		if readErr, ok := err.(*ReadError); !ok || readErr.Kind != CommitError {
+			t.Errorf("expected a commit error, got %v", err)
+		}
+	default:
+		t.Error("the failed commit should be reported when the reader is closed")
+	}
+	mockCtrl.Finish()
+}
+
+func TestCommitFailureReport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Partition: 1, Offset: 2}
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(errors.New("commit error"))
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithCommitFailurePolicy(CommitFailureReport)(&reader)
+	reader.commit(msg)
+
+	select {
+	case err := <-reader.Errors():
+		if readErr, ok := err.(*ReadError); !ok || readErr.Kind != CommitError || readErr.Message.Offset != 2 {
+			t.Errorf("expected a commit error of the message, got %v", err)
+		}
+	default:
+		t.Error("the failed commit should be reported right away")
+	}
+	mockCtrl.Finish()
+}
+
+func TestCommitFailurePanic(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Partition: 1, Offset: 2}
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(errors.New("commit error"))
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithCommitFailurePolicy(CommitFailurePanic)(&reader)
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("the failed commit should panic")
+		}
+		mockCtrl.Finish()
+	}()
+	reader.commit(msg)
+}
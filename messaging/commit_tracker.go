@@ -0,0 +1,167 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// partitionKey identifies a partition of a topic
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// trackedMessage is a fetched message waiting to be committed
+type trackedMessage struct {
+	msg  Message
+	done bool
+}
+
+// commitTracker keeps track of messages which are processed concurrently and commits them in the order they have been
+// fetched, so that a slow message never lets a later message of the same partition commit past it
+type commitTracker struct {
+	mu      sync.Mutex
+	pending map[partitionKey][]*trackedMessage
+	// committing holds the partitions with a commit in progress and the newest message to commit once it returned
+	committing map[partitionKey]*Message
+	commit     func(m Message)
+}
+
+// newCommitTracker returns a commitTracker calling commit for every message which is ready to be committed
+func newCommitTracker(commit func(m Message)) *commitTracker {
+	return &commitTracker{pending: map[partitionKey][]*trackedMessage{}, committing: map[partitionKey]*Message{}, commit: commit}
+}
+
+// add registers a fetched message, it has to be called in fetch order
+func (ct *commitTracker) add(m Message) *trackedMessage {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	tm := &trackedMessage{msg: m}
+	key := partitionKey{topic: m.Topic, partition: m.Partition}
+	ct.pending[key] = append(ct.pending[key], tm)
+	return tm
+}
+
+// done marks a message as processed and commits the newest message of the partition whose predecessors are all done.
+// The commit happens outside of the lock, so a slow or retried commit does not block messages completing meanwhile.
+func (ct *commitTracker) done(tm *trackedMessage) {
+	ct.mu.Lock()
+	tm.done = true
+	key := partitionKey{topic: tm.msg.Topic, partition: tm.msg.Partition}
+	pending := ct.pending[key]
+
+	n := 0
+	for n < len(pending) && pending[n].done {
+		n++
+	}
+	if n == 0 {
+		ct.mu.Unlock()
+		return
+	}
+
+	// committing an offset commits all previous offsets of the partition as well
+	m := pending[n-1].msg
+	ct.pending[key] = pending[n:]
+
+	// to keep commits of a partition monotonic only one commit per partition is in progress, it picks up the newer
+	// offset after returning
+	if _, ok := ct.committing[key]; ok {
+		ct.committing[key] = &m
+		ct.mu.Unlock()
+		return
+	}
+	ct.committing[key] = nil
+	ct.mu.Unlock()
+
+	for {
+		ct.commit(m)
+
+		ct.mu.Lock()
+		next := ct.committing[key]
+		if next == nil {
+			delete(ct.committing, key)
+			ct.mu.Unlock()
+			return
+		}
+		ct.committing[key] = nil
+		ct.mu.Unlock()
+		m = *next
+	}
+}
+
+// uncommitted reports whether a fetched message is waiting to be committed
+func (ct *commitTracker) uncommitted() bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	for _, pending := range ct.pending {
+		if len(pending) > 0 {
+			return true
+		}
+	}
+	return len(ct.committing) > 0
+}
+
+// commitBatcher collects messages which are ready to be committed and commits them together to save broker round-trips
+type commitBatcher struct {
+	mu      sync.Mutex
+	size    int
+	pending []Message
+	commit  func(msgs ...Message)
+}
+
+// newCommitBatcher returns a commitBatcher committing as soon as size messages have been collected, a size below 2
+// only commits on flush
+func newCommitBatcher(size int, commit func(msgs ...Message)) *commitBatcher {
+	return &commitBatcher{size: size, commit: commit}
+}
+
+// add collects a message ready to be committed, messages have to be added in commit order
+func (cb *commitBatcher) add(m Message) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.pending = append(cb.pending, m)
+	if cb.size > 1 && len(cb.pending) >= cb.size {
+		cb.flushLocked()
+	}
+}
+
+// flush commits all collected messages
+func (cb *commitBatcher) flush() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.flushLocked()
+}
+
+// flushLocked commits all collected messages, cb.mu has to be held
+func (cb *commitBatcher) flushLocked() {
+	if len(cb.pending) == 0 {
+		return
+	}
+	cb.commit(cb.pending...)
+	cb.pending = nil
+}
+
+// uncommitted reports whether collected messages are waiting to be committed
+func (cb *commitBatcher) uncommitted() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return len(cb.pending) > 0
+}
+
+// flushEvery flushes the collected messages periodically until ctx is cancelled
+func (cb *commitBatcher) flushEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cb.flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
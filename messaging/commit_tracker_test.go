@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommitTracker_OutOfOrder(t *testing.T) {
+	var committed []int64
+	var otherCommitted []int64
+	tracker := newCommitTracker(func(m Message) {
+		if m.Partition == 0 {
+			committed = append(committed, m.Offset)
+		} else {
+			otherCommitted = append(otherCommitted, m.Offset)
+		}
+	})
+
+	var tms []*trackedMessage
+	for i := 0; i < 4; i++ {
+		tms = append(tms, tracker.add(Message{Topic: "test", Partition: 0, Offset: int64(i)}))
+	}
+	other := tracker.add(Message{Topic: "test", Partition: 1, Offset: 0})
+
+	tracker.done(tms[2])
+	tracker.done(tms[1])
+	if len(committed) != 0 {
+		t.Errorf("nothing should be committed while offset 0 is in progress, got %v", committed)
+	}
+
+	tracker.done(other)
+	if len(otherCommitted) != 1 {
+		t.Errorf("partitions should be committed independently, got %v", otherCommitted)
+	}
+
+	tracker.done(tms[0])
+	tracker.done(tms[3])
+
+	exp := []int64{2, 3}
+	if len(committed) != len(exp) {
+		t.Fatalf("expected commits %v, got %v", exp, committed)
+	}
+	for i := range exp {
+		if committed[i] != exp[i] {
+			t.Error(expected(strconv.FormatInt(committed[i], 10), strconv.FormatInt(exp[i], 10)))
+		}
+	}
+}
+
+func TestCommitTracker_DoneWhileCommitting(t *testing.T) {
+	var mu sync.Mutex
+	var committed []Message
+	release := make(chan struct{})
+	tracker := newCommitTracker(func(m Message) {
+		// the first commit is slow, e.g. because it is retried
+		if m.Partition == 0 && m.Offset == 0 {
+			<-release
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		committed = append(committed, m)
+	})
+
+	first := tracker.add(Message{Topic: "test", Partition: 0, Offset: 0})
+	second := tracker.add(Message{Topic: "test", Partition: 0, Offset: 1})
+	third := tracker.add(Message{Topic: "test", Partition: 0, Offset: 2})
+	other := tracker.add(Message{Topic: "test", Partition: 1, Offset: 0})
+
+	committing := make(chan struct{})
+	go func() {
+		defer close(committing)
+		tracker.done(first)
+	}()
+	waitFor(t, func() bool {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		_, ok := tracker.committing[partitionKey{topic: "test", partition: 0}]
+		return ok
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		tracker.done(second)
+		tracker.done(third)
+		tracker.done(other)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("completing messages should not wait for a commit in progress")
+	}
+
+	mu.Lock()
+	if len(committed) != 1 || committed[0].Partition != 1 {
+		t.Errorf("expected only the other partition to be committed yet, got %v", committed)
+	}
+	mu.Unlock()
+	if !tracker.uncommitted() {
+		t.Error("a commit in progress should count as uncommitted")
+	}
+
+	close(release)
+	<-committing
+
+	// the offsets completed meanwhile are committed after the commit in progress, in a single commit
+	if len(committed) != 3 || committed[1].Offset != 0 || committed[2].Offset != 2 {
+		t.Errorf("expected offsets 0 and 2 of partition 0 to be committed in order, got %v", committed)
+	}
+	if tracker.uncommitted() {
+		t.Error("all messages should be committed")
+	}
+}
+
+func TestCommitBatcher_Size(t *testing.T) {
+	var batches [][]Message
+	batcher := newCommitBatcher(3, func(msgs ...Message) {
+		batches = append(batches, msgs)
+	})
+
+	for i := 0; i < 4; i++ {
+		batcher.add(Message{Topic: "test", Offset: int64(i)})
+	}
+	if len(batches) != 1 || len(batches[0]) != 3 {
+		t.Fatalf("expected one batch of 3 messages, got %v", batches)
+	}
+
+	batcher.flush()
+	if len(batches) != 2 || len(batches[1]) != 1 || batches[1][0].Offset != 3 {
+		t.Fatalf("expected flush to commit the remaining message, got %v", batches)
+	}
+
+	batcher.flush()
+	if len(batches) != 2 {
+		t.Errorf("flushing an empty batch should not commit, got %v", batches)
+	}
+}
@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// MessageIDHeader is the default header identifying a message for WithDedup
+const MessageIDHeader = "missy-message-id"
+
+// dedupCache remembers the ids of recently processed messages for a time window
+type dedupCache struct {
+	mu        sync.Mutex
+	window    time.Duration
+	header    string
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// newDedupCache returns a dedupCache remembering the ids read from header for window
+func newDedupCache(window time.Duration, header string) *dedupCache {
+	if header == "" {
+		header = MessageIDHeader
+	}
+	return &dedupCache{window: window, header: header, seen: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// id returns the id of a message, messages without the id header are never deduplicated
+func (dc *dedupCache) id(m Message) (string, bool) {
+	value, ok := m.GetHeader(dc.header)
+	if !ok || len(value) == 0 {
+		return "", false
+	}
+	return string(value), true
+}
+
+// contains reports whether a message with the same id has been processed within the window
+func (dc *dedupCache) contains(m Message) bool {
+	id, ok := dc.id(m)
+	if !ok {
+		return false
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	seenAt, ok := dc.seen[id]
+	return ok && time.Since(seenAt) <= dc.window
+}
+
+// add remembers the id of a processed message
+func (dc *dedupCache) add(m Message) {
+	id, ok := dc.id(m)
+	if !ok {
+		return
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	now := time.Now()
+	dc.seen[id] = now
+
+	// forget expired ids once per window to bound the memory used
+	if now.Sub(dc.lastSweep) > dc.window {
+		for id, seenAt := range dc.seen {
+			if now.Sub(seenAt) > dc.window {
+				delete(dc.seen, id)
+			}
+		}
+		dc.lastSweep = now
+	}
+}
+
+// withMessageID returns m with a random id in the MessageIDHeader unless it already has one. The headers of m are
+// copied, so the message passed by the caller is not changed.
+func withMessageID(m Message) Message {
+	if id, ok := m.GetHeader(MessageIDHeader); ok && len(id) > 0 {
+		return m
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// the message is still written, it just cannot be deduplicated
+		return m
+	}
+	m.Headers = append([]Header(nil), m.Headers...)
+	m.SetHeader(MessageIDHeader, []byte(hex.EncodeToString(id)))
+	return m
+}
@@ -0,0 +1,64 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	dc := newDedupCache(time.Minute, "")
+	msg := Message{Headers: []Header{{Key: MessageIDHeader, Value: []byte("id-1")}}}
+
+	if dc.contains(msg) {
+		t.Error("an unknown message should not be a duplicate")
+	}
+	dc.add(msg)
+	if !dc.contains(msg) {
+		t.Error("a processed message should be a duplicate within the window")
+	}
+
+	dc.seen["id-1"] = time.Now().Add(-2 * time.Minute)
+	if dc.contains(msg) {
+		t.Error("a message processed before the window should not be a duplicate")
+	}
+
+	dc.add(Message{})
+	if dc.contains(Message{}) {
+		t.Error("messages without id should never be duplicates")
+	}
+}
+
+func TestDedupCache_Sweep(t *testing.T) {
+	dc := newDedupCache(time.Minute, "id")
+	dc.seen["expired"] = time.Now().Add(-2 * time.Minute)
+	dc.lastSweep = time.Now().Add(-2 * time.Minute)
+
+	dc.add(Message{Headers: []Header{{Key: "id", Value: []byte("new")}}})
+
+	if _, ok := dc.seen["expired"]; ok {
+		t.Error("expired ids should be forgotten")
+	}
+	if _, ok := dc.seen["new"]; !ok {
+		t.Error("the added id should be remembered")
+	}
+}
+
+func TestWithMessageID(t *testing.T) {
+	m := Message{Key: []byte("key"), Headers: []Header{{Key: "trace-id", Value: []byte("1")}}}
+
+	identified := withMessageID(m)
+	id, ok := identified.GetHeader(MessageIDHeader)
+	if !ok || len(id) != 32 {
+		t.Errorf("expected a random message id, got %q", id)
+	}
+	if len(m.Headers) != 1 {
+		t.Errorf("the headers of the given message should not be changed, got %v", m.Headers)
+	}
+	if other, _ := withMessageID(m).GetHeader(MessageIDHeader); string(other) == string(id) {
+		t.Error("every message should get its own id")
+	}
+
+	if again, _ := withMessageID(identified).GetHeader(MessageIDHeader); string(again) != string(id) {
+		t.Error(expected(string(again), string(id)))
+	}
+}
@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Headers describing why a message has been sent to the dead letter queue. The key, value and other headers of the
+// message are kept unchanged.
+const (
+	// DLQOriginalTopicHeader is the topic the message has been read from
+	DLQOriginalTopicHeader = "missy-dlq-original-topic"
+	// DLQOriginalPartitionHeader is the partition the message has been read from
+	DLQOriginalPartitionHeader = "missy-dlq-original-partition"
+	// DLQOriginalOffsetHeader is the offset of the message in its original partition
+	DLQOriginalOffsetHeader = "missy-dlq-original-offset"
+	// DLQRetryCountHeader is the number of times the message has been retried
+	DLQRetryCountHeader = "missy-dlq-retry-count"
+	// DLQLastErrorHeader is the error returned by the last attempt to process the message
+	DLQLastErrorHeader = "missy-dlq-last-error"
+	// DLQFailedAtHeader is the time the message has been sent to the dead letter queue, in RFC 3339 format
+	DLQFailedAtHeader = "missy-dlq-failed-at"
+)
+
+// DLQCallback is called for a message whose retries are used up before it is written to the dead letter queue. m is
+// the message about to be written, including the DLQ headers, and err the error of the last attempt. Changes to m are
+// written, returning false drops the message instead of writing it.
+type DLQCallback func(m *Message, err error) bool
+
+// maxLastErrorLength is the maximum length in bytes of the DLQLastErrorHeader, longer errors are truncated
+const maxLastErrorLength = 1024
+
+// dlqHeaders are the headers added to messages sent to the dead letter queue
+var dlqHeaders = []string{DLQOriginalTopicHeader, DLQOriginalPartitionHeader, DLQOriginalOffsetHeader, DLQRetryCountHeader, DLQLastErrorHeader, DLQFailedAtHeader}
+
+// ErrNoDLQMessage is returned by ParseDLQMessage for messages which have not been sent to the DLQ by a missy reader
+var ErrNoDLQMessage = errors.New("message has not been sent to the dead letter queue by missy")
+
+// DLQRecord is a message read from the dead letter queue together with the reason it has been sent there
+type DLQRecord struct {
+	// Message is the message as it has been read from the original topic, without the DLQ headers
+	Message           Message
+	OriginalTopic     string
+	OriginalPartition int
+	OriginalOffset    int64
+	RetryCount        int
+	LastError         string
+	FailedAt          time.Time
+}
+
+// ParseDLQMessage reads the failure details of a message read from the dead letter queue
+func ParseDLQMessage(m Message) (DLQRecord, error) {
+	topic, ok := m.GetHeader(DLQOriginalTopicHeader)
+	if !ok {
+		return DLQRecord{}, ErrNoDLQMessage
+	}
+
+	record := DLQRecord{OriginalTopic: string(topic)}
+	if lastError, ok := m.GetHeader(DLQLastErrorHeader); ok {
+		record.LastError = string(lastError)
+	}
+
+	var err error
+	if record.OriginalPartition, err = strconv.Atoi(dlqHeader(m, DLQOriginalPartitionHeader)); err != nil {
+		return DLQRecord{}, fmt.Errorf("invalid %s header: %v", DLQOriginalPartitionHeader, err)
+	}
+	if record.OriginalOffset, err = strconv.ParseInt(dlqHeader(m, DLQOriginalOffsetHeader), 10, 64); err != nil {
+		return DLQRecord{}, fmt.Errorf("invalid %s header: %v", DLQOriginalOffsetHeader, err)
+	}
+	if record.RetryCount, err = strconv.Atoi(dlqHeader(m, DLQRetryCountHeader)); err != nil {
+		return DLQRecord{}, fmt.Errorf("invalid %s header: %v", DLQRetryCountHeader, err)
+	}
+	if record.FailedAt, err = time.Parse(time.RFC3339Nano, dlqHeader(m, DLQFailedAtHeader)); err != nil {
+		return DLQRecord{}, fmt.Errorf("invalid %s header: %v", DLQFailedAtHeader, err)
+	}
+
+	record.Message = m
+	record.Message.Headers = nil
+	for _, h := range m.Headers {
+		if !isDLQHeader(h.Key) {
+			record.Message.Headers = append(record.Message.Headers, h)
+		}
+	}
+	return record, nil
+}
+
+// dlqMessage returns the message written to the dead letter queue for a message which failed with err
+func dlqMessage(m Message, err error) Message {
+	msg := Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: append([]Header(nil), m.Headers...),
+	}
+	lastError := ""
+	if err != nil {
+		lastError = truncateError(err.Error())
+	}
+	if id := m.traceID(); id != "" {
+		msg.SetHeader(TraceIDHeader, []byte(id))
+	}
+	msg.SetHeader(DLQOriginalTopicHeader, []byte(m.Topic))
+	msg.SetHeader(DLQOriginalPartitionHeader, []byte(strconv.Itoa(m.Partition)))
+	msg.SetHeader(DLQOriginalOffsetHeader, []byte(strconv.FormatInt(m.Offset, 10)))
+	msg.SetHeader(DLQRetryCountHeader, []byte(strconv.Itoa(m.RetryCounter)))
+	msg.SetHeader(DLQLastErrorHeader, []byte(lastError))
+	msg.SetHeader(DLQFailedAtHeader, []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	return msg
+}
+
+// dlqHeader returns the value of a header as string, missing headers are empty
+func dlqHeader(m Message, key string) string {
+	value, _ := m.GetHeader(key)
+	return string(value)
+}
+
+// isDLQHeader reports whether key is one of the headers added when sending a message to the dead letter queue
+func isDLQHeader(key string) bool {
+	for _, h := range dlqHeaders {
+		if h == key {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateError cuts an error message to maxLastErrorLength bytes without splitting a UTF-8 character
+func truncateError(msg string) string {
+	const ellipsis = "..."
+	if len(msg) <= maxLastErrorLength {
+		return msg
+	}
+	cut := maxLastErrorLength - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + ellipsis
+}
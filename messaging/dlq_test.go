@@ -0,0 +1,166 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDLQMessage_RoundTrip(t *testing.T) {
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 3, Offset: 42, RetryCounter: 5, Headers: []Header{{Key: "trace-id", Value: []byte("abc")}}}
+
+	before := time.Now()
+	record, err := ParseDLQMessage(dlqMessage(msg, errors.New("downstream unavailable")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if record.OriginalTopic != "test" {
+		t.Error(expected(record.OriginalTopic, "test"))
+	}
+	if record.OriginalPartition != 3 {
+		t.Error(expected(strconv.Itoa(record.OriginalPartition), "3"))
+	}
+	if record.OriginalOffset != 42 {
+		t.Error(expected(strconv.FormatInt(record.OriginalOffset, 10), "42"))
+	}
+	if record.RetryCount != 5 {
+		t.Error(expected(strconv.Itoa(record.RetryCount), "5"))
+	}
+	if record.LastError != "downstream unavailable" {
+		t.Error(expected(record.LastError, "downstream unavailable"))
+	}
+	if record.FailedAt.Before(before.Add(-time.Second)) {
+		t.Errorf("unexpected failed at %v", record.FailedAt)
+	}
+	if string(record.Message.Key) != "key" || string(record.Message.Value) != "value" {
+		t.Errorf("key and value should be kept, got %s = %s", record.Message.Key, record.Message.Value)
+	}
+	if len(record.Message.Headers) != 2 || record.Message.Headers[0].Key != "trace-id" || record.Message.Headers[1].Key != TraceIDHeader {
+		t.Errorf("only the original headers and the trace id should be left, got %v", record.Message.Headers)
+	}
+}
+
+func TestParseDLQMessage_NoDLQMessage(t *testing.T) {
+	if _, err := ParseDLQMessage(Message{Value: []byte("value")}); err != ErrNoDLQMessage {
+		t.Errorf("expected ErrNoDLQMessage, got %v", err)
+	}
+}
+
+func TestParseDLQMessage_InvalidHeader(t *testing.T) {
+	m := dlqMessage(Message{Topic: "test"}, errors.New("error"))
+	m.SetHeader(DLQOriginalOffsetHeader, []byte("invalid"))
+
+	if _, err := ParseDLQMessage(m); err == nil {
+		t.Error("error was expected")
+	}
+}
+
+func TestDLQMessage_LastErrorOfMsgFunc(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("orders").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	r.Read(func(m Message) error {
+		return errors.Errorf("attempt %d: downstream unavailable", m.RetryCounter)
+	})
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+
+	lastError, ok := im.Messages("orders.dlq")[0].GetHeader(DLQLastErrorHeader)
+	if !ok {
+		t.Fatal("the DLQ message should have the last error header")
+	}
+	if string(lastError) != "attempt 5: downstream unavailable" {
+		t.Error(expected(string(lastError), "attempt 5: downstream unavailable"))
+	}
+}
+
+func TestDLQMessage_TruncatesLongErrors(t *testing.T) {
+	long := strings.Repeat("ä", maxLastErrorLength)
+
+	lastError, _ := dlqMessage(Message{Topic: "test"}, errors.New(long)).GetHeader(DLQLastErrorHeader)
+
+	if len(lastError) > maxLastErrorLength {
+		t.Errorf("the error should be truncated to %d bytes, got %d", maxLastErrorLength, len(lastError))
+	}
+	if !utf8.Valid(lastError) || !strings.HasSuffix(string(lastError), "...") || !strings.HasPrefix(long, strings.TrimSuffix(string(lastError), "...")) {
+		t.Errorf("the error should be cut at a character boundary, got %q", lastError)
+	}
+}
+
+func TestWithDLQCallback(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("alerts").Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	m := getReaderMetrics()
+	m.dlq.Reset()
+
+	failure := fmt.Errorf("invalid payload: %w", ErrSendToDLQ)
+	var called []error
+	r := im.NewReader("group", "alerts", WithDLQCallback(func(m *Message, err error) bool {
+		called = append(called, err)
+		if topic, _ := m.GetHeader(DLQOriginalTopicHeader); string(topic) != "alerts" {
+			t.Errorf("expected the DLQ headers to be set before the callback, got topic %q", topic)
+		}
+		m.SetHeader("paged", []byte("true"))
+		return true
+	}))
+	defer r.Close()
+
+	if err := r.ReadN(context.Background(), 1, func(m Message) error { return failure }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(called) != 1 || called[0] != failure {
+		t.Errorf("expected the callback to be called once with the error, got %v", called)
+	}
+	dlq := im.Messages("alerts.dlq")
+	if len(dlq) != 1 {
+		t.Fatalf("expected the message to be written to the DLQ, got %d messages", len(dlq))
+	}
+	if paged, _ := dlq[0].GetHeader("paged"); string(paged) != "true" {
+		t.Error("the changes of the callback should be written to the DLQ")
+	}
+	if v := testutil.ToFloat64(m.dlq.WithLabelValues("alerts", "0")); v != 1 {
+		t.Errorf("expected 1 message in missy_messaging_dlq_total, got %v", v)
+	}
+}
+
+func TestWithDLQCallback_Suppress(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("suppressed").Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	m := getReaderMetrics()
+	m.dlq.Reset()
+
+	r := im.NewReader("group", "suppressed", WithDLQCallback(func(m *Message, err error) bool { return false }))
+	defer r.Close()
+
+	if err := r.ReadN(context.Background(), 1, func(m Message) error { return ErrSendToDLQ }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(im.Messages("suppressed.dlq")) != 0 {
+		t.Error("a message dropped by the callback should not be written to the DLQ")
+	}
+	if lag := im.lag("group", "suppressed"); lag != 0 {
+		t.Errorf("a message dropped by the callback should be committed, got lag %d", lag)
+	}
+	if v := testutil.ToFloat64(m.dlq.WithLabelValues("suppressed", "0")); v != 0 {
+		t.Errorf("expected no message in missy_messaging_dlq_total, got %v", v)
+	}
+}
@@ -0,0 +1,77 @@
+package messaging
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// errorsBufferSize is the number of errors buffered on the channel returned by Reader.Errors
+const errorsBufferSize = 100
+
+// ErrorKind tells in which step of consuming a message an error happened
+type ErrorKind int
+
+const (
+	// FetchError is an error fetching a message from the broker
+	FetchError ErrorKind = iota
+	// CommitError is an error committing a message to the broker
+	CommitError
+	// RetryError is an error re-enqueuing a failed message for retry
+	RetryError
+	// DLQError is an error writing a message to the dead letter queue
+	DLQError
+)
+
+// String returns a human readable name of the error kind
+func (k ErrorKind) String() string {
+	switch k {
+	case FetchError:
+		return "fetch"
+	case CommitError:
+		return "commit"
+	case RetryError:
+		return "retry"
+	case DLQError:
+		return "dlq"
+	}
+	return "unknown"
+}
+
+// ReadError is emitted on the Reader.Errors channel. Message is nil for fetch errors.
+type ReadError struct {
+	Kind    ErrorKind
+	Message *Message
+	Err     error
+}
+
+// Error implements the error interface
+func (e *ReadError) Error() string {
+	if e.Message == nil {
+		return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s error for message [%s] %v/%v: %v", e.Kind, e.Message.Topic, e.Message.Partition, e.Message.Offset, e.Err)
+}
+
+// Errors returns a channel emitting a ReadError for every failed fetch, commit, retry or DLQ write. It is buffered
+// and errors are dropped if nobody reads from it, so reading messages is never blocked.
+func (mr *missyReader) Errors() <-chan error {
+	return mr.errorChannel()
+}
+
+// errorChannel returns the errors channel and creates it on first use
+func (mr *missyReader) errorChannel() chan error {
+	mr.errorsOnce.Do(func() {
+		mr.errs = make(chan error, errorsBufferSize)
+	})
+	return mr.errs
+}
+
+// reportError emits an error on the errors channel or drops it if the channel is full
+func (mr *missyReader) reportError(kind ErrorKind, m *Message, err error) {
+	select {
+	case mr.errorChannel() <- &ReadError{Kind: kind, Message: m, Err: err}:
+	default:
+		dropped := atomic.AddUint64(&mr.droppedErrors, 1)
+		mr.getLogger().Debugf("# messaging # errors channel is full, dropped %d errors so far", dropped)
+	}
+}
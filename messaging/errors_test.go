@@ -0,0 +1,91 @@
+package messaging
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+)
+
+func TestMissyReader_ErrorsOnCommit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 1, Offset: 2}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	// the commit is retried by default before the error is emitted
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Times(1 + commitRetries).Return(errors.New("commit error"))
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := reader.ReadContext(ctx, func(msg Message) error { return nil }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case err := <-reader.Errors():
+		readErr, ok := err.(*ReadError)
+		if !ok {
+			t.Fatalf("expected a *ReadError, got %T", err)
+		}
+		if readErr.Kind != CommitError {
+			t.Error(expected(readErr.Kind.String(), CommitError.String()))
+		}
+		if readErr.Message == nil || readErr.Message.Offset != 2 {
+			t.Error("the error should carry the message which could not be committed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no error was emitted for the failed commit")
+	}
+}
+
+func TestMissyReader_ErrorsOnFetch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, errors.New("fetch error"))
+	// the reader may be closed during the fetch backoff, before fetching again
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF).AnyTimes()
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	defer reader.Close()
+
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case err := <-reader.Errors():
+		if readErr, ok := err.(*ReadError); !ok || readErr.Kind != FetchError || readErr.Message != nil {
+			t.Errorf("expected a fetch error without message, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("no error was emitted for the failed fetch")
+	}
+}
+
+func TestMissyReader_ErrorsDropWhenFull(t *testing.T) {
+	reader := missyReader{}
+
+	for i := 0; i < errorsBufferSize+3; i++ {
+		reader.reportError(CommitError, &Message{}, errors.New("error"))
+	}
+
+	if n := len(reader.Errors()); n != errorsBufferSize {
+		t.Error(expected(strconv.Itoa(n), strconv.Itoa(errorsBufferSize)))
+	}
+	if reader.droppedErrors != 3 {
+		t.Error(expected(strconv.FormatUint(reader.droppedErrors, 10), "3"))
+	}
+}
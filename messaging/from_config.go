@@ -0,0 +1,140 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/microdevs/missy/config"
+)
+
+// defaultConfigWriteRetryBackoff is the backoff of the write retries configured with <prefix>.retries
+const defaultConfigWriteRetryBackoff = 100 * time.Millisecond
+
+// NewReaderFromConfig creates a Reader from the missy config values with the internal names <prefix>.brokers (comma
+// separated), <prefix>.topic and <prefix>.group, which are required, and the optional <prefix>.retries,
+// <prefix>.dlq.topic, <prefix>.minbytes and <prefix>.maxbytes. An error lists all missing required values. opts are
+// applied after the config values.
+func NewReaderFromConfig(prefix string, opts ...ReaderOption) (Reader, error) {
+	return newReaderFromConfig(config.GetInstance(), prefix, opts...)
+}
+
+// NewWriterFromConfig creates a Writer from the missy config values with the internal names <prefix>.brokers (comma
+// separated), which is required, and the optional <prefix>.topic and <prefix>.retries. Without topic a multi topic
+// writer is created. opts are applied after the config values.
+func NewWriterFromConfig(prefix string, opts ...WriterOption) (Writer, error) {
+	return newWriterFromConfig(config.GetInstance(), prefix, opts...)
+}
+
+// newReaderFromConfig creates a Reader from the values of c
+func newReaderFromConfig(c *config.Config, prefix string, opts ...ReaderOption) (Reader, error) {
+	values := configValues{c: c, prefix: prefix}
+	brokers := values.required("brokers")
+	topic := values.required("topic")
+	groupID := values.required("group")
+	if err := values.missingErr(); err != nil {
+		return nil, err
+	}
+
+	var configOpts []ReaderOption
+	if retries, ok, err := values.int("retries"); err != nil {
+		return nil, err
+	} else if ok {
+		if retries < InfiniteRetries {
+			return nil, fmt.Errorf("invalid messaging config value %s.retries: must not be negative or -1 for infinite retries", prefix)
+		}
+		configOpts = append(configOpts, WithRetries(retries))
+	}
+	if dlqTopic, ok := values.optional("dlq.topic"); ok && dlqTopic != "" {
+		configOpts = append(configOpts, WithDLQTopic(dlqTopic))
+	}
+	if minBytes, ok, err := values.int("minbytes"); err != nil {
+		return nil, err
+	} else if ok {
+		configOpts = append(configOpts, WithMinBytes(minBytes))
+	}
+	if maxBytes, ok, err := values.int("maxbytes"); err != nil {
+		return nil, err
+	} else if ok {
+		configOpts = append(configOpts, WithMaxBytes(maxBytes))
+	}
+
+	return NewReader(splitBrokers(brokers), groupID, topic, append(configOpts, opts...)...), nil
+}
+
+// newWriterFromConfig creates a Writer from the values of c
+func newWriterFromConfig(c *config.Config, prefix string, opts ...WriterOption) (Writer, error) {
+	values := configValues{c: c, prefix: prefix}
+	brokers := values.required("brokers")
+	if err := values.missingErr(); err != nil {
+		return nil, err
+	}
+
+	var configOpts []WriterOption
+	if retries, ok, err := values.int("retries"); err != nil {
+		return nil, err
+	} else if ok {
+		if retries < 0 {
+			return nil, fmt.Errorf("invalid messaging config value %s.retries: must not be negative", prefix)
+		}
+		configOpts = append(configOpts, WithWriteRetries(retries, defaultConfigWriteRetryBackoff))
+	}
+
+	topic, _ := values.optional("topic")
+	return NewWriter(splitBrokers(brokers), topic, append(configOpts, opts...)...), nil
+}
+
+// configValues reads the messaging values under a prefix from the missy config and collects missing required ones
+type configValues struct {
+	c       *config.Config
+	prefix  string
+	missing []string
+}
+
+// optional returns the value of <prefix>.<key> and whether it is configured
+func (cv *configValues) optional(key string) (string, bool) {
+	return cv.c.Lookup(cv.prefix + "." + key)
+}
+
+// required returns the value of <prefix>.<key> and remembers it as missing if it is not configured or empty
+func (cv *configValues) required(key string) string {
+	value, ok := cv.optional(key)
+	if !ok || value == "" {
+		cv.missing = append(cv.missing, cv.prefix+"."+key)
+	}
+	return value
+}
+
+// int returns the number in <prefix>.<key>, values which are not configured or empty are skipped
+func (cv *configValues) int(key string) (int, bool, error) {
+	value, ok := cv.optional(key)
+	if !ok || value == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid messaging config value %s.%s: %q is not a number", cv.prefix, key, value)
+	}
+	return n, true, nil
+}
+
+// missingErr returns an error listing the missing required values, nil if none is missing
+func (cv *configValues) missingErr() error {
+	if len(cv.missing) == 0 {
+		return nil
+	}
+	return errors.New("missing messaging config values: " + strings.Join(cv.missing, ", "))
+}
+
+// splitBrokers splits a comma separated list of brokers
+func splitBrokers(brokers string) []string {
+	var list []string
+	for _, b := range strings.Split(brokers, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			list = append(list, b)
+		}
+	}
+	return list
+}
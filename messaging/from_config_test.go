@@ -0,0 +1,85 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/microdevs/missy/config"
+)
+
+// testConfig returns a missy config with the given values by internal name
+func testConfig(values map[string]string) *config.Config {
+	c := &config.Config{}
+	for name, value := range values {
+		c.Environment = append(c.Environment, config.EnvParameter{InternalName: name, Value: value})
+	}
+	return c
+}
+
+func TestNewReaderFromConfig(t *testing.T) {
+	c := testConfig(map[string]string{
+		"orders.brokers":   "kafka-1:9092, kafka-2:9092",
+		"orders.topic":     "orders",
+		"orders.group":     "billing",
+		"orders.retries":   "2",
+		"orders.dlq.topic": "orders-failed",
+		"orders.minbytes":  "1",
+		"orders.maxbytes":  "1000",
+	})
+
+	r, err := newReaderFromConfig(c, "orders")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	mr := r.(*missyReader)
+
+	if strings.Join(mr.brokers, ",") != "kafka-1:9092,kafka-2:9092" {
+		t.Error(expected(strings.Join(mr.brokers, ","), "kafka-1:9092,kafka-2:9092"))
+	}
+	if mr.topic != "orders" || mr.groupID != "billing" {
+		t.Errorf("expected topic orders and group billing, got %s and %s", mr.topic, mr.groupID)
+	}
+	if mr.numOfRetries != 2 {
+		t.Errorf("expected 2 retries, got %d", mr.numOfRetries)
+	}
+	if mr.dlqTopic != "orders-failed" {
+		t.Error(expected(mr.dlqTopic, "orders-failed"))
+	}
+	if mr.config.MinBytes != 1 || mr.config.MaxBytes != 1000 {
+		t.Errorf("expected min bytes 1 and max bytes 1000, got %d and %d", mr.config.MinBytes, mr.config.MaxBytes)
+	}
+}
+
+func TestNewReaderFromConfig_Missing(t *testing.T) {
+	c := testConfig(map[string]string{"orders.topic": "orders", "orders.group": ""})
+
+	_, err := newReaderFromConfig(c, "orders")
+	if err == nil || err.Error() != "missing messaging config values: orders.brokers, orders.group" {
+		t.Errorf("expected the missing values to be listed, got %v", err)
+	}
+}
+
+func TestNewReaderFromConfig_Invalid(t *testing.T) {
+	c := testConfig(map[string]string{"orders.brokers": "kafka:9092", "orders.topic": "orders", "orders.group": "billing", "orders.maxbytes": "1MB"})
+
+	if _, err := newReaderFromConfig(c, "orders"); err == nil || !strings.Contains(err.Error(), "orders.maxbytes") {
+		t.Errorf("expected an error for the invalid value, got %v", err)
+	}
+}
+
+func TestNewWriterFromConfig(t *testing.T) {
+	c := testConfig(map[string]string{"events.brokers": "kafka:9092", "events.topic": "events", "events.retries": "3"})
+
+	w, err := newWriterFromConfig(c, "events")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	mw := w.(*missyWriter)
+	if mw.topic != "events" || mw.writeRetries != 3 {
+		t.Errorf("expected topic events with 3 retries, got %s with %d", mw.topic, mw.writeRetries)
+	}
+
+	if _, err := newWriterFromConfig(testConfig(nil), "events"); err == nil || err.Error() != "missing messaging config values: events.brokers" {
+		t.Errorf("expected the missing brokers to be listed, got %v", err)
+	}
+}
@@ -0,0 +1,473 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/microdevs/missy/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// InMemory is a broker which keeps messages in memory, for tests of code using Reader and Writer without Kafka. Readers
+// and writers created from the same InMemory share its topics, every topic has a single partition 0. The readers are
+// regular missy readers, so retries, the DLQ, commits and the other reader options behave like with Kafka.
+type InMemory struct {
+	mu      sync.Mutex
+	topics  map[string][]kafka.Message
+	offsets map[string]int64
+	// written is closed and replaced whenever messages are written, fetching readers wait for it
+	written chan struct{}
+}
+
+// NewInMemory returns an empty in-memory broker
+func NewInMemory() *InMemory {
+	return &InMemory{
+		topics:  make(map[string][]kafka.Message),
+		offsets: make(map[string]int64),
+		written: make(chan struct{}),
+	}
+}
+
+// NewReader returns a Reader of topic for the consumer group groupID. Retried messages are written back to topic and
+// failed messages to "<topic>.dlq" of the same InMemory unless other writers are passed as options.
+func (im *InMemory) NewReader(groupID string, topic string, opts ...ReaderOption) Reader {
+	mr := &missyReader{
+		groupID:      groupID,
+		topic:        topic,
+		config:       kafka.ReaderConfig{GroupID: groupID, Topic: topic},
+		numOfRetries: numOfRetriesFromEnv(),
+		writers:      make(map[string]Writer),
+		dlqWriters:   make(map[string]Writer),
+	}
+
+	for _, opt := range opts {
+		opt(mr)
+	}
+
+	if topic == "" || len(mr.config.GroupTopics) > 0 {
+		log.Panicf("# messaging # invalid in-memory reader config: a single Topic is required")
+	}
+	mr.validateProcessing()
+
+	mr.brokerReader = &inMemoryBrokerReader{im: im, group: groupID, topic: topic, start: mr.config.StartOffset, closed: make(chan struct{})}
+	mr.seek = func(ctx context.Context, partition int, offset int64) error {
+		im.seek(groupID, topic, offset)
+		return nil
+	}
+	mr.seekTime = func(ctx context.Context, t time.Time) error {
+		im.seekTime(groupID, topic, t)
+		return nil
+	}
+	mr.fetchLag = func(ctx context.Context) (map[int]int64, error) {
+		return map[int]int64{0: im.lag(groupID, topic)}, nil
+	}
+	mr.peek = func(ctx context.Context, n int) ([]Message, error) {
+		return im.peek(groupID, topic, n), nil
+	}
+
+	mr.newWriter = func(topic string) Writer { return im.NewWriter(topic, mr.writerLogger()) }
+	if !mr.writerInjected {
+		mr.writer = newLazyWriter(mr.newWriter, topic)
+	}
+	if !mr.dlqDisabled && !mr.dlqWriterInjected {
+		if mr.dlqTopic == "" {
+			mr.dlqTopic = topic + ".dlq"
+		}
+		mr.dlqWriter = newLazyWriter(mr.newWriter, mr.dlqTopic)
+	}
+
+	return &inMemoryReader{mr}
+}
+
+// NewPartitionReader returns a Reader of topic without a consumer group like NewPartitionReader, its position is only
+// kept by the reader. InMemory topics have a single partition 0.
+func (im *InMemory) NewPartitionReader(topic string, partition int, opts ...ReaderOption) Reader {
+	if partition != 0 {
+		log.Panicf("# messaging # invalid in-memory partition %d: topics have a single partition 0", partition)
+	}
+
+	r := im.NewReader("", topic, opts...).(*inMemoryReader)
+	br := r.brokerReader.(*inMemoryBrokerReader)
+	r.manualCommit = true
+	r.seek = func(ctx context.Context, p int, offset int64) error {
+		if p != partition {
+			return fmt.Errorf("cannot set the offset of partition %d, the reader reads partition %d", p, partition)
+		}
+		br.setNext(offset)
+		return nil
+	}
+	r.seekTime = func(ctx context.Context, t time.Time) error {
+		br.setNextAt(t)
+		return nil
+	}
+	r.fetchLag = func(ctx context.Context) (map[int]int64, error) {
+		return map[int]int64{partition: br.lag()}, nil
+	}
+	r.peek = func(ctx context.Context, n int) ([]Message, error) {
+		return br.peek(n), nil
+	}
+	return r
+}
+
+// NewWriter returns a Writer of topic, an empty topic returns a multi topic writer
+func (im *InMemory) NewWriter(topic string, opts ...WriterOption) Writer {
+	mw := &missyWriter{
+		topic:  topic,
+		config: kafka.WriterConfig{Topic: topic},
+	}
+
+	for _, opt := range opts {
+		opt(mw)
+	}
+
+	bw := &inMemoryBrokerWriter{im: im, topic: topic}
+	if mw.config.Async {
+		mw.pending = &pendingWrites{}
+		bw.completion = mw.completed
+	}
+	mw.brokerWriter = bw
+	mw.produce = func(ctx context.Context, m Message) (Message, error) {
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
+		}
+		written := []kafka.Message{{Topic: m.Topic, Key: m.Key, Value: m.Value, Headers: toKafkaHeaders(m)}}
+		im.write(written)
+		return fromKafkaMessage(written[0]), nil
+	}
+
+	return &inMemoryWriter{mw}
+}
+
+// NewDLQReprocessor returns a DLQReprocessor reading dlqTopic with the consumer group "<dlqTopic>.reprocessor" and
+// writing to targetTopic of the same InMemory
+func (im *InMemory) NewDLQReprocessor(dlqTopic string, targetTopic string) *DLQReprocessor {
+	if dlqTopic == "" || targetTopic == "" {
+		log.Panicf("# messaging # invalid in-memory DLQ reprocessor config: a DLQ topic and a target topic are required")
+	}
+
+	group := dlqTopic + ".reprocessor"
+	return &DLQReprocessor{
+		dlqTopic:     dlqTopic,
+		targetTopic:  targetTopic,
+		brokerReader: &inMemoryBrokerReader{im: im, group: group, topic: dlqTopic, closed: make(chan struct{})},
+		writer:       im.NewWriter(targetTopic),
+		wait:         peekWait,
+	}
+}
+
+// Messages returns all messages written to topic, including the messages which have already been read
+func (im *InMemory) Messages(topic string) []Message {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	msgs := make([]Message, len(im.topics[topic]))
+	for i, m := range im.topics[topic] {
+		msgs[i] = fromKafkaMessage(m)
+	}
+	return msgs
+}
+
+// write appends msgs to their topics, sets their offsets and wakes up the waiting readers
+func (im *InMemory) write(msgs []kafka.Message) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	for i := range msgs {
+		m := &msgs[i]
+		m.Partition = 0
+		m.Offset = int64(len(im.topics[m.Topic]))
+		if m.Time.IsZero() {
+			m.Time = time.Now()
+		}
+		im.topics[m.Topic] = append(im.topics[m.Topic], *m)
+	}
+	close(im.written)
+	im.written = make(chan struct{})
+}
+
+// committed returns the committed offset of the group, start applies if the group has not committed yet
+func (im *InMemory) committed(group string, topic string, start int64) int64 {
+	if offset, ok := im.offsets[group+"\x00"+topic]; ok {
+		return offset
+	}
+	if start == kafka.LastOffset {
+		return int64(len(im.topics[topic]))
+	}
+	return 0
+}
+
+// commit moves the committed offset of the group forward to the offset after m
+func (im *InMemory) commit(group string, m Message) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	key := group + "\x00" + m.Topic
+	if offset, ok := im.offsets[key]; !ok || m.Offset+1 > offset {
+		im.offsets[key] = m.Offset + 1
+	}
+}
+
+// seek sets the committed offset of the group, kafka.FirstOffset and kafka.LastOffset are resolved like by Kafka
+func (im *InMemory) seek(group string, topic string, offset int64) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	switch offset {
+	case kafka.FirstOffset:
+		offset = 0
+	case kafka.LastOffset:
+		offset = int64(len(im.topics[topic]))
+	}
+	im.offsets[group+"\x00"+topic] = offset
+}
+
+// seekTime sets the committed offset of the group to the first message written at or after t, or to the end of the
+// topic if there is none
+func (im *InMemory) seekTime(group string, topic string, t time.Time) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	im.offsets[group+"\x00"+topic] = im.offsetAt(topic, t)
+}
+
+// offsetAt returns the offset of the first message written at or after t, or the end of the topic if there is none.
+// im.mu has to be held.
+func (im *InMemory) offsetAt(topic string, t time.Time) int64 {
+	for i, m := range im.topics[topic] {
+		if !m.Time.Before(t) {
+			return int64(i)
+		}
+	}
+	return int64(len(im.topics[topic]))
+}
+
+// lag returns the number of messages the group has not committed yet
+func (im *InMemory) lag(group string, topic string) int64 {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	return int64(len(im.topics[topic])) - im.committed(group, topic, kafka.FirstOffset)
+}
+
+// peek returns up to n messages after the committed offset of the group
+func (im *InMemory) peek(group string, topic string, n int) []Message {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	var msgs []Message
+	for _, m := range im.topics[topic][im.committed(group, topic, kafka.FirstOffset):] {
+		if len(msgs) == n {
+			break
+		}
+		msgs = append(msgs, fromKafkaMessage(m))
+	}
+	return msgs
+}
+
+// inMemoryReader is a missy reader on an InMemory broker, it only differs in not needing brokers to ping
+type inMemoryReader struct {
+	*missyReader
+}
+
+// Ping only fails if the reader has been closed
+func (r *inMemoryReader) Ping(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrReaderClosed
+	}
+	return nil
+}
+
+// inMemoryWriter is a missy writer on an InMemory broker, it only differs in not needing brokers to ping
+type inMemoryWriter struct {
+	*missyWriter
+}
+
+// Ping always succeeds
+func (w *inMemoryWriter) Ping(ctx context.Context) error {
+	return nil
+}
+
+// inMemoryBrokerReader fetches the messages of a topic from an InMemory broker to fulfill BrokerReader interface
+type inMemoryBrokerReader struct {
+	im    *InMemory
+	group string
+	topic string
+	start int64
+
+	once      sync.Once
+	next      int64
+	fetches   int64
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// FetchMessage waits for the next message of the topic, it returns io.EOF once the reader has been closed
+func (r *inMemoryBrokerReader) FetchMessage(ctx context.Context) (Message, error) {
+	atomic.AddInt64(&r.fetches, 1)
+	for {
+		r.im.mu.Lock()
+		msgs := r.im.topics[r.topic]
+		if r.position() < int64(len(msgs)) {
+			m := msgs[r.next]
+			r.next++
+			r.im.mu.Unlock()
+			return fromKafkaMessage(m), nil
+		}
+		written := r.im.written
+		r.im.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-r.closed:
+			return Message{}, io.EOF
+		case <-written:
+		}
+	}
+}
+
+// ReadMessage fetches and commits the next message
+func (r *inMemoryBrokerReader) ReadMessage(ctx context.Context) (Message, error) {
+	m, err := r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return m, r.CommitMessages(ctx, m)
+}
+
+// CommitMessages commits the offsets of msgs for the consumer group, like kafka-go it fails without a group
+func (r *inMemoryBrokerReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	if r.group == "" {
+		return errNoGroup
+	}
+	for _, m := range msgs {
+		r.im.commit(r.group, m)
+	}
+	return nil
+}
+
+// setNext sets the offset of the next message fetched, kafka.FirstOffset and kafka.LastOffset are resolved like by
+// Kafka and offsets after the end of the topic are moved to the end
+func (r *inMemoryBrokerReader) setNext(offset int64) {
+	r.im.mu.Lock()
+	defer r.im.mu.Unlock()
+
+	switch offset {
+	case kafka.FirstOffset:
+		offset = 0
+	case kafka.LastOffset:
+		offset = int64(len(r.im.topics[r.topic]))
+	}
+	if end := int64(len(r.im.topics[r.topic])); offset > end {
+		offset = end
+	}
+	r.once.Do(func() {})
+	r.next = offset
+}
+
+// setNextAt sets the next message fetched to the first message written at or after t
+func (r *inMemoryBrokerReader) setNextAt(t time.Time) {
+	r.im.mu.Lock()
+	defer r.im.mu.Unlock()
+
+	r.once.Do(func() {})
+	r.next = r.im.offsetAt(r.topic, t)
+}
+
+// position returns the offset of the next message fetched, im.mu has to be held
+func (r *inMemoryBrokerReader) position() int64 {
+	r.once.Do(func() { r.next = r.im.committed(r.group, r.topic, r.start) })
+	return r.next
+}
+
+// lag returns the number of messages after the position of the reader
+func (r *inMemoryBrokerReader) lag() int64 {
+	r.im.mu.Lock()
+	defer r.im.mu.Unlock()
+
+	return int64(len(r.im.topics[r.topic])) - r.position()
+}
+
+// peek returns up to n messages after the position of the reader
+func (r *inMemoryBrokerReader) peek(n int) []Message {
+	r.im.mu.Lock()
+	defer r.im.mu.Unlock()
+
+	var msgs []Message
+	for _, m := range r.im.topics[r.topic][r.position():] {
+		if len(msgs) == n {
+			break
+		}
+		msgs = append(msgs, fromKafkaMessage(m))
+	}
+	return msgs
+}
+
+// Stats returns the stats of the topic the reader reads, the fetches are counted since the previous call like by kafka-go
+func (r *inMemoryBrokerReader) Stats() kafka.ReaderStats {
+	return kafka.ReaderStats{Topic: r.topic, Partition: "0", Fetches: atomic.SwapInt64(&r.fetches, 0), Lag: r.im.lag(r.group, r.topic)}
+}
+
+// Close stops waiting fetches
+func (r *inMemoryBrokerReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+
+// inMemoryBrokerWriter writes messages to an InMemory broker to fulfill BrokerWriter interface
+type inMemoryBrokerWriter struct {
+	im         *InMemory
+	topic      string
+	completion func(msgs []kafka.Message, err error)
+
+	mu    sync.Mutex
+	stats kafka.WriterStats
+}
+
+// WriteMessages writes msgs to the topic of the writer or to their own topic for a multi topic writer
+func (w *inMemoryBrokerWriter) WriteMessages(ctx context.Context, msgs ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	kafkaMessages := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		if w.topic != "" {
+			m.Topic = w.topic
+		}
+		kafkaMessages[i] = kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Headers: toKafkaHeaders(m)}
+	}
+	w.im.write(kafkaMessages)
+
+	w.mu.Lock()
+	w.stats.Writes++
+	w.stats.Messages += int64(len(msgs))
+	w.mu.Unlock()
+
+	if w.completion != nil {
+		w.completion(kafkaMessages, nil)
+	}
+	return nil
+}
+
+// Stats returns the number of writes and messages written
+func (w *inMemoryBrokerWriter) Stats() kafka.WriterStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.Topic = w.topic
+	return stats
+}
+
+// Close does nothing, messages are written synchronously
+func (w *inMemoryBrokerWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,278 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// waitFor polls cond until it is true or the timeout expires
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInMemory_WriteAndRead(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Message, 1)
+	go r.ReadContext(ctx, func(m Message) error {
+		received <- m
+		return nil
+	})
+
+	if err := w.WriteWithHeaders([]byte("key"), []byte("value"), Header{Key: "source", Value: []byte("test")}); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	select {
+	case m := <-received:
+		if string(m.Key) != "key" || string(m.Value) != "value" || m.Topic != "orders" {
+			t.Errorf("unexpected message %+v", m)
+		}
+		if source, _ := m.GetHeader("source"); string(source) != "test" {
+			t.Errorf("expected the header to be delivered, got %q", source)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message was not delivered")
+	}
+
+	waitFor(t, func() bool { return im.lag("group", "orders") == 0 })
+}
+
+func TestInMemory_RetryAndDLQ(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var retryCounters []int
+	go r.ReadContext(ctx, func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		retryCounters = append(retryCounters, m.RetryCounter)
+		return errors.New("failed")
+	})
+
+	if err := w.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(retryCounters, []int{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("expected the message to be retried 5 times, got retry counters %v", retryCounters)
+	}
+	if dlq := im.Messages("orders.dlq")[0]; string(dlq.Value) != "value" {
+		t.Errorf("expected the failed message in the DLQ, got %+v", dlq)
+	}
+}
+
+func TestInMemory_DLQWriterIsCreatedOnFirstUse(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+	dlqWriter := r.(*inMemoryReader).dlqWriter.(*lazyWriter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go r.ReadContext(ctx, func(m Message) error {
+		if string(m.Value) == "fail" {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	if err := w.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	waitFor(t, func() bool { return im.lag("group", "orders") == 0 })
+
+	if dlqWriter.created() != nil {
+		t.Error("no DLQ writer should be created while all messages succeed")
+	}
+
+	if err := w.Write([]byte("key"), []byte("fail")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+
+	if dlqWriter.created() == nil {
+		t.Error("the DLQ writer should be created for the failed message")
+	}
+}
+
+func TestInMemory_Filter(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("filtered")
+	for i := 0; i < 10; i++ {
+		if err := w.Write(nil, []byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	m := getReaderMetrics()
+	m.filtered.Reset()
+
+	even := func(m Message) bool {
+		i, _ := strconv.Atoi(string(m.Value))
+		return i%2 == 0
+	}
+	r := im.NewReader("group", "filtered", WithFilter(even))
+	defer r.Close()
+
+	var mu sync.Mutex
+	var values []string
+	if err := r.Read(func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, string(m.Value))
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// filtered messages are committed as well
+	waitFor(t, func() bool { return im.lag("group", "filtered") == 0 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(values, []string{"0", "2", "4", "6", "8"}) {
+		t.Errorf("expected only the messages passing the filter to be handled, got %v", values)
+	}
+	if v := testutil.ToFloat64(m.filtered.WithLabelValues("filtered", "0")); v != 5 {
+		t.Errorf("expected 5 filtered messages, got %v", v)
+	}
+	if len(im.Messages("filtered.dlq")) != 0 {
+		t.Error("filtered messages should not be sent to the DLQ")
+	}
+}
+
+func TestInMemory_CommittedMessagesAreNotReadAgain(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for _, v := range []string{"1", "2"} {
+		if err := w.Write(nil, []byte(v)); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	read := func(wait func(values []string) bool) []string {
+		r := im.NewReader("group", "orders")
+
+		var mu sync.Mutex
+		var values []string
+		r.Read(func(m Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			values = append(values, string(m.Value))
+			return nil
+		})
+		waitFor(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return wait(values)
+		})
+		r.Close()
+		return values
+	}
+
+	values := read(func(values []string) bool { return len(values) == 2 })
+	if !reflect.DeepEqual(values, []string{"1", "2"}) {
+		t.Errorf("expected both messages to be read, got %v", values)
+	}
+
+	started := time.Now()
+	values = read(func([]string) bool { return time.Since(started) > 100*time.Millisecond })
+	if len(values) != 0 {
+		t.Errorf("committed messages should not be read again, got %v", values)
+	}
+}
+
+func TestInMemory_Ping(t *testing.T) {
+	im := NewInMemory()
+	r := im.NewReader("group", "orders")
+
+	if err := r.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := im.NewWriter("orders").Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	r.Close()
+	if err := r.Ping(context.Background()); err != ErrReaderClosed {
+		t.Errorf("expected %v, got %v", ErrReaderClosed, err)
+	}
+}
+
+func TestInMemory_IdleCallback(t *testing.T) {
+	im := NewInMemory()
+	var mu sync.Mutex
+	idle := 0
+	r := im.NewReader("group", "orders", WithPollTimeout(10*time.Millisecond), WithIdleCallback(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		idle++
+	}))
+	defer r.Close()
+
+	received := make(chan Message, 1)
+	if err := r.Read(func(m Message) error {
+		received <- m
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return idle >= 3
+	})
+
+	// the reader keeps fetching after poll timeouts
+	if err := im.NewWriter("orders").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	select {
+	case m := <-received:
+		if string(m.Value) != "value" {
+			t.Errorf("unexpected message %+v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message was not delivered after the poll timeouts")
+	}
+
+	select {
+	case err := <-r.Errors():
+		t.Errorf("poll timeouts should not be reported as errors, got %v", err)
+	default:
+	}
+}
@@ -0,0 +1,44 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContentTypeHeader is the header describing the encoding of the message value
+const ContentTypeHeader = "content-type"
+
+// ContentTypeJSON is the content type of messages created by NewJSONMessage
+const ContentTypeJSON = "application/json"
+
+// NewJSONMessage returns a message with the given key, v encoded as JSON value and the content type header set to
+// application/json
+func NewJSONMessage(key string, v interface{}) (Message, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return Message{}, fmt.Errorf("could not encode message value as JSON: %w", err)
+	}
+
+	m := Message{Key: []byte(key), Value: value}
+	m.SetHeader(ContentTypeHeader, []byte(ContentTypeJSON))
+	return m, nil
+}
+
+// WriteJSON writes a message with the given key and v encoded as JSON value like NewJSONMessage, independent of the
+// serializer of the writer
+func (mw *missyWriter) WriteJSON(key string, v interface{}) error {
+	m, err := NewJSONMessage(key, v)
+	if err != nil {
+		return err
+	}
+	return mw.WriteMessage(m)
+}
+
+// DecodeJSON decodes the JSON value of the message into v. The content type header is not checked, so messages of
+// producers which do not set it can be decoded as well.
+func (m Message) DecodeJSON(v interface{}) error {
+	if err := json.Unmarshal(m.Value, v); err != nil {
+		return fmt.Errorf("could not decode JSON value of message %d of topic %s partition %d: %w", m.Offset, m.Topic, m.Partition, err)
+	}
+	return nil
+}
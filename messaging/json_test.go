@@ -0,0 +1,69 @@
+package messaging
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+)
+
+type order struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+func TestJSONMessage_RoundTrip(t *testing.T) {
+	m, err := NewJSONMessage("order-1", order{ID: "order-1", Amount: 9.99})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if string(m.Key) != "order-1" {
+		t.Error(expected(string(m.Key), "order-1"))
+	}
+	if contentType, _ := m.GetHeader(ContentTypeHeader); string(contentType) != ContentTypeJSON {
+		t.Error(expected(string(contentType), ContentTypeJSON))
+	}
+
+	var decoded order
+	if err := m.DecodeJSON(&decoded); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if decoded != (order{ID: "order-1", Amount: 9.99}) {
+		t.Errorf("expected the encoded order, got %+v", decoded)
+	}
+}
+
+func TestNewJSONMessage_Error(t *testing.T) {
+	if _, err := NewJSONMessage("key", make(chan int)); err == nil {
+		t.Error("values which cannot be encoded should fail")
+	}
+}
+
+func TestMessage_DecodeJSONMalformed(t *testing.T) {
+	m := Message{Topic: "orders", Value: []byte(`{"id": "order-1"`)}
+
+	var decoded order
+	err := m.DecodeJSON(&decoded)
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected a JSON syntax error, got %v", err)
+	}
+}
+
+func TestMissyWriter_WriteJSON(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	want, _ := NewJSONMessage("order-1", order{ID: "order-1", Amount: 9.99})
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), want).Return(nil)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, topic: "orders"}
+	if err := writer.WriteJSON("order-1", order{ID: "order-1", Amount: 9.99}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := writer.WriteJSON("order-1", make(chan int)); err == nil {
+		t.Error("values which cannot be encoded should fail")
+	}
+}
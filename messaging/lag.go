@@ -0,0 +1,173 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// lagCacheTTL is how long a lag queried from the broker is returned by Lag before it is queried again
+const lagCacheTTL = 5 * time.Second
+
+// lagTimeout is the maximum time Lag waits for the broker to answer
+const lagTimeout = 10 * time.Second
+
+// lagFunc queries the per-partition lag of a consumer group from the broker
+type lagFunc func(ctx context.Context) (map[int]int64, error)
+
+// Lag returns the lag per partition, that is the high-water mark minus the committed offset of the consumer group.
+// The value is cached for a few seconds to not query the broker on every call.
+func (mr *missyReader) Lag() (map[int]int64, error) {
+	mr.mu.Lock()
+	closed := mr.closed
+	mr.mu.Unlock()
+	if closed {
+		return nil, ErrReaderClosed
+	}
+
+	mr.lagMu.Lock()
+	defer mr.lagMu.Unlock()
+
+	if mr.lag == nil || time.Since(mr.lagQueriedAt) > lagCacheTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), lagTimeout)
+		defer cancel()
+
+		lag, err := mr.fetchLag(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mr.lag = lag
+		mr.lagQueriedAt = time.Now()
+	}
+
+	lag := make(map[int]int64, len(mr.lag))
+	for p, l := range mr.lag {
+		lag[p] = l
+	}
+	return lag, nil
+}
+
+// CaughtUp reports whether the reader has processed and committed every message fetched so far and the broker reader
+// lag stats show no further messages, e.g. to switch from catching up on a backlog to live processing. It is a snapshot:
+// new messages make the reader fall behind again right after. A reader which is not reading or has not fetched from the
+// broker yet has not caught up. kafka-go reports the lag of the partition fetched last, so with several partitions
+// CaughtUp turns true once the reader has caught up on that one.
+func (mr *missyReader) CaughtUp() bool {
+	mr.mu.Lock()
+	reading, tracker, batcher := mr.readFunc != nil, mr.tracker, mr.batcher
+	mr.mu.Unlock()
+	if !reading {
+		return false
+	}
+
+	stats := mr.pollStats()
+	mr.statsMu.Lock()
+	fetched := mr.brokerFetched
+	mr.statsMu.Unlock()
+	if !fetched || stats.Lag != 0 {
+		return false
+	}
+	return !tracker.uncommitted() && (batcher == nil || !batcher.uncommitted())
+}
+
+// newKafkaClient returns a client for admin requests using the TLS and SASL settings of dialer
+func newKafkaClient(brokers []string, dialer *kafka.Dialer) *kafka.Client {
+	transport := &kafka.Transport{}
+	if dialer != nil {
+		transport.TLS = dialer.TLS
+		transport.SASL = dialer.SASLMechanism
+	}
+	return &kafka.Client{Addr: kafka.TCP(brokers...), Transport: transport}
+}
+
+// kafkaLag returns a lagFunc querying the group's committed offsets and the partitions' high-water marks from kafka
+func kafkaLag(brokers []string, groupID string, topic string, dialer *kafka.Dialer) lagFunc {
+	client := newKafkaClient(brokers, dialer)
+
+	return func(ctx context.Context) (map[int]int64, error) {
+		partitions, err := topicPartitions(ctx, client, topic)
+		if err != nil {
+			return nil, err
+		}
+		committed, err := committedOffsets(ctx, client, groupID, topic, partitions)
+		if err != nil {
+			return nil, err
+		}
+
+		var requests []kafka.OffsetRequest
+		for _, p := range partitions {
+			requests = append(requests, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+		}
+		offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+		if err != nil {
+			return nil, err
+		}
+
+		first := make(map[int]int64)
+		last := make(map[int]int64)
+		for _, p := range offsets.Topics[topic] {
+			if p.Error != nil {
+				return nil, p.Error
+			}
+			first[p.Partition] = p.FirstOffset
+			last[p.Partition] = p.LastOffset
+		}
+
+		lag := make(map[int]int64)
+		for partition, offset := range committed {
+			hwm, ok := last[partition]
+			if !ok {
+				return nil, fmt.Errorf("no high-water mark for partition %d of topic %s", partition, topic)
+			}
+			if offset < 0 {
+				// nothing committed yet, the group starts with the first available message
+				offset = first[partition]
+			}
+			lag[partition] = hwm - offset
+		}
+		return lag, nil
+	}
+}
+
+// topicPartitions returns the ids of the partitions of topic
+func topicPartitions(ctx context.Context, client offsetAdmin, topic string) ([]int, error) {
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	var partitions []int
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, t.Error
+		}
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	return partitions, nil
+}
+
+// committedOffsets returns the offsets committed by the consumer group per partition, -1 if nothing has been committed
+func committedOffsets(ctx context.Context, client *kafka.Client, groupID string, topic string, partitions []int) (map[int]int64, error) {
+	res, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: groupID, Topics: map[string][]int{topic: partitions}})
+	if err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	committed := make(map[int]int64)
+	for _, p := range res.Topics[topic] {
+		if p.Error != nil {
+			return nil, p.Error
+		}
+		committed[p.Partition] = p.CommittedOffset
+	}
+	return committed, nil
+}
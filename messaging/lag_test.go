@@ -0,0 +1,105 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestMissyReader_LagCached(t *testing.T) {
+	calls := 0
+	reader := missyReader{fetchLag: func(ctx context.Context) (map[int]int64, error) {
+		calls++
+		return map[int]int64{0: 3, 1: 0}, nil
+	}}
+
+	for i := 0; i < 2; i++ {
+		lag, err := reader.Lag()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if lag[0] != 3 || lag[1] != 0 {
+			t.Errorf("unexpected lag %v", lag)
+		}
+		// modifying the returned map must not change the cached lag
+		lag[0] = 100
+	}
+	if calls != 1 {
+		t.Errorf("lag should be queried once while cached, got %d queries", calls)
+	}
+
+	reader.lagQueriedAt = time.Now().Add(-2 * lagCacheTTL)
+	if _, err := reader.Lag(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("an expired lag should be queried again, got %d queries", calls)
+	}
+}
+
+func TestMissyReader_LagError(t *testing.T) {
+	reader := missyReader{fetchLag: func(ctx context.Context) (map[int]int64, error) {
+		return nil, errors.New("error")
+	}}
+
+	if _, err := reader.Lag(); err == nil {
+		t.Error("error was expected")
+	}
+}
+
+func TestMissyReader_LagClosed(t *testing.T) {
+	reader := missyReader{closed: true}
+
+	if _, err := reader.Lag(); err != ErrReaderClosed {
+		t.Errorf("expected ErrReaderClosed, got %v", err)
+	}
+}
+
+func TestInMemory_CaughtUp(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for i := 0; i < 5; i++ {
+		if err := w.Write([]byte("key"), []byte("backlog")); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	if r.CaughtUp() {
+		t.Error("a reader which is not reading has not caught up")
+	}
+
+	var mu sync.Mutex
+	processed := 0
+	live := make(chan struct{})
+	if err := r.Read(func(m Message) error {
+		if string(m.Value) == "live" {
+			<-live
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		processed++
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, r.CaughtUp)
+	mu.Lock()
+	if processed != 5 {
+		t.Errorf("expected the backlog of 5 messages to be processed, got %d", processed)
+	}
+	mu.Unlock()
+
+	// a message being processed is not committed yet
+	if err := w.Write([]byte("key"), []byte("live")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	waitFor(t, func() bool { return !r.CaughtUp() })
+	close(live)
+	waitFor(t, r.CaughtUp)
+}
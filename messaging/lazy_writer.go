@@ -0,0 +1,117 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// lazyWriter creates the retry and DLQ writers of a reader on first use, so readers whose messages never fail do not
+// connect to the broker for them
+type lazyWriter struct {
+	once      sync.Once
+	newWriter func() Writer
+
+	mu sync.Mutex
+	w  Writer
+}
+
+// newLazyWriter returns a writer of topic which is created with newWriter once it is used
+func newLazyWriter(newWriter func(topic string) Writer, topic string) *lazyWriter {
+	return &lazyWriter{newWriter: func() Writer { return newWriter(topic) }}
+}
+
+// writer returns the writer and creates it on first use
+func (lw *lazyWriter) writer() Writer {
+	lw.once.Do(func() {
+		w := lw.newWriter()
+		lw.mu.Lock()
+		lw.w = w
+		lw.mu.Unlock()
+	})
+	return lw.w
+}
+
+// created returns the writer or nil if it has not been used yet
+func (lw *lazyWriter) created() Writer {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w
+}
+
+func (lw *lazyWriter) Write(key []byte, value []byte) error {
+	return lw.writer().Write(key, value)
+}
+
+func (lw *lazyWriter) WriteContext(ctx context.Context, key []byte, value []byte) error {
+	return lw.writer().WriteContext(ctx, key, value)
+}
+
+func (lw *lazyWriter) WriteWithRetryCounter(key []byte, value []byte, retryCounter int) error {
+	return lw.writer().WriteWithRetryCounter(key, value, retryCounter)
+}
+
+func (lw *lazyWriter) WriteWithHeaders(key []byte, value []byte, headers ...Header) error {
+	return lw.writer().WriteWithHeaders(key, value, headers...)
+}
+
+func (lw *lazyWriter) WriteTo(topic string, key []byte, value []byte) error {
+	return lw.writer().WriteTo(topic, key, value)
+}
+
+func (lw *lazyWriter) WriteValue(key []byte, v interface{}) error {
+	return lw.writer().WriteValue(key, v)
+}
+
+func (lw *lazyWriter) WriteString(key string, value []byte) error {
+	return lw.writer().WriteString(key, value)
+}
+
+func (lw *lazyWriter) WriteJSON(key string, v interface{}) error {
+	return lw.writer().WriteJSON(key, v)
+}
+
+func (lw *lazyWriter) WriteMessage(m Message) error {
+	return lw.writer().WriteMessage(m)
+}
+
+func (lw *lazyWriter) WriteBatch(msgs []Message) error {
+	return lw.writer().WriteBatch(msgs)
+}
+
+func (lw *lazyWriter) WriteBatchContext(ctx context.Context, msgs []Message) error {
+	return lw.writer().WriteBatchContext(ctx, msgs)
+}
+
+func (lw *lazyWriter) ProduceSync(ctx context.Context, msg Message) (int, int64, error) {
+	return lw.writer().ProduceSync(ctx, msg)
+}
+
+func (lw *lazyWriter) Ping(ctx context.Context) error {
+	return lw.writer().Ping(ctx)
+}
+
+// Flush waits for the pending messages of the writer, there are none if it has not been used yet
+func (lw *lazyWriter) Flush(ctx context.Context) error {
+	if w := lw.created(); w != nil {
+		return w.Flush(ctx)
+	}
+	return nil
+}
+
+// Stats returns the stats of the writer, they are empty if it has not been used yet
+func (lw *lazyWriter) Stats() kafka.WriterStats {
+	if w := lw.created(); w != nil {
+		return w.Stats()
+	}
+	return kafka.WriterStats{}
+}
+
+// Close closes the writer if it has been used
+func (lw *lazyWriter) Close() error {
+	if w := lw.created(); w != nil {
+		return w.Close()
+	}
+	return nil
+}
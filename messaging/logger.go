@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"github.com/microdevs/missy/log"
+)
+
+// Logger is what readers and writers log with, WithLogger and WithWriterLogger replace the default logger writing to
+// missy/log, e.g. to use another logging library or to capture the log output in tests
+type Logger interface {
+	// WithFields returns a logger attaching fields to every entry, e.g. the topic and offset of a message
+	WithFields(fields map[string]interface{}) Logger
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// defaultLogger logs to missy/log, it is used by readers and writers without a logger and by the package functions
+var defaultLogger Logger = missyLogger{log.WithFields(nil)}
+
+// missyLogger adapts a missy/log logger to Logger
+type missyLogger struct {
+	logger log.Logger
+}
+
+func (ml missyLogger) WithFields(fields map[string]interface{}) Logger {
+	return missyLogger{ml.logger.WithFields(fields)}
+}
+
+func (ml missyLogger) Debugf(format string, args ...interface{}) {
+	ml.logger.Debugf(format, args...)
+}
+
+func (ml missyLogger) Infof(format string, args ...interface{}) {
+	ml.logger.Infof(format, args...)
+}
+
+func (ml missyLogger) Warnf(format string, args ...interface{}) {
+	ml.logger.Warnf(format, args...)
+}
+
+func (ml missyLogger) Errorf(format string, args ...interface{}) {
+	ml.logger.Errorf(format, args...)
+}
+
+// getLogger returns the logger of the reader
+func (mr *missyReader) getLogger() Logger {
+	if mr.logger == nil {
+		return defaultLogger
+	}
+	return mr.logger
+}
+
+// messageLogger returns the logger of the reader attaching the fields identifying m to every entry
+func (mr *missyReader) messageLogger(m Message) Logger {
+	return mr.getLogger().WithFields(m.logFields())
+}
+
+// writerLogger passes the logger of the reader on to the writers it creates
+func (mr *missyReader) writerLogger() WriterOption {
+	return WithWriterLogger(mr.logger)
+}
+
+// getLogger returns the logger of the writer
+func (mw *missyWriter) getLogger() Logger {
+	if mw.logger == nil {
+		return defaultLogger
+	}
+	return mw.logger
+}
@@ -0,0 +1,131 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// logEntry is an entry logged with a capturingLogger
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// capturingLogger keeps the entries logged with it and the loggers derived from it
+type capturingLogger struct {
+	mu      *sync.Mutex
+	entries *[]logEntry
+	fields  map[string]interface{}
+}
+
+func newCapturingLogger() *capturingLogger {
+	return &capturingLogger{mu: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (cl *capturingLogger) WithFields(fields map[string]interface{}) Logger {
+	merged := make(map[string]interface{}, len(cl.fields)+len(fields))
+	for k, v := range cl.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &capturingLogger{mu: cl.mu, entries: cl.entries, fields: merged}
+}
+
+func (cl *capturingLogger) log(level string, format string, args ...interface{}) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	*cl.entries = append(*cl.entries, logEntry{level: level, msg: fmt.Sprintf(format, args...), fields: cl.fields})
+}
+
+func (cl *capturingLogger) Debugf(format string, args ...interface{}) {
+	cl.log("debug", format, args...)
+}
+
+func (cl *capturingLogger) Infof(format string, args ...interface{}) {
+	cl.log("info", format, args...)
+}
+
+func (cl *capturingLogger) Warnf(format string, args ...interface{}) {
+	cl.log("warn", format, args...)
+}
+
+func (cl *capturingLogger) Errorf(format string, args ...interface{}) {
+	cl.log("error", format, args...)
+}
+
+// find returns the first entry of level containing msg
+func (cl *capturingLogger) find(level string, msg string) (logEntry, bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for _, e := range *cl.entries {
+		if e.level == level && strings.Contains(e.msg, msg) {
+			return e, true
+		}
+	}
+	return logEntry{}, false
+}
+
+func TestWithLogger(t *testing.T) {
+	logger := newCapturingLogger()
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders", WithLogger(logger))
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.ReadContext(ctx, func(m Message) error {
+		return errors.Wrap(ErrDrop, "invalid order")
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	var entry logEntry
+	waitFor(t, func() bool {
+		var ok bool
+		entry, ok = logger.find("warn", "dropping message: invalid order")
+		return ok
+	})
+	if entry.fields["topic"] != "orders" || entry.fields["key"] != "key" {
+		t.Errorf("expected the fields of the message, got %v", entry.fields)
+	}
+	if _, ok := logger.find("info", "new message"); !ok {
+		t.Error("expected the fetched message to be logged")
+	}
+}
+
+func TestWithWriterLogger(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	gomock.InOrder(
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(kafka.LeaderNotAvailable),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(nil),
+	)
+
+	logger := newCapturingLogger()
+	writer := missyWriter{topic: "orders", brokerWriter: brokerWriterMock}
+	WithWriteRetries(1, time.Millisecond)(&writer)
+	WithWriterLogger(logger)(&writer)
+
+	if err := writer.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := logger.find("warn", "write to topic orders failed"); !ok {
+		t.Error("expected the retried write to be logged")
+	}
+	mockCtrl.Finish()
+}
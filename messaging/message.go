@@ -1,14 +1,148 @@
 package messaging
 
 import (
+	"strconv"
 	"time"
+
+	"github.com/segmentio/kafka-go"
 )
 
+// RetryCounterHeader is the header carrying the number of times a message has been re-enqueued for retry.
+// Messages without this header, e.g. from producers outside of missy, have a retry counter of 0.
+const RetryCounterHeader = "missy-retry-count"
+
+// OriginalTimeHeader is the header carrying the time a re-enqueued message has originally been written, in RFC 3339
+// format with nanoseconds. It is set on the first retry and kept for all further retries.
+const OriginalTimeHeader = "missy-original-time"
+
 type Message struct {
-	Topic     string
-	Key       []byte
-	Value     []byte
-	Time      time.Time
-	Partition int
-	Offset    int64
+	Topic        string
+	Key          []byte
+	Value        []byte
+	Time         time.Time
+	Partition    int
+	Offset       int64
+	RetryCounter int
+	Headers      []Header
+}
+
+// Header is a key/value pair attached to a message, e.g. to pass correlation ids or schema information
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// GetHeader returns the value of the first header with the given key
+func (m Message) GetHeader(key string) ([]byte, bool) {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value, true
+		}
+	}
+	return nil, false
+}
+
+// SetHeader sets the value of a header, an existing header with the same key is replaced
+func (m *Message) SetHeader(key string, value []byte) {
+	for i, h := range m.Headers {
+		if h.Key == key {
+			m.Headers[i].Value = value
+			return
+		}
+	}
+	m.Headers = append(m.Headers, Header{Key: key, Value: value})
+}
+
+// Size returns the number of bytes of the key, the value and the keys and values of the headers of the message, the
+// payload counting towards the message size limit of the broker
+func (m Message) Size() int {
+	size := len(m.Key) + len(m.Value)
+	for _, h := range m.Headers {
+		size += len(h.Key) + len(h.Value)
+	}
+	return size
+}
+
+// logFields returns the fields identifying a message in log entries
+func (m Message) logFields() map[string]interface{} {
+	return map[string]interface{}{
+		"topic":     m.Topic,
+		"partition": m.Partition,
+		"offset":    m.Offset,
+		"retry":     m.RetryCounter,
+		"key":       string(m.Key),
+		"trace_id":  m.traceID(),
+	}
+}
+
+// retryMessage returns the message to re-enqueue for another retry of m, keeping its headers and original time
+func retryMessage(m Message) Message {
+	retried := Message{
+		Key:          m.Key,
+		Value:        m.Value,
+		RetryCounter: m.RetryCounter + 1,
+		Headers:      append([]Header(nil), m.Headers...),
+	}
+	if id := m.traceID(); id != "" {
+		retried.SetHeader(TraceIDHeader, []byte(id))
+	}
+	if _, ok := retried.GetHeader(OriginalTimeHeader); !ok && !m.Time.IsZero() {
+		retried.SetHeader(OriginalTimeHeader, []byte(m.Time.Format(time.RFC3339Nano)))
+	}
+	return retried
+}
+
+// originalTime returns the time a message has originally been written, taken from the OriginalTimeHeader of
+// re-enqueued messages
+func originalTime(m Message) time.Time {
+	value, ok := m.GetHeader(OriginalTimeHeader)
+	if !ok {
+		return m.Time
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(value))
+	if err != nil {
+		defaultLogger.Warnf("# messaging # invalid %s header \"%s\", using the message time", OriginalTimeHeader, string(value))
+		return m.Time
+	}
+	return t
+}
+
+// fromKafkaMessage converts a kafka.Message to a missy Message and restores the retry counter from its headers
+func fromKafkaMessage(m kafka.Message) Message {
+	msg := Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time, Partition: m.Partition, Offset: m.Offset}
+	for _, h := range m.Headers {
+		if h.Key == RetryCounterHeader {
+			msg.RetryCounter = parseRetryCounter(h.Value)
+			continue
+		}
+		msg.Headers = append(msg.Headers, Header{Key: h.Key, Value: h.Value})
+	}
+	return msg
+}
+
+// parseRetryCounter parses the value of the retry counter header, invalid values count as 0
+func parseRetryCounter(value []byte) int {
+	retryCounter, err := strconv.Atoi(string(value))
+	if err != nil || retryCounter < 0 {
+		defaultLogger.Warnf("# messaging # invalid %s header \"%s\", using 0", RetryCounterHeader, string(value))
+		return 0
+	}
+	return retryCounter
+}
+
+// toKafkaMessage converts a missy Message to a kafka.Message
+func toKafkaMessage(m Message) kafka.Message {
+	return kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time, Partition: m.Partition, Offset: m.Offset, Headers: toKafkaHeaders(m)}
+}
+
+// toKafkaHeaders converts the headers of a message to kafka headers and adds the retry counter header if needed
+func toKafkaHeaders(m Message) []kafka.Header {
+	var headers []kafka.Header
+	for _, h := range m.Headers {
+		headers = append(headers, kafka.Header{Key: h.Key, Value: h.Value})
+	}
+	if m.RetryCounter > 0 {
+		headers = append(headers, kafka.Header{Key: RetryCounterHeader, Value: []byte(strconv.Itoa(m.RetryCounter))})
+	}
+	return headers
 }
@@ -0,0 +1,198 @@
+package messaging
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bouk/monkey"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMessage_Headers(t *testing.T) {
+	msg := Message{}
+
+	if _, ok := msg.GetHeader("correlation-id"); ok {
+		t.Error("header should not be present")
+	}
+
+	msg.SetHeader("correlation-id", []byte("1"))
+	msg.SetHeader("correlation-id", []byte("2"))
+
+	if len(msg.Headers) != 1 {
+		t.Errorf("SetHeader should replace an existing header, got %v", msg.Headers)
+	}
+
+	if v, ok := msg.GetHeader("correlation-id"); !ok || string(v) != "2" {
+		t.Error(expected(string(v), "2"))
+	}
+}
+
+func TestMessage_Size(t *testing.T) {
+	msg := Message{Topic: "ignored", Key: []byte("key"), Value: []byte("value"), Offset: 42}
+	if size := msg.Size(); size != 8 {
+		t.Errorf("expected the size of key and value, got %d", size)
+	}
+
+	msg.SetHeader("correlation-id", []byte("abc"))
+	msg.SetHeader("empty", nil)
+	if size := msg.Size(); size != 8+17+5 {
+		t.Errorf("expected the size of key, value and headers, got %d", size)
+	}
+
+	if size := (Message{}).Size(); size != 0 {
+		t.Errorf("expected an empty message to have size 0, got %d", size)
+	}
+}
+
+func TestMessage_KafkaHeaders(t *testing.T) {
+	msg := Message{Key: []byte("key"), Headers: []Header{{Key: "correlation-id", Value: []byte("abc")}}}
+
+	km := toKafkaMessage(msg)
+	if len(km.Headers) != 1 || km.Headers[0].Key != "correlation-id" || string(km.Headers[0].Value) != "abc" {
+		t.Errorf("headers were not mapped to kafka headers, got %v", km.Headers)
+	}
+
+	back := fromKafkaMessage(kafka.Message{Headers: km.Headers})
+	if v, ok := back.GetHeader("correlation-id"); !ok || string(v) != "abc" {
+		t.Error(expected(string(v), "abc"))
+	}
+}
+
+func TestMessage_RetryCounterRoundTrip(t *testing.T) {
+	kw := kafka.NewWriter(kafka.WriterConfig{
+		Brokers: []string{"localhost:9999"},
+		Topic:   "test",
+	})
+	kr := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{"localhost:9999"},
+		GroupID: "gr1",
+		Topic:   "test",
+	})
+
+	// using monkey patching to pass the written message to the reader (https://github.com/bouk/monkey)
+	var written kafka.Message
+	monkey.PatchInstanceMethod(reflect.TypeOf(kw), "WriteMessages", func(_ *kafka.Writer, ctx context.Context, messages ...kafka.Message) error {
+		written = messages[0]
+		return nil
+	})
+	defer monkey.Unpatch(kw.WriteMessages)
+	monkey.PatchInstanceMethod(reflect.TypeOf(kr), "FetchMessage", func(_ *kafka.Reader, ctx context.Context) (kafka.Message, error) {
+		return written, nil
+	})
+	defer monkey.Unpatch(kr.FetchMessage)
+
+	writer := missyWriter{brokerWriter: &writeBroker{kw}}
+	if err := writer.WriteWithRetryCounter([]byte("key"), []byte("value"), 3); err != nil {
+		t.Error("there was an unexpected error during WriteWithRetryCounter")
+	}
+
+	rb := readBroker{kr}
+	msg, err := rb.FetchMessage(context.Background())
+	if err != nil {
+		t.Error("there is an unexpected error during FetchMessage call")
+	}
+
+	if msg.RetryCounter != 3 {
+		t.Error(expected(strconv.Itoa(msg.RetryCounter), "3"))
+	}
+	if len(msg.Headers) != 0 {
+		t.Errorf("the retry counter header should not show up in the message headers, got %v", msg.Headers)
+	}
+}
+
+func TestMessage_HeadersRoundTrip(t *testing.T) {
+	kw := kafka.NewWriter(kafka.WriterConfig{
+		Brokers: []string{"localhost:9999"},
+		Topic:   "test",
+	})
+	kr := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: []string{"localhost:9999"},
+		GroupID: "gr1",
+		Topic:   "test",
+	})
+
+	var written []kafka.Message
+	monkey.PatchInstanceMethod(reflect.TypeOf(kw), "WriteMessages", func(_ *kafka.Writer, ctx context.Context, messages ...kafka.Message) error {
+		written = append(written, messages...)
+		return nil
+	})
+	defer monkey.Unpatch(kw.WriteMessages)
+	monkey.PatchInstanceMethod(reflect.TypeOf(kr), "FetchMessage", func(_ *kafka.Reader, ctx context.Context) (kafka.Message, error) {
+		m := written[0]
+		written = written[1:]
+		return m, nil
+	})
+	defer monkey.Unpatch(kr.FetchMessage)
+
+	writer := missyWriter{brokerWriter: &writeBroker{kw}}
+	traceID := Header{Key: "trace-id", Value: []byte("4bf92f3577b34da6")}
+	if err := writer.WriteWithHeaders([]byte("key"), []byte("value"), traceID); err != nil {
+		t.Error("there was an unexpected error during WriteWithHeaders")
+	}
+	if err := writer.WriteMessage(Message{Key: []byte("key"), Headers: []Header{traceID}, RetryCounter: 2}); err != nil {
+		t.Error("there was an unexpected error during WriteMessage")
+	}
+
+	rb := readBroker{kr}
+	for _, retryCounter := range []int{0, 2} {
+		msg, err := rb.FetchMessage(context.Background())
+		if err != nil {
+			t.Error("there is an unexpected error during FetchMessage call")
+		}
+		if v, ok := msg.GetHeader("trace-id"); !ok || string(v) != "4bf92f3577b34da6" {
+			t.Error(expected(string(v), "4bf92f3577b34da6"))
+		}
+		if len(msg.Headers) != 1 {
+			t.Errorf("expected only the written header, got %v", msg.Headers)
+		}
+		if msg.RetryCounter != retryCounter {
+			t.Error(expected(strconv.Itoa(msg.RetryCounter), strconv.Itoa(retryCounter)))
+		}
+	}
+}
+
+func TestMessage_RetryCounterWithoutHeader(t *testing.T) {
+	msg := fromKafkaMessage(kafka.Message{Key: []byte("key")})
+	if msg.RetryCounter != 0 {
+		t.Error(expected(strconv.Itoa(msg.RetryCounter), "0"))
+	}
+
+	msg = fromKafkaMessage(kafka.Message{Headers: []kafka.Header{{Key: RetryCounterHeader, Value: []byte("invalid")}}})
+	if msg.RetryCounter != 0 {
+		t.Error(expected(strconv.Itoa(msg.RetryCounter), "0"))
+	}
+}
+
+func TestRetryMessage_KeepsOriginalTime(t *testing.T) {
+	written := time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC)
+	msg := Message{Topic: "test", Key: []byte("key"), Time: written, Partition: 1, Offset: 2, Headers: []Header{{Key: "trace-id", Value: []byte("abc")}}}
+
+	first := retryMessage(msg)
+	// the retried message is read again with the time it has been re-enqueued
+	first.Time = written.Add(time.Minute)
+	second := retryMessage(first)
+
+	if second.RetryCounter != 2 {
+		t.Error(expected(strconv.Itoa(second.RetryCounter), "2"))
+	}
+	if originalTime, _ := second.GetHeader(OriginalTimeHeader); string(originalTime) != written.Format(time.RFC3339Nano) {
+		t.Error(expected(string(originalTime), written.Format(time.RFC3339Nano)))
+	}
+	if len(second.Headers) != 3 {
+		t.Errorf("expected the trace-id, missy trace id and original time headers, got %v", second.Headers)
+	}
+	if len(msg.Headers) != 1 {
+		t.Errorf("re-enqueuing should not modify the headers of the failed message, got %v", msg.Headers)
+	}
+}
+
+func TestMessage_LogFields(t *testing.T) {
+	fields := Message{Topic: "test", Key: []byte("key"), Partition: 2, Offset: 42, RetryCounter: 1}.logFields()
+
+	if fields["topic"] != "test" || fields["partition"] != 2 || fields["offset"] != int64(42) || fields["retry"] != 1 || fields["key"] != "key" {
+		t.Errorf("unexpected log fields %v", fields)
+	}
+}
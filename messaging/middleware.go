@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReadMiddleware wraps a ReadMessageFunc, e.g. to log, measure or trace the handling of every message. It calls next
+// to pass the message on and may change the error it returns.
+type ReadMiddleware func(next ReadMessageFunc) ReadMessageFunc
+
+// WithMiddleware wraps msgFunc with the given middleware, the first one is the outermost: WithMiddleware(m1, m2) calls
+// m1(m2(msgFunc)). Calling it several times appends the middleware. It runs inside the panic recovery, tracing and
+// process timeout of the reader, so it only sees the errors returned by msgFunc.
+func WithMiddleware(middleware ...ReadMiddleware) ReaderOption {
+	return func(mr *missyReader) {
+		mr.middleware = append(mr.middleware, middleware...)
+	}
+}
+
+// withMiddleware binds msgFunc to ctx and wraps it with the middleware of the reader
+func (mr *missyReader) withMiddleware(ctx context.Context, msgFunc ReadMessageFuncCtx) ReadMessageFunc {
+	handle := func(m Message) error {
+		return msgFunc(ctx, m)
+	}
+	for i := len(mr.middleware) - 1; i >= 0; i-- {
+		handle = mr.middleware[i](handle)
+	}
+	return handle
+}
+
+// TimingMiddleware calls observe with the time msgFunc took for every message and the error it returned, e.g. to
+// record the processing time in a metric of the application
+func TimingMiddleware(observe func(m Message, d time.Duration, err error)) ReadMiddleware {
+	return func(next ReadMessageFunc) ReadMessageFunc {
+		return func(m Message) error {
+			start := time.Now()
+			err := next(m)
+			observe(m, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// RecoverMiddleware turns a panic of msgFunc into an error, so the message is retried like after any other error. The
+// reader recovers from panics itself unless WithPanicRecovery(false) is used, RecoverMiddleware recovers before the
+// outer middleware sees the panic.
+func RecoverMiddleware() ReadMiddleware {
+	return func(next ReadMessageFunc) ReadMessageFunc {
+		return func(m Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic processing message: %v", r)
+				}
+			}()
+			return next(m)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingMiddleware appends name to calls before and after passing a message on
+func recordingMiddleware(name string, calls *[]string) ReadMiddleware {
+	return func(next ReadMessageFunc) ReadMessageFunc {
+		return func(m Message) error {
+			*calls = append(*calls, name+" before")
+			err := next(m)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestWithMiddleware_Order(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("orders").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	var calls []string
+	r := im.NewReader("group", "orders", WithMiddleware(recordingMiddleware("first", &calls), recordingMiddleware("second", &calls)), WithMiddleware(recordingMiddleware("third", &calls)))
+	defer r.Close()
+
+	if err := r.ReadN(context.Background(), 1, func(m Message) error {
+		calls = append(calls, "msgFunc")
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"first before", "second before", "third before", "msgFunc", "third after", "second after", "first after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("expected calls %v, got %v", want, calls)
+	}
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	failure := errors.New("failure")
+	var observed time.Duration
+	var observedErr error
+	handle := TimingMiddleware(func(m Message, d time.Duration, err error) {
+		observed, observedErr = d, err
+	})(func(m Message) error {
+		time.Sleep(20 * time.Millisecond)
+		return failure
+	})
+
+	if err := handle(Message{}); err != failure {
+		t.Errorf("expected the error of msgFunc to be returned, got %v", err)
+	}
+	if observed < 20*time.Millisecond || observedErr != failure {
+		t.Errorf("unexpected observation %s %v", observed, observedErr)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	handle := RecoverMiddleware()(func(m Message) error {
+		panic("boom")
+	})
+
+	if err := handle(Message{}); err == nil || err.Error() != "panic processing message: boom" {
+		t.Errorf("expected the panic to be turned into an error, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// seekTimeout is the maximum time SetOffset waits for the broker to answer
+const seekTimeout = 10 * time.Second
+
+// seekFunc sets the committed offset of a consumer group partition on the broker
+type seekFunc func(ctx context.Context, partition int, offset int64) error
+
+// SetOffset sets the offset the consumer group continues reading the partition from, kafka.FirstOffset and
+// kafka.LastOffset seek to the beginning or the end of the partition. Seeking is only allowed before Read is called
+// or after reading stopped, because a running consumer group member would overwrite the offset with its next commit.
+func (mr *missyReader) SetOffset(partition int, offset int64) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.closed {
+		return ErrReaderClosed
+	}
+	if mr.readFunc != nil {
+		return errors.New("cannot set the offset while the reader is reading from underlying broker")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), seekTimeout)
+	defer cancel()
+
+	return mr.seek(ctx, partition, offset)
+}
+
+// kafkaSeek returns a seekFunc committing the offset for the consumer group to kafka
+func kafkaSeek(brokers []string, groupID string, topic string, dialer *kafka.Dialer) seekFunc {
+	client := newKafkaClient(brokers, dialer)
+
+	return func(ctx context.Context, partition int, offset int64) error {
+		if offset == kafka.FirstOffset || offset == kafka.LastOffset {
+			offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+				Topics: map[string][]kafka.OffsetRequest{topic: {{Partition: partition, Timestamp: offset}}},
+			})
+			if err != nil {
+				return err
+			}
+			resolved := int64(-1)
+			for _, p := range offsets.Topics[topic] {
+				if p.Partition != partition {
+					continue
+				}
+				if p.Error != nil {
+					return p.Error
+				}
+				if offset == kafka.FirstOffset {
+					resolved = p.FirstOffset
+				} else {
+					resolved = p.LastOffset
+				}
+			}
+			if resolved < 0 {
+				return fmt.Errorf("no offset for partition %d of topic %s", partition, topic)
+			}
+			offset = resolved
+		}
+
+		return commitOffsets(ctx, client, groupID, topic, map[int]int64{partition: offset})
+	}
+}
+
+// seekTimeFunc sets the committed offsets of all partitions of a consumer group to the first messages at or after a time
+type seekTimeFunc func(ctx context.Context, t time.Time) error
+
+// SeekToTime sets the offsets the consumer group continues reading all partitions from to the first messages written
+// at or after t, e.g. to look at everything since an incident started. Partitions without such messages are set to
+// their end, so only messages written from now on are read from them. Like SetOffset, it is only allowed while the
+// reader is not reading.
+func (mr *missyReader) SeekToTime(t time.Time) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.closed {
+		return ErrReaderClosed
+	}
+	if mr.readFunc != nil {
+		return errors.New("cannot seek while the reader is reading from underlying broker")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), seekTimeout)
+	defer cancel()
+
+	return mr.seekTime(ctx, t)
+}
+
+// kafkaSeekTime returns a seekTimeFunc resolving the offsets with the broker and committing them for the consumer group
+func kafkaSeekTime(brokers []string, groupID string, topic string, dialer *kafka.Dialer) seekTimeFunc {
+	client := newKafkaClient(brokers, dialer)
+
+	return func(ctx context.Context, t time.Time) error {
+		partitions, err := topicPartitions(ctx, client, topic)
+		if err != nil {
+			return err
+		}
+		offsets, err := resolveOffsets(ctx, client, topic, partitions, OffsetAt(t))
+		if err != nil {
+			return err
+		}
+		return commitOffsets(ctx, client, groupID, topic, offsets)
+	}
+}
@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMissyReader_SetOffset(t *testing.T) {
+	var partition int
+	var offset int64
+	reader := missyReader{seek: func(ctx context.Context, p int, o int64) error {
+		partition, offset = p, o
+		return nil
+	}}
+
+	if err := reader.SetOffset(2, kafka.FirstOffset); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if partition != 2 || offset != kafka.FirstOffset {
+		t.Errorf("unexpected seek to partition %d offset %d", partition, offset)
+	}
+}
+
+func TestMissyReader_SetOffsetError(t *testing.T) {
+	reader := missyReader{seek: func(ctx context.Context, p int, o int64) error {
+		return errors.New("error")
+	}}
+
+	if err := reader.SetOffset(0, 10); err == nil {
+		t.Error("error was expected")
+	}
+}
+
+func TestMissyReader_SetOffsetWhileReading(t *testing.T) {
+	readFunc := ReadMessageFuncCtx(func(ctx context.Context, msg Message) error { return nil })
+	reader := missyReader{readFunc: &readFunc, seek: func(ctx context.Context, p int, o int64) error {
+		t.Error("seeking while reading should not reach the broker")
+		return nil
+	}}
+
+	if err := reader.SetOffset(0, 10); err == nil {
+		t.Error("error was expected")
+	}
+}
+
+func TestMissyReader_SetOffsetClosed(t *testing.T) {
+	reader := missyReader{closed: true}
+
+	if err := reader.SetOffset(0, 10); err != ErrReaderClosed {
+		t.Errorf("expected ErrReaderClosed, got %v", err)
+	}
+}
+
+func TestMissyReader_SeekToTime(t *testing.T) {
+	im := NewInMemory()
+	start := time.Date(2020, 1, 1, 14, 0, 0, 0, time.UTC)
+	var msgs []kafka.Message
+	for i, v := range []string{"13:58", "13:59", "14:00", "14:01"} {
+		msgs = append(msgs, kafka.Message{Topic: "orders", Value: []byte(v), Time: start.Add(time.Duration(i-2) * time.Minute)})
+	}
+	im.write(msgs)
+
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+	if err := r.SeekToTime(start); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peeked, _ := r.Peek(context.Background(), 10); len(peeked) != 2 || string(peeked[0].Value) != "14:00" {
+		t.Errorf("expected the group to continue with the message at 14:00, got %+v", peeked)
+	}
+
+	if err := r.SeekToTime(start.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lag := im.lag("group", "orders"); lag != 0 {
+		t.Errorf("expected the group at the end of the topic without messages after the time, got lag %d", lag)
+	}
+}
+
+func TestMissyReader_SeekToTimeWhileReading(t *testing.T) {
+	readFunc := ReadMessageFuncCtx(func(ctx context.Context, msg Message) error { return nil })
+	reader := missyReader{readFunc: &readFunc, seekTime: func(ctx context.Context, t time.Time) error {
+		return nil
+	}}
+
+	if err := reader.SeekToTime(time.Now()); err == nil {
+		t.Error("error was expected")
+	}
+}
+
+func TestMissyReader_CommitOffset(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for _, v := range []string{"1", "2", "3"} {
+		if err := w.Write(nil, []byte(v)); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	var committed []int64
+	r := im.NewReader("group", "orders", WithManualCommit(), WithCommitCallback(func(topic string, partition int, offset int64) {
+		committed = append(committed, offset)
+	}))
+	defer r.Close()
+
+	if err := r.CommitOffset("orders", 0, 0); !errors.Is(err, ErrNotAssigned) {
+		t.Errorf("expected ErrNotAssigned before a message of the partition has been fetched, got %v", err)
+	}
+
+	if err := r.ReadN(context.Background(), 3, func(m Message) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lag := im.lag("group", "orders"); lag != 3 {
+		t.Fatalf("nothing should be committed in manual commit mode, got lag %d", lag)
+	}
+
+	// the sink has flushed the first two messages
+	if err := r.CommitOffset("orders", 0, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lag := im.lag("group", "orders"); lag != 1 {
+		t.Errorf("expected the offsets up to 1 to be committed, got lag %d", lag)
+	}
+	if !reflect.DeepEqual(committed, []int64{1}) {
+		t.Errorf("expected the commit callback for offset 1, got %v", committed)
+	}
+
+	if err := r.CommitOffset("orders", 0, 3); err == nil {
+		t.Error("an offset which has not been fetched should not be committed")
+	}
+	if err := r.CommitOffset("orders", 1, 0); !errors.Is(err, ErrNotAssigned) {
+		t.Errorf("expected ErrNotAssigned for another partition, got %v", err)
+	}
+}
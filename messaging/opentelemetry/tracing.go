@@ -0,0 +1,90 @@
+// Package opentelemetry propagates OpenTelemetry trace context through messaging headers using the W3C traceparent
+// header. It is a separate package, so only users of OpenTelemetry depend on it.
+package opentelemetry
+
+import (
+	"context"
+
+	"github.com/microdevs/missy/messaging"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer creating the spans of read messages
+const tracerName = "github.com/microdevs/missy/messaging"
+
+// Tracing implements messaging.Tracing with the W3C trace context propagator
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewTracing returns a Tracing creating the spans of read messages with a tracer of tp
+func NewTracing(tp trace.TracerProvider) *Tracing {
+	return &Tracing{tracer: tp.Tracer(tracerName), propagator: propagation.TraceContext{}}
+}
+
+// WithTracing starts a consumer span around msgFunc for every message, a child of the span the message was written in
+func WithTracing(tp trace.TracerProvider) messaging.ReaderOption {
+	return messaging.WithTracing(NewTracing(tp))
+}
+
+// WithWriterTracing adds the traceparent header of the span in the context passed to WriteContext and
+// WriteBatchContext to the written messages
+func WithWriterTracing(tp trace.TracerProvider) messaging.WriterOption {
+	return messaging.WithWriterTracing(NewTracing(tp))
+}
+
+// Inject implements messaging.Tracing
+func (t *Tracing) Inject(ctx context.Context, m *messaging.Message) {
+	t.propagator.Inject(ctx, headerCarrier{m: m})
+}
+
+// StartSpan implements messaging.Tracing
+func (t *Tracing) StartSpan(ctx context.Context, m messaging.Message) (context.Context, func(err error)) {
+	ctx = t.propagator.Extract(ctx, headerCarrier{m: &m})
+	ctx, span := t.tracer.Start(ctx, m.Topic+" process", trace.WithSpanKind(trace.SpanKindConsumer))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// headerCarrier adapts the headers of a message to propagation.TextMapCarrier
+type headerCarrier struct {
+	m *messaging.Message
+}
+
+// Get implements propagation.TextMapCarrier
+func (hc headerCarrier) Get(key string) string {
+	for _, h := range hc.m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set implements propagation.TextMapCarrier, it replaces an existing header of key
+func (hc headerCarrier) Set(key string, value string) {
+	for i, h := range hc.m.Headers {
+		if h.Key == key {
+			hc.m.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	hc.m.Headers = append(hc.m.Headers, messaging.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys implements propagation.TextMapCarrier
+func (hc headerCarrier) Keys() []string {
+	keys := make([]string, len(hc.m.Headers))
+	for i, h := range hc.m.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
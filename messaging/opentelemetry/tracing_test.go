@@ -0,0 +1,67 @@
+package opentelemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/microdevs/missy/messaging"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing_TraceparentRoundTrip(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	im := messaging.NewInMemory()
+	w := im.NewWriter("orders", WithWriterTracing(tp))
+	r := im.NewReader("group", "orders", WithTracing(tp))
+	defer r.Close()
+
+	ctx, producer := tp.Tracer("test").Start(context.Background(), "produce")
+	if err := w.WriteContext(ctx, []byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	producer.End()
+
+	received := make(chan messaging.Message, 1)
+	spans := make(chan trace.SpanContext, 1)
+	readCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.ReadCtx(readCtx, func(ctx context.Context, m messaging.Message) error {
+		spans <- trace.SpanContextFromContext(ctx)
+		received <- m
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error reading: %v", err)
+	}
+
+	m := <-received
+	traceparent := ""
+	for _, h := range m.Headers {
+		if h.Key == "traceparent" {
+			traceparent = string(h.Value)
+		}
+	}
+	expected := "00-" + producer.SpanContext().TraceID().String() + "-" + producer.SpanContext().SpanID().String() + "-01"
+	if traceparent != expected {
+		t.Errorf("expected traceparent %q, got %q", expected, traceparent)
+	}
+
+	consumer := <-spans
+	if consumer.TraceID() != producer.SpanContext().TraceID() {
+		t.Errorf("expected the consumer span in trace %s, got %s", producer.SpanContext().TraceID(), consumer.TraceID())
+	}
+	cancel()
+	r.Close()
+
+	var parent trace.SpanContext
+	for _, s := range recorder.Ended() {
+		if s.SpanKind() == trace.SpanKindConsumer {
+			parent = s.Parent()
+		}
+	}
+	if parent.SpanID() != producer.SpanContext().SpanID() {
+		t.Errorf("expected the consumer span to be a child of the producer span, got parent %s", parent.SpanID())
+	}
+}
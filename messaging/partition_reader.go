@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/microdevs/missy/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// errNoGroup is returned when committing messages of a reader which is not a member of a consumer group
+var errNoGroup = errors.New("not supported by readers without a consumer group, keep track of the offsets and use SetOffset")
+
+// NewPartitionReader returns a Reader of a single partition of topic which does not join a consumer group, e.g. for
+// workflows pinned to a partition per tenant. There are no rebalances and nothing is committed: the application keeps
+// track of the offsets it has processed and sets the offset to continue from with SetOffset before reading, otherwise
+// reading starts at the first message of the partition. CommitMessages and CommitOffset return an error. Failed
+// messages are re-enqueued to the same partition, so the reader reads its retries.
+func NewPartitionReader(brokers []string, topic string, partition int, opts ...ReaderOption) Reader {
+	if partition < 0 {
+		log.Panicf("# messaging # invalid partition %d: must not be negative", partition)
+	}
+
+	config := kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+		MinBytes:  defaultMinBytes,
+		MaxBytes:  defaultMaxBytes,
+	}
+
+	return NewReaderWithConfig(config, opts...)
+}
+
+// usePartitionReader makes a reader without a consumer group manage its position with kr instead of committed offsets
+func (mr *missyReader) usePartitionReader(kr *kafka.Reader) {
+	partition := mr.config.Partition
+	mr.manualCommit = true
+	mr.seek = func(ctx context.Context, p int, offset int64) error {
+		if p != partition {
+			return fmt.Errorf("cannot set the offset of partition %d, the reader reads partition %d", p, partition)
+		}
+		return kr.SetOffset(offset)
+	}
+	mr.seekTime = func(ctx context.Context, t time.Time) error {
+		return kr.SetOffsetAt(ctx, t)
+	}
+	mr.fetchLag = func(ctx context.Context) (map[int]int64, error) {
+		lag, err := kr.ReadLag(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[int]int64{partition: lag}, nil
+	}
+	mr.peek = func(ctx context.Context, n int) ([]Message, error) {
+		return peekPartition(ctx, mr.config, partition, kr.Offset(), n)
+	}
+}
@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestNewPartitionReader(t *testing.T) {
+	r := NewPartitionReader([]string{"localhost:9091"}, "orders", 2).(*missyReader)
+	defer r.Close()
+
+	if r.groupID != "" || r.config.GroupID != "" {
+		t.Errorf("the reader should not join a consumer group, got %q", r.config.GroupID)
+	}
+	if r.config.Partition != 2 {
+		t.Errorf("expected partition 2, got %d", r.config.Partition)
+	}
+	if !r.manualCommit {
+		t.Error("a reader without a consumer group should not commit")
+	}
+	if err := r.SetOffset(1, 0); err == nil {
+		t.Error("expected an error setting the offset of another partition")
+	}
+	retryWriter := r.writer.(*lazyWriter).writer().(*missyWriter)
+	if p := retryWriter.config.Balancer.Balance(kafka.Message{Key: []byte("key")}, 0, 1, 2, 3); p != 2 {
+		t.Errorf("expected retries to go to partition 2, got %d", p)
+	}
+}
+
+func TestInMemory_PartitionReaderReadsFromOffset(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for _, v := range []string{"0", "1", "2", "3", "4"} {
+		if err := w.Write(nil, []byte(v)); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	r := im.NewPartitionReader("orders", 0)
+	defer r.Close()
+
+	// the application continues after the last offset it has processed
+	if err := r.SetOffset(0, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lag, _ := r.Lag(); lag[0] != 2 {
+		t.Errorf("expected a lag of 2, got %d", lag[0])
+	}
+
+	var read []Message
+	if err := r.ReadN(context.Background(), 2, func(m Message) error {
+		read = append(read, m)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(read) != 2 || read[0].Offset != 3 || string(read[0].Value) != "3" || read[1].Offset != 4 {
+		t.Errorf("expected the messages at offsets 3 and 4, got %+v", read)
+	}
+	if err := r.CommitMessages(read[1]); err == nil {
+		t.Error("expected an error committing without a consumer group")
+	}
+
+	// seeking back to the beginning of the partition
+	if err := r.SetOffset(0, kafka.FirstOffset); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if peeked, _ := r.Peek(context.Background(), 1); len(peeked) != 1 || peeked[0].Offset != 0 {
+		t.Errorf("expected to peek the first message, got %+v", peeked)
+	}
+}
@@ -0,0 +1,109 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// peekWait is how long Peek waits for the next message of a partition before it treats the partition as exhausted
+const peekWait = time.Second
+
+// peekFunc fetches up to n messages without committing them
+type peekFunc func(ctx context.Context, n int) ([]Message, error)
+
+// Peek returns up to n of the next messages the consumer group would read, without committing them or joining the
+// group. Partitions are read one after the other starting at the committed offset, fewer than n messages are
+// returned if the topic has no more messages within a short time.
+func (mr *missyReader) Peek(ctx context.Context, n int) ([]Message, error) {
+	mr.mu.Lock()
+	closed := mr.closed
+	mr.mu.Unlock()
+	if closed {
+		return nil, ErrReaderClosed
+	}
+	if n <= 0 {
+		return nil, errors.New("the number of messages to peek must be positive")
+	}
+
+	return mr.peek(ctx, n)
+}
+
+// kafkaPeek returns a peekFunc reading the partitions of topic with readers outside of the consumer group
+func kafkaPeek(config kafka.ReaderConfig) peekFunc {
+	client := newKafkaClient(config.Brokers, config.Dialer)
+
+	return func(ctx context.Context, n int) ([]Message, error) {
+		partitions, err := topicPartitions(ctx, client, config.Topic)
+		if err != nil {
+			return nil, err
+		}
+		sort.Ints(partitions)
+
+		committed := make(map[int]int64)
+		if config.GroupID != "" {
+			if committed, err = committedOffsets(ctx, client, config.GroupID, config.Topic, partitions); err != nil {
+				return nil, err
+			}
+		}
+
+		var msgs []Message
+		for _, p := range partitions {
+			if len(msgs) >= n {
+				break
+			}
+
+			offset, ok := committed[p]
+			if !ok || offset < 0 {
+				offset = config.StartOffset
+				if offset == 0 {
+					offset = kafka.FirstOffset
+				}
+			}
+
+			peeked, err := peekPartition(ctx, config, p, offset, n-len(msgs))
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, peeked...)
+		}
+		return msgs, nil
+	}
+}
+
+// peekPartition reads up to n messages of a partition starting at offset
+func peekPartition(ctx context.Context, config kafka.ReaderConfig, partition int, offset int64, n int) ([]Message, error) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   config.Brokers,
+		Topic:     config.Topic,
+		Partition: partition,
+		Dialer:    config.Dialer,
+		MinBytes:  1,
+		MaxBytes:  config.MaxBytes,
+		MaxWait:   peekWait,
+	})
+	defer r.Close()
+
+	if err := r.SetOffset(offset); err != nil {
+		return nil, err
+	}
+
+	var msgs []Message
+	for len(msgs) < n {
+		fetchCtx, cancel := context.WithTimeout(ctx, peekWait)
+		m, err := r.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			// no message within peekWait, the partition is exhausted
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, fromKafkaMessage(m))
+	}
+	return msgs, nil
+}
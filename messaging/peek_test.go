@@ -0,0 +1,43 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMissyReader_Peek(t *testing.T) {
+	msgs := []Message{{Topic: "test", Offset: 3}, {Topic: "test", Offset: 4}}
+	reader := missyReader{peek: func(ctx context.Context, n int) ([]Message, error) {
+		if n != 5 {
+			t.Errorf("expected to peek 5 messages, got %d", n)
+		}
+		return msgs, nil
+	}}
+
+	peeked, err := reader.Peek(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(peeked) != 2 {
+		t.Errorf("fewer messages should be returned if the topic is exhausted, got %v", peeked)
+	}
+}
+
+func TestMissyReader_PeekInvalidN(t *testing.T) {
+	reader := missyReader{peek: func(ctx context.Context, n int) ([]Message, error) {
+		t.Error("peeking no messages should not reach the broker")
+		return nil, nil
+	}}
+
+	if _, err := reader.Peek(context.Background(), 0); err == nil {
+		t.Error("error was expected")
+	}
+}
+
+func TestMissyReader_PeekClosed(t *testing.T) {
+	reader := missyReader{closed: true}
+
+	if _, err := reader.Peek(context.Background(), 1); err != ErrReaderClosed {
+		t.Errorf("expected ErrReaderClosed, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// pingTimeout is the maximum time Ping waits for the brokers if ctx has no deadline
+const pingTimeout = 5 * time.Second
+
+// Ping checks that the brokers can be reached and know the topics of the reader, e.g. for readiness probes. It only
+// requests the metadata of the topics and does not fetch or commit messages.
+func (mr *missyReader) Ping(ctx context.Context) error {
+	mr.mu.Lock()
+	closed := mr.closed
+	mr.mu.Unlock()
+	if closed {
+		return ErrReaderClosed
+	}
+
+	topics := mr.config.GroupTopics
+	if mr.topic != "" {
+		topics = []string{mr.topic}
+	}
+	return kafkaPing(ctx, mr.brokers, mr.config.Dialer, topics)
+}
+
+// Ping checks that the brokers can be reached and know the topic of the writer, e.g. for readiness probes. A multi
+// topic writer only checks the connection. No message is written.
+func (mw *missyWriter) Ping(ctx context.Context) error {
+	topics := []string{}
+	if mw.topic != "" {
+		topics = []string{mw.topic}
+	}
+	return kafkaPing(ctx, mw.brokers, mw.config.Dialer, topics)
+}
+
+// kafkaPing requests the metadata of topics from the brokers, an empty list of topics only checks the connection
+func kafkaPing(ctx context.Context, brokers []string, dialer *kafka.Dialer, topics []string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+	}
+
+	client := newKafkaClient(brokers, dialer)
+	defer client.Transport.(*kafka.Transport).CloseIdleConnections()
+
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return err
+	}
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return fmt.Errorf("topic %s: %w", t.Name, t.Error)
+		}
+	}
+	return nil
+}
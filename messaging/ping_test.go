@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPing_UnreachableBroker(t *testing.T) {
+	// nothing listens on the port of a closed listener
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	reader := missyReader{brokers: []string{addr}, topic: "test"}
+	writer := missyWriter{brokers: []string{addr}, topic: "test"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := reader.Ping(ctx); err == nil {
+		t.Error("expected an error for an unreachable broker")
+	}
+	if err := writer.Ping(ctx); err == nil {
+		t.Error("expected an error for an unreachable broker")
+	}
+}
+
+func TestPing_RespectsDeadline(t *testing.T) {
+	// the broker accepts connections but never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer l.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	writer := missyWriter{brokers: []string{l.Addr().String()}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := writer.Ping(ctx); err == nil {
+		t.Error("expected an error once the deadline expired")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("Ping should return once the context is done, took %s", time.Since(start))
+	}
+}
+
+func TestPing_ClosedReader(t *testing.T) {
+	reader := missyReader{closed: true}
+
+	if err := reader.Ping(context.Background()); err != ErrReaderClosed {
+		t.Errorf("expected %v, got %v", ErrReaderClosed, err)
+	}
+}
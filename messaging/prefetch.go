@@ -0,0 +1,72 @@
+package messaging
+
+import "context"
+
+// fetchResult is a prefetched message or the error which stopped prefetching
+type fetchResult struct {
+	m   Message
+	err error
+}
+
+// fetcher returns the function the read loop takes the next message from, it fetches ahead of the read loop with
+// WithPrefetch
+func (mr *missyReader) fetcher(ctx context.Context, limit int) func() (Message, error) {
+	if mr.prefetch <= 0 {
+		first := true
+		return func() (Message, error) {
+			m, err := mr.fetch(ctx, first)
+			first = false
+			return m, err
+		}
+	}
+	return mr.prefetcher(ctx, limit)
+}
+
+// prefetcher fetches up to limit messages in the background and keeps up to mr.prefetch of them in a buffer. The
+// returned function takes them out in fetch order, so they are tracked and committed in order. Once reading stops,
+// the buffered messages are discarded without being committed, they are delivered again.
+func (mr *missyReader) prefetcher(ctx context.Context, limit int) func() (Message, error) {
+	// one more than the slots for the error stopping prefetching
+	buffer := make(chan fetchResult, mr.prefetch+1)
+	// a slot is taken before fetching and freed when the message is taken out, so at most mr.prefetch messages are
+	// fetched ahead including the one being fetched
+	slots := make(chan struct{}, mr.prefetch)
+	metrics := getReaderMetrics()
+
+	go func() {
+		defer close(buffer)
+		for i := 0; limit <= 0 || i < limit; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				buffer <- fetchResult{err: errStopped}
+				return
+			}
+
+			m, err := mr.fetch(ctx, i == 0)
+			buffer <- fetchResult{m: m, err: err}
+			if err != nil {
+				return
+			}
+			metrics.onPrefetched(mr.groupID, mr.topic, len(buffer))
+		}
+	}()
+
+	return func() (Message, error) {
+		r, ok := <-buffer
+		if ok && r.err == nil && ctx.Err() == nil {
+			<-slots
+			metrics.onPrefetched(mr.groupID, mr.topic, len(buffer))
+			return r.m, nil
+		}
+
+		// wait for the prefetching goroutine to stop using the broker reader
+		for range buffer {
+		}
+		metrics.onPrefetched(mr.groupID, mr.topic, 0)
+		if !ok || r.err == nil {
+			return Message{}, errStopped
+		}
+		return Message{}, r.err
+	}
+}
@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMissyReader_Prefetch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+
+	const total = 10
+	var mu sync.Mutex
+	fetched := 0
+	var committed []int64
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+		mu.Lock()
+		if fetched < total {
+			m := Message{Topic: "prefetch", Partition: 0, Offset: int64(fetched)}
+			fetched++
+			mu.Unlock()
+			return m, nil
+		}
+		mu.Unlock()
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range msgs {
+			committed = append(committed, m.Offset)
+		}
+		return nil
+	})
+
+	brokerReaderMock.EXPECT().Stats().AnyTimes().Return(kafka.ReaderStats{})
+
+	reader := missyReader{brokerReader: brokerReaderMock, groupID: "prefetch", topic: "prefetch"}
+	WithPrefetch(3)(&reader)
+
+	m := getReaderMetrics()
+	m.prefetched.Reset()
+
+	release := make(chan struct{})
+	var processed []int64
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := reader.ReadContext(ctx, func(msg Message) error {
+		<-release
+		processed = append(processed, msg.Offset)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the first message is being processed and 3 more wait in the buffer
+	waitFor(t, func() bool { return testutil.ToFloat64(m.prefetched.WithLabelValues("prefetch", "prefetch")) == 3 })
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	if fetched != 4 {
+		t.Errorf("expected the buffer to cap prefetching at 3 messages, got %d fetched", fetched)
+	}
+	mu.Unlock()
+
+	close(release)
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(committed) == total
+	})
+	cancel()
+	<-reader.done
+
+	want := []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if !reflect.DeepEqual(processed, want) {
+		t.Errorf("expected the messages to be processed in fetch order, got %v", processed)
+	}
+	if !reflect.DeepEqual(committed, want) {
+		t.Errorf("expected the messages to be committed in order, got %v", committed)
+	}
+	if v := testutil.ToFloat64(m.prefetched.WithLabelValues("prefetch", "prefetch")); v != 0 {
+		t.Errorf("expected an empty buffer after reading stopped, got %v", v)
+	}
+}
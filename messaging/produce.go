@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// errProduceAsync is returned by ProduceSync of an async writer, its writes return before the offset is known
+var errProduceAsync = errors.New("ProduceSync is not supported by async writers")
+
+// errProduceWithoutAcks is returned by ProduceSync of a writer with kafka.RequireNone, the broker does not answer then
+var errProduceWithoutAcks = errors.New("ProduceSync requires acks, the writer uses kafka.RequireNone")
+
+// produceFunc writes a single message and returns it with the partition and offset assigned by the broker
+type produceFunc func(ctx context.Context, m Message) (Message, error)
+
+// ProduceSync writes a single message like WriteMessage and returns the partition and offset it has been written to,
+// e.g. to record where a message of an outbox landed. It requires a synchronous writer which waits for acks, async
+// writers and writers with kafka.RequireNone return an error. The message is sent in a request of its own instead of
+// being batched with other writes, so it is slower than WriteMessage. A multi topic writer writes to the topic of msg.
+func (mw *missyWriter) ProduceSync(ctx context.Context, msg Message) (partition int, offset int64, err error) {
+	if mw.pending != nil {
+		return -1, -1, errProduceAsync
+	}
+	if mw.requiredAcks != nil && *mw.requiredAcks == kafka.RequireNone {
+		return -1, -1, errProduceWithoutAcks
+	}
+
+	m := writableMessage(msg)
+	m.Topic = mw.topic
+	if m.Topic == "" {
+		m.Topic = msg.Topic
+	}
+	msgs, err := mw.prepare(ctx, []Message{m})
+	if err != nil {
+		return -1, -1, err
+	}
+
+	start := time.Now()
+	written, err := mw.produceWithRetries(ctx, msgs[0])
+	getWriterMetrics().onWrite(mw.metricsTopic(msgs), start)
+	getWriterMetrics().onWritten(mw.topic, msgs, err)
+	if err != nil {
+		return -1, -1, err
+	}
+	return written.Partition, written.Offset, nil
+}
+
+// produceWithRetries produces the message and tries again up to writeRetries times like writeWithRetries
+func (mw *missyWriter) produceWithRetries(ctx context.Context, m Message) (Message, error) {
+	written, err := mw.produce(ctx, m)
+
+	backoff := mw.writeRetryBackoff
+	for attempt := 1; attempt <= mw.writeRetries && retryableWriteError(err); attempt++ {
+		mw.getLogger().Warnf("# messaging # write to topic %s failed, retrying in %s (%d/%d): %v", mw.topic, backoff, attempt, mw.writeRetries, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return Message{}, err
+		}
+		backoff *= 2
+
+		written, err = mw.produce(ctx, m)
+	}
+	return written, err
+}
+
+// kafkaProduce returns a produceFunc sending a produce request to the leader of the partition chosen by balancer
+func kafkaProduce(brokers []string, dialer *kafka.Dialer, balancer kafka.Balancer, acks kafka.RequiredAcks, compression kafka.Compression) produceFunc {
+	client := newKafkaClient(brokers, dialer)
+
+	return func(ctx context.Context, m Message) (Message, error) {
+		partitions, err := topicPartitions(ctx, client, m.Topic)
+		if err != nil {
+			return Message{}, err
+		}
+		if len(partitions) == 0 {
+			return Message{}, fmt.Errorf("topic %s has no partitions", m.Topic)
+		}
+		// the kafka writer passes the partitions sorted as well, so keys are hashed to the same partition
+		sort.Ints(partitions)
+
+		headers := toKafkaHeaders(m)
+		partition := balancer.Balance(kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Headers: headers}, partitions...)
+
+		res, err := client.Produce(ctx, &kafka.ProduceRequest{
+			Topic:        m.Topic,
+			Partition:    partition,
+			RequiredAcks: acks,
+			Compression:  compression,
+			Records: kafka.NewRecordReader(kafka.Record{
+				Time:    time.Now(),
+				Key:     kafka.NewBytes(m.Key),
+				Value:   kafka.NewBytes(m.Value),
+				Headers: headers,
+			}),
+		})
+		if err != nil {
+			return Message{}, err
+		}
+		if res.Error != nil {
+			return Message{}, res.Error
+		}
+		if err := res.RecordErrors[0]; err != nil {
+			return Message{}, err
+		}
+
+		m.Partition = partition
+		m.Offset = res.BaseOffset
+		return m, nil
+	}
+}
@@ -0,0 +1,35 @@
+package messaging
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ProduceAndCommit writes the messages produced from input with w and commits input with r once all of them have been
+// written, r should use WithManualCommit. kafka-go does not support Kafka transactions, so this is not atomic: if the
+// process dies after writing the outputs, input is delivered again and the outputs are written again. Every output
+// gets an id in the MessageIDHeader derived from the topic, partition and offset of input, so reprocessing input
+// produces the same ids and readers of the outputs using WithDedup skip the duplicates. Outputs which already have an
+// id keep it.
+func ProduceAndCommit(w Writer, r Reader, outputs []Message, input Message) error {
+	if len(outputs) > 0 {
+		batch := make([]Message, len(outputs))
+		for i, m := range outputs {
+			batch[i] = withOutputID(m, input, i)
+		}
+		if err := w.WriteBatch(batch); err != nil {
+			return fmt.Errorf("could not write the outputs of message %d of topic %s partition %d: %w", input.Offset, input.Topic, input.Partition, err)
+		}
+	}
+	return r.CommitMessages(input)
+}
+
+// withOutputID returns the i-th output of input with an id which is the same every time input is processed
+func withOutputID(m Message, input Message, i int) Message {
+	if id, ok := m.GetHeader(MessageIDHeader); ok && len(id) > 0 {
+		return m
+	}
+	m.Headers = append([]Header(nil), m.Headers...)
+	m.SetHeader(MessageIDHeader, []byte(input.Topic+"-"+strconv.Itoa(input.Partition)+"-"+strconv.FormatInt(input.Offset, 10)+"-"+strconv.Itoa(i)))
+	return m
+}
@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+)
+
+func TestProduceAndCommit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	writerMock := NewMockWriter(mockCtrl)
+	readerMock := NewMockReader(mockCtrl)
+
+	input := Message{Topic: "orders", Partition: 2, Offset: 42}
+	outputs := []Message{{Key: []byte("a")}, {Key: []byte("b"), Headers: []Header{{Key: MessageIDHeader, Value: []byte("own-id")}}}}
+
+	var written [][]Message
+	writerMock.EXPECT().WriteBatch(gomock.Any()).Times(2).DoAndReturn(func(msgs []Message) error {
+		written = append(written, msgs)
+		return nil
+	})
+	readerMock.EXPECT().CommitMessages(input).Times(2).Return(nil)
+
+	// the input is processed twice, e.g. because the process died before the commit
+	for i := 0; i < 2; i++ {
+		if err := ProduceAndCommit(writerMock, readerMock, outputs, input); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	mockCtrl.Finish()
+
+	for _, msgs := range written {
+		if id, _ := msgs[0].GetHeader(MessageIDHeader); string(id) != "orders-2-42-0" {
+			t.Error(expected(string(id), "orders-2-42-0"))
+		}
+		if id, _ := msgs[1].GetHeader(MessageIDHeader); string(id) != "own-id" {
+			t.Error(expected(string(id), "own-id"))
+		}
+	}
+	if len(outputs[0].Headers) != 0 {
+		t.Errorf("the given outputs should not be changed, got %v", outputs[0].Headers)
+	}
+}
+
+func TestProduceAndCommit_WriteError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	writerMock := NewMockWriter(mockCtrl)
+	readerMock := NewMockReader(mockCtrl)
+
+	writeErr := errors.New("leader not available")
+	writerMock.EXPECT().WriteBatch(gomock.Any()).Return(writeErr)
+	// the input must not be committed if its outputs have not been written
+
+	err := ProduceAndCommit(writerMock, readerMock, []Message{{Key: []byte("a")}}, Message{Topic: "orders"})
+	if !errors.Is(err, writeErr) {
+		t.Errorf("expected %v, got %v", writeErr, err)
+	}
+	mockCtrl.Finish()
+}
@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMissyWriter_ProduceSync(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+
+	for i := int64(0); i < 3; i++ {
+		partition, offset, err := w.ProduceSync(context.Background(), Message{Key: []byte("key"), Value: []byte("value")})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if partition != 0 || offset != i {
+			t.Errorf("expected partition 0 offset %d, got partition %d offset %d", i, partition, offset)
+		}
+	}
+
+	msgs := im.Messages("orders")
+	if len(msgs) != 3 || string(msgs[2].Value) != "value" {
+		t.Errorf("expected 3 messages to be written, got %+v", msgs)
+	}
+}
+
+func TestMissyWriter_ProduceSyncReturnsBrokerPosition(t *testing.T) {
+	var produced Message
+	w := &missyWriter{topic: "orders", produce: func(ctx context.Context, m Message) (Message, error) {
+		produced = m
+		m.Partition = 3
+		m.Offset = 17
+		return m, nil
+	}}
+
+	partition, offset, err := w.ProduceSync(context.Background(), Message{Topic: "other", Key: []byte("key"), Partition: 1, Offset: 5})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if partition != 3 || offset != 17 {
+		t.Errorf("expected partition 3 offset 17, got partition %d offset %d", partition, offset)
+	}
+	if produced.Topic != "orders" || produced.Partition != 0 || produced.Offset != 0 {
+		t.Errorf("expected the message to be produced to the topic of the writer, got %+v", produced)
+	}
+}
+
+func TestMissyWriter_ProduceSyncError(t *testing.T) {
+	w := &missyWriter{topic: "orders", produce: func(ctx context.Context, m Message) (Message, error) {
+		return Message{}, errors.New("error")
+	}}
+
+	if _, _, err := w.ProduceSync(context.Background(), Message{Key: []byte("key")}); err == nil {
+		t.Error("expected the produce error to be returned")
+	}
+}
+
+func TestMissyWriter_ProduceSyncRequiresSyncWriter(t *testing.T) {
+	async := NewInMemory().NewWriter("orders", WithAsync(nil))
+	if _, _, err := async.ProduceSync(context.Background(), Message{}); err != errProduceAsync {
+		t.Errorf("expected %v, got %v", errProduceAsync, err)
+	}
+
+	noAcks := NewWriter([]string{"localhost:9091"}, "orders", WithRequiredAcks(kafka.RequireNone))
+	if _, _, err := noAcks.ProduceSync(context.Background(), Message{}); err != errProduceWithoutAcks {
+		t.Errorf("expected %v, got %v", errProduceWithoutAcks, err)
+	}
+}
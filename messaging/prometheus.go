@@ -0,0 +1,251 @@
+package messaging
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+)
+
+var metrics *readerMetrics
+var metricsOnce sync.Once
+
+var wMetrics *writerMetrics
+var wMetricsOnce sync.Once
+
+// messageSizeBuckets are the buckets of the message size histograms, from 64 bytes to 1 MiB, the default maximum
+// message size of the broker
+var messageSizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+
+// readerMetrics holds the Prometheus metrics of all readers in the process, they are labeled by topic and partition
+type readerMetrics struct {
+	fetched         *prometheus.CounterVec
+	committed       *prometheus.CounterVec
+	retried         *prometheus.CounterVec
+	dlq             *prometheus.CounterVec
+	skipped         *prometheus.CounterVec
+	filtered        *prometheus.CounterVec
+	processDuration *prometheus.HistogramVec
+	messageSize     *prometheus.HistogramVec
+	breakerState    *prometheus.GaugeVec
+	breakerOpened   *prometheus.CounterVec
+	rebalances      *prometheus.CounterVec
+	prefetched      *prometheus.GaugeVec
+}
+
+// getReaderMetrics returns the reader metrics and registers them with the default Prometheus registry on first use,
+// so they show up on the metrics endpoint of a missy service
+func getReaderMetrics() *readerMetrics {
+	metricsOnce.Do(func() {
+		labels := []string{"topic", "partition"}
+		metrics = &readerMetrics{
+			fetched: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_messages_fetched_total",
+				Help: "Number of messages fetched from the broker",
+			}, labels),
+			committed: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_messages_committed_total",
+				Help: "Number of messages committed to the broker",
+			}, labels),
+			retried: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_messages_retried_total",
+				Help: "Number of failed messages re-enqueued for retry",
+			}, labels),
+			dlq: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_dlq_total",
+				Help: "Number of messages written to the dead letter queue",
+			}, labels),
+			skipped: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_messages_skipped_total",
+				Help: "Number of messages skipped by the message handler with ErrSkip",
+			}, labels),
+			filtered: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_messages_filtered_total",
+				Help: "Number of messages filtered out with WithFilter before the message handler",
+			}, labels),
+			processDuration: registerHistogramVec(prometheus.HistogramOpts{
+				Name: "missy_messaging_process_duration_seconds",
+				Help: "Time spent in the message handler, messages skipped with ErrSkip are not observed",
+			}, labels),
+			messageSize: registerHistogramVec(prometheus.HistogramOpts{
+				Name:    "missy_messaging_fetched_message_size_bytes",
+				Help:    "Size of the key, value and headers of fetched messages",
+				Buckets: messageSizeBuckets,
+			}, labels),
+			breakerState: registerGaugeVec(prometheus.GaugeOpts{
+				Name: "missy_messaging_circuit_breaker_state",
+				Help: "State of the circuit breaker of a reader, 0 closed, 1 open, 2 half-open",
+			}, []string{"group", "topic"}),
+			breakerOpened: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_circuit_breaker_opened_total",
+				Help: "Number of times the circuit breaker of a reader opened",
+			}, []string{"group", "topic"}),
+			rebalances: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_rebalances_total",
+				Help: "Number of times partitions have been assigned to a reader of a consumer group",
+			}, []string{"group", "topic"}),
+			prefetched: registerGaugeVec(prometheus.GaugeOpts{
+				Name: "missy_messaging_prefetch_buffer_messages",
+				Help: "Number of messages fetched ahead and waiting to be processed by a reader using WithPrefetch",
+			}, []string{"group", "topic"}),
+		}
+	})
+	return metrics
+}
+
+// writerMetrics holds the Prometheus metrics of all writers in the process, they are labeled by topic
+type writerMetrics struct {
+	written       *prometheus.CounterVec
+	failed        *prometheus.CounterVec
+	writeDuration *prometheus.HistogramVec
+	messageSize   *prometheus.HistogramVec
+}
+
+// getWriterMetrics returns the writer metrics and registers them with the default Prometheus registry on first use
+func getWriterMetrics() *writerMetrics {
+	wMetricsOnce.Do(func() {
+		labels := []string{"topic"}
+		wMetrics = &writerMetrics{
+			written: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_messages_written_total",
+				Help: "Number of messages written to the broker",
+			}, labels),
+			failed: registerCounterVec(prometheus.CounterOpts{
+				Name: "missy_messaging_write_errors_total",
+				Help: "Number of messages which could not be written to the broker",
+			}, labels),
+			writeDuration: registerHistogramVec(prometheus.HistogramOpts{
+				Name: "missy_messaging_write_duration_seconds",
+				Help: "Time spent writing messages to the broker, including retries",
+			}, labels),
+			messageSize: registerHistogramVec(prometheus.HistogramOpts{
+				Name:    "missy_messaging_written_message_size_bytes",
+				Help:    "Size of the key, value and headers of written messages",
+				Buckets: messageSizeBuckets,
+			}, labels),
+		}
+	})
+	return wMetrics
+}
+
+// registerCounterVec registers a counter and returns the already registered one if it exists
+func registerCounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(opts, labels)
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector.(*prometheus.CounterVec)
+		}
+		panic(err)
+	}
+	return c
+}
+
+// registerGaugeVec registers a gauge and returns the already registered one if it exists
+func registerGaugeVec(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(opts, labels)
+	if err := prometheus.Register(g); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+		panic(err)
+	}
+	return g
+}
+
+// registerHistogramVec registers a histogram and returns the already registered one if it exists
+func registerHistogramVec(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(opts, labels)
+	if err := prometheus.Register(h); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector.(*prometheus.HistogramVec)
+		}
+		panic(err)
+	}
+	return h
+}
+
+// labelValues returns the metric label values of a message
+func labelValues(m Message) []string {
+	return []string{m.Topic, strconv.Itoa(m.Partition)}
+}
+
+// onFetched counts a fetched message and observes its size
+func (rm *readerMetrics) onFetched(m Message) {
+	rm.fetched.WithLabelValues(labelValues(m)...).Inc()
+	rm.messageSize.WithLabelValues(labelValues(m)...).Observe(float64(m.Size()))
+}
+
+// onCommitted counts a committed message
+func (rm *readerMetrics) onCommitted(m Message) {
+	rm.committed.WithLabelValues(labelValues(m)...).Inc()
+}
+
+// onRetried counts a message re-enqueued for retry
+func (rm *readerMetrics) onRetried(m Message) {
+	rm.retried.WithLabelValues(labelValues(m)...).Inc()
+}
+
+// onDLQ counts a message written to the dead letter queue
+func (rm *readerMetrics) onDLQ(m Message) {
+	rm.dlq.WithLabelValues(labelValues(m)...).Inc()
+}
+
+// onSkipped counts a message skipped by msgFunc
+func (rm *readerMetrics) onSkipped(m Message) {
+	rm.skipped.WithLabelValues(labelValues(m)...).Inc()
+}
+
+// onFiltered counts a message filtered out before msgFunc
+func (rm *readerMetrics) onFiltered(m Message) {
+	rm.filtered.WithLabelValues(labelValues(m)...).Inc()
+}
+
+// onProcessed observes the time msgFunc took to handle a message
+func (rm *readerMetrics) onProcessed(m Message, start time.Time) {
+	rm.processDuration.WithLabelValues(labelValues(m)...).Observe(time.Since(start).Seconds())
+}
+
+// onBreakerChanged records the state of the circuit breaker of a reader and counts its openings
+func (rm *readerMetrics) onBreakerChanged(group string, topic string, state breakerState) {
+	rm.breakerState.WithLabelValues(group, topic).Set(float64(state))
+	if state == breakerOpen {
+		rm.breakerOpened.WithLabelValues(group, topic).Inc()
+	}
+}
+
+// onRebalanced counts rebalances of the consumer group of a reader
+func (rm *readerMetrics) onRebalanced(group string, topic string, n int64) {
+	rm.rebalances.WithLabelValues(group, topic).Add(float64(n))
+}
+
+// onPrefetched sets the number of messages waiting in the prefetch buffer of a reader
+func (rm *readerMetrics) onPrefetched(group string, topic string, n int) {
+	rm.prefetched.WithLabelValues(group, topic).Set(float64(n))
+}
+
+// onWritten counts the written and failed messages of a write which returned err and observes the size of the written
+// ones. Failed messages are those with an error in a kafka.WriteErrors, or all messages for other errors.
+func (wm *writerMetrics) onWritten(topic string, msgs []Message, err error) {
+	var writeErrors kafka.WriteErrors
+	partial := errors.As(err, &writeErrors) && len(writeErrors) == len(msgs)
+	for i, m := range msgs {
+		t := m.Topic
+		if t == "" {
+			t = topic
+		}
+		if err != nil && (!partial || writeErrors[i] != nil) {
+			wm.failed.WithLabelValues(t).Inc()
+		} else {
+			wm.written.WithLabelValues(t).Inc()
+			wm.messageSize.WithLabelValues(t).Observe(float64(m.Size()))
+		}
+	}
+}
+
+// onWrite observes the time a write took
+func (wm *writerMetrics) onWrite(topic string, start time.Time) {
+	wm.writeDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,158 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestReaderMetrics(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	retried := Message{Topic: "metrics", Key: []byte("retried"), Partition: 1, Offset: 0}
+	dlq := Message{Topic: "metrics", Key: []byte("dlq"), Partition: 1, Offset: 1, RetryCounter: 5}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(retried, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(dlq, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), retried).Return(nil)
+	committed := make(chan struct{})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), dlq).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		close(committed)
+		return nil
+	})
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().WriteMessage(Message{Key: retried.Key, Value: retried.Value, RetryCounter: 1, Headers: traceHeaders(retried)}).Return(nil)
+	dlqWriterMock := NewMockWriter(mockCtrl)
+	dlqWriterMock.EXPECT().WriteMessage(gomock.Any()).Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock, dlqWriter: dlqWriterMock}
+
+	m := getReaderMetrics()
+	// metrics are global, start from zero when the test runs more than once
+	for _, c := range []*prometheus.CounterVec{m.fetched, m.committed, m.retried, m.dlq} {
+		c.Reset()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := reader.ReadContext(ctx, func(msg Message) error { return errors.New("error") }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Fatal("messages have not been committed")
+	}
+	cancel()
+	<-reader.done
+
+	for name, c := range map[string]*prometheus.CounterVec{"fetched": m.fetched, "committed": m.committed} {
+		if v := testutil.ToFloat64(c.WithLabelValues("metrics", "1")); v != 2 {
+			t.Errorf("expected 2 %s messages, got %v", name, v)
+		}
+	}
+	for name, c := range map[string]*prometheus.CounterVec{"retried": m.retried, "dlq": m.dlq} {
+		if v := testutil.ToFloat64(c.WithLabelValues("metrics", "1")); v != 1 {
+			t.Errorf("expected 1 %s message, got %v", name, v)
+		}
+	}
+}
+
+func TestReaderMetrics_Skipped(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	skipped := Message{Topic: "skipped", Key: []byte("skipped"), Partition: 1, Offset: 0}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(skipped, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	committed := make(chan struct{})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), skipped).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		close(committed)
+		return nil
+	})
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: NewMockWriter(mockCtrl), dlqWriter: NewMockWriter(mockCtrl)}
+
+	m := getReaderMetrics()
+	m.skipped.DeleteLabelValues("skipped", "1")
+	m.processDuration.DeleteLabelValues("skipped", "1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := reader.ReadContext(ctx, func(msg Message) error { return errors.Wrap(ErrSkip, "not an order") }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Fatal("the skipped message has not been committed")
+	}
+	cancel()
+	<-reader.done
+
+	if v := testutil.ToFloat64(m.skipped.WithLabelValues("skipped", "1")); v != 1 {
+		t.Errorf("expected 1 skipped message, got %v", v)
+	}
+	// readers of other tests may still observe their own series, only the series of this message must not exist
+	if m.processDuration.DeleteLabelValues("skipped", "1") {
+		t.Error("a skipped message should not be counted as processed")
+	}
+}
+
+func TestWriterMetrics(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	gomock.InOrder(
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(nil),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(kafka.WriteErrors{nil, kafka.MessageSizeTooLarge, nil}),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(kafka.LeaderNotAvailable),
+	)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, topic: "wmetrics"}
+
+	m := getWriterMetrics()
+	m.written.Reset()
+	m.failed.Reset()
+	m.writeDuration.Reset()
+	m.messageSize.Reset()
+
+	_ = writer.Write([]byte("key"), []byte("value"))
+	_ = writer.WriteBatch([]Message{{Key: []byte("a")}, {Key: []byte("b")}, {Key: []byte("c")}})
+	_ = writer.Write([]byte("key"), []byte("value"))
+	mockCtrl.Finish()
+
+	if v := testutil.ToFloat64(m.written.WithLabelValues("wmetrics")); v != 3 {
+		t.Errorf("expected 3 written messages, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.failed.WithLabelValues("wmetrics")); v != 2 {
+		t.Errorf("expected 2 failed messages, got %v", v)
+	}
+	if n := testutil.CollectAndCount(m.writeDuration); n != 1 {
+		t.Errorf("expected the write duration of one topic, got %d", n)
+	}
+	if n := testutil.CollectAndCount(m.messageSize); n != 1 {
+		t.Errorf("expected the message sizes of one topic, got %d", n)
+	}
+}
+
+func TestRegisterCounterVecIdempotent(t *testing.T) {
+	opts := prometheus.CounterOpts{Name: "missy_messaging_test_total", Help: "test"}
+
+	first := registerCounterVec(opts, []string{"topic"})
+	second := registerCounterVec(opts, []string{"topic"})
+
+	if first != second {
+		t.Error("registering a counter twice should return the registered counter")
+	}
+}
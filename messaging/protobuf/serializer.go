@@ -0,0 +1,92 @@
+// Package protobuf provides a messaging Serializer and Deserializer for protocol buffers. It is a separate package,
+// so only users of protocol buffers depend on them.
+package protobuf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/microdevs/missy/messaging"
+	"google.golang.org/protobuf/proto"
+)
+
+// errMessageIndexes is returned for values in the Confluent wire format with invalid message indexes
+var errMessageIndexes = errors.New("invalid message indexes in protobuf value")
+
+// ProtoSerializer encodes and decodes proto.Message values. Without a schema registry the values are plain protobuf
+// binary data, with one they use the Confluent protobuf wire format.
+type ProtoSerializer struct {
+	registry *messaging.SchemaRegistry
+	schema   string
+}
+
+// NewProtoSerializer returns a ProtoSerializer writing plain protobuf binary data
+func NewProtoSerializer() *ProtoSerializer {
+	return &ProtoSerializer{}
+}
+
+// NewConfluentProtoSerializer returns a ProtoSerializer using the Confluent protobuf wire format. Writers register
+// schema, the .proto definition whose first message is written, under the subject "<topic>-value". Readers decode
+// values into the message type passed to Reader.Decode and only skip the schema id and message indexes, the schema
+// can be empty for them.
+func NewConfluentProtoSerializer(registry *messaging.SchemaRegistry, schema string) *ProtoSerializer {
+	return &ProtoSerializer{registry: registry, schema: schema}
+}
+
+// Serialize implements messaging.Serializer
+func (ps *ProtoSerializer) Serialize(topic string, v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("the protobuf serializer only writes proto.Message values, got %T", v)
+	}
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if ps.registry == nil {
+		return data, nil
+	}
+
+	id, err := ps.registry.RegisterType(topic+"-value", "PROTOBUF", ps.schema)
+	if err != nil {
+		return nil, err
+	}
+	// the message indexes [0] of the first message in the schema are written as a single 0
+	return messaging.AppendSchemaID(id, append([]byte{0}, data...)), nil
+}
+
+// Deserialize implements messaging.Deserializer
+func (ps *ProtoSerializer) Deserialize(topic string, data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("the protobuf deserializer only reads into proto.Message values, got %T", v)
+	}
+	if ps.registry != nil {
+		_, payload, err := messaging.SplitSchemaID(data)
+		if err != nil {
+			return err
+		}
+		if data, err = skipMessageIndexes(payload); err != nil {
+			return err
+		}
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// skipMessageIndexes returns the protobuf data following the message indexes of the Confluent wire format, a zigzag
+// varint count followed by as many zigzag varint indexes
+func skipMessageIndexes(payload []byte) ([]byte, error) {
+	count, n := binary.Varint(payload)
+	if n <= 0 || count < 0 {
+		return nil, errMessageIndexes
+	}
+	payload = payload[n:]
+	for i := int64(0); i < count; i++ {
+		if _, n = binary.Varint(payload); n <= 0 {
+			return nil, errMessageIndexes
+		}
+		payload = payload[n:]
+	}
+	return payload, nil
+}
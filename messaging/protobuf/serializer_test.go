@@ -0,0 +1,82 @@
+package protobuf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/microdevs/missy/messaging"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtoSerializer_RoundTrip(t *testing.T) {
+	serializer := NewProtoSerializer()
+	sent := timestamppb.New(timestamppb.Now().AsTime())
+
+	data, err := serializer.Serialize("events", sent)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	received := &timestamppb.Timestamp{}
+	if err := serializer.Deserialize("events", data, received); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !proto.Equal(sent, received) {
+		t.Errorf("expected %v, got %v", sent, received)
+	}
+}
+
+func TestProtoSerializer_NoProtoMessage(t *testing.T) {
+	serializer := NewProtoSerializer()
+
+	if _, err := serializer.Serialize("events", "value"); err == nil {
+		t.Error("values which are no proto.Message should fail")
+	}
+	var value string
+	if err := serializer.Deserialize("events", nil, &value); err == nil {
+		t.Error("targets which are no proto.Message should fail")
+	}
+	if err := serializer.Deserialize("events", []byte{0xff}, &wrapperspb.StringValue{}); err == nil {
+		t.Error("malformed values should fail")
+	}
+}
+
+func TestConfluentProtoSerializer_RoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/subjects/names-value/versions" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"id":3}`))
+	}))
+	defer server.Close()
+
+	writer := NewConfluentProtoSerializer(messaging.NewSchemaRegistry(server.URL), `syntax = "proto3"; message StringValue { string value = 1; }`)
+	data, err := writer.Serialize("names", wrapperspb.String("missy"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if id, payload, err := messaging.SplitSchemaID(data); err != nil || id != 3 || payload[0] != 0 {
+		t.Errorf("expected schema 3 and the message indexes [0], got %d %v %v", id, payload, err)
+	}
+
+	reader := NewConfluentProtoSerializer(messaging.NewSchemaRegistry(server.URL), "")
+	received := &wrapperspb.StringValue{}
+	if err := reader.Deserialize("names", data, received); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if received.GetValue() != "missy" {
+		t.Errorf("expected missy, got %s", received.GetValue())
+	}
+
+	// message indexes of a nested message, [1, 0]
+	nested := messaging.AppendSchemaID(3, append([]byte{4, 2, 0}, data[6:]...))
+	if err := reader.Deserialize("names", nested, received); err != nil || received.GetValue() != "missy" {
+		t.Errorf("expected the message indexes to be skipped, got %v %v", received, err)
+	}
+	if err := reader.Deserialize("names", []byte("plain"), received); err != messaging.ErrNoSchemaID {
+		t.Errorf("expected %v, got %v", messaging.ErrNoSchemaID, err)
+	}
+}
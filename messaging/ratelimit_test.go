@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for i := 0; i < 10; i++ {
+		if err := w.Write(nil, []byte("value")); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	r := im.NewReader("group", "orders", WithRateLimit(20), WithConcurrency(4))
+	defer r.Close()
+
+	var mu sync.Mutex
+	var processed []time.Time
+	start := time.Now()
+	r.Read(func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, time.Now())
+		return nil
+	})
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(processed) == 10
+	})
+
+	// 10 messages at 20 per second take at least 9 intervals of 50ms
+	if elapsed := processed[9].Sub(start); elapsed < 450*time.Millisecond {
+		t.Errorf("10 messages should take at least 450ms at 20 per second, took %s", elapsed)
+	}
+	for i := 1; i < len(processed); i++ {
+		if gap := processed[i].Sub(processed[i-1]); gap < 30*time.Millisecond {
+			t.Errorf("messages %d and %d were processed %s apart, expected about 50ms", i-1, i, gap)
+		}
+	}
+}
+
+func TestRateLimiter_WaitStopsWithContext(t *testing.T) {
+	rl := newRateLimiter(1)
+	if err := rl.wait(context.Background()); err != nil {
+		t.Fatalf("the first message should not wait: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := rl.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("waiting should stop when the context is done, took %s", elapsed)
+	}
+}
+
+func TestWithRateLimit_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("a rate limit of 0 should panic")
+		}
+	}()
+	WithRateLimit(0)(&missyReader{})
+}
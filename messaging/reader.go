@@ -3,18 +3,98 @@ package messaging
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
+	"math/rand"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/microdevs/missy/log"
 	"github.com/segmentio/kafka-go"
 )
 
-// ReadMessageFunc is a message reading callback function, on error message will not be committed to underlying
+// defaultNumOfRetries is the number of retries used when MESSAGING_NUMBER_OF_RETRIES is not set
+const defaultNumOfRetries = 5
+
+// InfiniteRetries as the number of retries retries failed messages until they succeed instead of sending them to the DLQ
+const InfiniteRetries = -1
+
+// envNumberOfRetries is the environment variable holding the number of retries before a message goes to the DLQ
+const envNumberOfRetries = "MESSAGING_NUMBER_OF_RETRIES"
+
+// defaultFetchBackoffMin is the delay before a failed fetch is retried the first time
+const defaultFetchBackoffMin = 100 * time.Millisecond
+
+// defaultFetchBackoffMax is the maximum delay between two retries of a failed fetch
+const defaultFetchBackoffMax = 10 * time.Second
+
+// closeTimeout is the maximum time Close waits for the reading goroutine to return or pending writes to complete
+const closeTimeout = 5 * time.Second
+
+// ErrReaderClosed is returned when reading from a reader which has been closed
+var ErrReaderClosed = errors.New("reader is closed")
+
+// errMultiTopic is returned by methods which are only supported by readers of a single topic
+var errMultiTopic = errors.New("not supported by readers of multiple topics")
+
+// errNilReadFunc is returned when reading is started without a msgFunc
+var errNilReadFunc = errors.New("msgFunc must not be nil, pass a function handling the read messages")
+
+// errStopped is returned by fetch if reading stops because ctx is done or the reader has been closed
+var errStopped = errors.New("reading stopped")
+
+// errPollTimeout is returned by fetchMessage if no message arrived within the poll timeout
+var errPollTimeout = errors.New("no message within the poll timeout")
+
+// ErrNotAssigned is returned by CommitOffset for a partition the reader has not fetched from since the last rebalance
+var ErrNotAssigned = errors.New("partition is not assigned to the reader")
+
+// ErrDrop can be returned by a ReadMessageFunc to skip a message which can never be processed, e.g. because of a
+// malformed payload. The message is committed without being retried or sent to the DLQ.
+var ErrDrop = errors.New("drop message")
+
+// ErrSkip can be returned by a ReadMessageFunc for a message it is not interested in, e.g. filtered out by its type.
+// The message is committed like a processed one, but counted as skipped instead of processed in the metrics.
+var ErrSkip = errors.New("skip message")
+
+// ErrSendToDLQ can be returned by a ReadMessageFunc to send a message to the DLQ right away without retrying it
+var ErrSendToDLQ = errors.New("send message to the dead letter queue")
+
+// ReadMessageFunc is a message reading callback function, on error the message is re-enqueued for retry or sent to the DLQ.
+// Return ErrDrop or ErrSendToDLQ, or an error wrapping them, to skip the retries, ErrSkip for messages which are filtered out.
 type ReadMessageFunc func(msg Message) error
 
+// ReadMessageFuncCtx is a ReadMessageFunc receiving a context, which is cancelled when reading stops or the process
+// timeout of the message expires
+type ReadMessageFuncCtx func(ctx context.Context, msg Message) error
+
 // Reader is used to read messages giving callback function
 type Reader interface {
 	Read(msgFunc ReadMessageFunc) error
+	ReadContext(ctx context.Context, msgFunc ReadMessageFunc) error
+	ReadCtx(ctx context.Context, msgFunc ReadMessageFuncCtx) error
+	ReadN(ctx context.Context, n int, msgFunc ReadMessageFunc) error
+	Done() <-chan error
+	CommitMessages(msgs ...Message) error
+	CommitOffset(topic string, partition int, offset int64) error
+	Errors() <-chan error
+	Lag() (map[int]int64, error)
+	CaughtUp() bool
+	SetOffset(partition int, offset int64) error
+	SeekToTime(t time.Time) error
+	Shutdown(ctx context.Context) error
+	Stats() kafka.ReaderStats
+	Peek(ctx context.Context, n int) ([]Message, error)
+	Decode(m Message, v interface{}) error
+	Ping(ctx context.Context) error
+	Topic() string
+	GroupID() string
+	Brokers() []string
 	io.Closer
 }
 
@@ -24,6 +104,7 @@ type BrokerReader interface {
 	FetchMessage(ctx context.Context) (Message, error)
 	CommitMessages(ctx context.Context, msgs ...Message) error
 	ReadMessage(ctx context.Context) (Message, error)
+	Stats() kafka.ReaderStats
 	io.Closer
 }
 
@@ -32,8 +113,92 @@ type missyReader struct {
 	brokers      []string
 	groupID      string
 	topic        string
+	config       kafka.ReaderConfig
 	brokerReader BrokerReader
-	readFunc     *ReadMessageFunc
+	readFunc     *ReadMessageFuncCtx
+	numOfRetries int
+	manualCommit bool
+	concurrency  int
+	keyOrdering  bool
+	writer       Writer
+	writers      map[string]Writer
+	dlqWriter    Writer
+	dlqWriters   map[string]Writer
+	dlqTopic     string
+	dlqCallback  DLQCallback
+
+	retryTopicFunc    func(attempt int) string
+	newWriter         func(topic string) Writer
+	retryTopicWriters map[string]Writer
+
+	writerInjected    bool
+	dlqWriterInjected bool
+	dlqDisabled       bool
+	done              chan struct{}
+	stopped           chan error
+	stopping          <-chan struct{}
+	readErr           error
+	failFast          bool
+	cancel            context.CancelFunc
+	cancelProc        context.CancelFunc
+	mu                sync.Mutex
+	closed            bool
+	tracker           *commitTracker
+	batcher           *commitBatcher
+	retries           sync.WaitGroup
+
+	errs          chan error
+	errorsOnce    sync.Once
+	droppedErrors uint64
+
+	commitBatchSize int
+	commitInterval  time.Duration
+	commitCallback  CommitCallback
+	maxUncommitted  int
+	uncommitted     *uncommittedLimit
+
+	pollTimeout      time.Duration
+	idleCallback     func()
+	fetchBackoffMin  time.Duration
+	fetchBackoffMax  time.Duration
+	retryBackoffBase time.Duration
+	retryBackoffMax  time.Duration
+	retryJitter      float64
+	processTimeout   time.Duration
+	rateLimiter      *rateLimiter
+	breaker          *circuitBreaker
+	inPlaceRetry     bool
+
+	panicRecoveryDisabled bool
+	maxRetryAge           time.Duration
+
+	seek         seekFunc
+	seekTime     seekTimeFunc
+	dedup        *dedupCache
+	filter       func(Message) bool
+	prefetch     int
+	tracing      Tracing
+	middleware   []ReadMiddleware
+	deserializer Deserializer
+	peek         peekFunc
+	fetchLag     lagFunc
+
+	statsMu           sync.Mutex
+	unreported        kafka.ReaderStats
+	brokerFetched     bool
+	rebalanceCallback RebalanceCallback
+	logger            Logger
+
+	commitFailurePolicy CommitFailurePolicy
+
+	assignedMu         sync.Mutex
+	assigned           map[partitionKey]int64
+	joined             bool
+	assignmentCallback AssignmentCallback
+
+	lagMu        sync.Mutex
+	lag          map[int]int64
+	lagQueriedAt time.Time
 }
 
 // readBroker us as a wrapper for kafka.Reader implementation to fulfill BrokerReader interface
@@ -49,7 +214,7 @@ func (rm *readBroker) FetchMessage(ctx context.Context) (Message, error) {
 		return Message{}, err
 	}
 
-	return Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time, Partition: m.Partition, Offset: m.Offset}, nil
+	return fromKafkaMessage(m), nil
 }
 
 // ReadMessage used to read and auto commit messages from the broker (currently not used in missy)
@@ -60,7 +225,7 @@ func (rm *readBroker) ReadMessage(ctx context.Context) (Message, error) {
 		return Message{}, err
 	}
 
-	return Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time, Partition: m.Partition, Offset: m.Offset}, nil
+	return fromKafkaMessage(m), nil
 }
 
 // CommitMessages used to commit red messages for the broker
@@ -69,8 +234,7 @@ func (rm *readBroker) CommitMessages(ctx context.Context, msgs ...Message) error
 	kafkaMessages := make([]kafka.Message, len(msgs))
 
 	for i, m := range msgs {
-		kafkaMsg := kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Time: m.Time, Partition: m.Partition, Offset: m.Offset}
-		kafkaMessages[i] = kafkaMsg
+		kafkaMessages[i] = toKafkaMessage(m)
 	}
 
 	return rm.Reader.CommitMessages(ctx, kafkaMessages...)
@@ -82,59 +246,1006 @@ func (rm *readBroker) Close() error {
 }
 
 // NewReader based on brokers hosts, consumerGroup and topic. You need to close it after use. (Close())
-// we are leaving using the missy config for now, because we don't know how we want to configure this yet.
-func NewReader(brokers []string, groupID string, topic string) Reader {
-
-	kafkaReader := kafka.NewReader(kafka.ReaderConfig{
+// Use NewReaderFromConfig to read brokers, group and topic from the missy config instead.
+// The defaults of the underlying reader can be changed by passing ReaderOptions.
+func NewReader(brokers []string, groupID string, topic string, opts ...ReaderOption) Reader {
+	config := kafka.ReaderConfig{
 		Brokers:        brokers,
 		GroupID:        groupID,
 		Topic:          topic,
-		CommitInterval: 0,    // 0 indicates that commits should be done synchronically
-		MinBytes:       10e3, // 10KB do we want it from config?
-		MaxBytes:       10e6, // 10MB do we want it from config?
-	})
+		CommitInterval: 0, // 0 indicates that commits should be done synchronically
+		MinBytes:       defaultMinBytes,
+		MaxBytes:       defaultMaxBytes,
+	}
+
+	return NewReaderWithConfig(config, opts...)
+}
+
+// NewMultiTopicReader creates a Reader consuming all topics with one consumer group. Failed messages are retried on
+// the topic they have been read from and sent to its DLQ <topic>.dlq, unless WithDLQTopic sets a DLQ for all topics.
+// Lag, SetOffset and SeekToTime are not supported.
+func NewMultiTopicReader(brokers []string, groupID string, topics []string, opts ...ReaderOption) Reader {
+	config := kafka.ReaderConfig{
+		Brokers:        brokers,
+		GroupID:        groupID,
+		GroupTopics:    topics,
+		CommitInterval: 0, // 0 indicates that commits should be done synchronically
+		MinBytes:       defaultMinBytes,
+		MaxBytes:       defaultMaxBytes,
+	}
+
+	return NewReaderWithConfig(config, opts...)
+}
+
+// NewReaderWithConfig creates a Reader using config as is instead of the defaults of NewReader, e.g. to tune timeouts
+// or queue sizes of the underlying reader. Brokers and Topic or GroupTopics are required, they are used for the retry
+// and DLQ writers as well. Options are applied on top of config. Without a GroupID, config.Partition is read like by
+// NewPartitionReader.
+func NewReaderWithConfig(config kafka.ReaderConfig, opts ...ReaderOption) Reader {
+	mr := &missyReader{
+		brokers:      config.Brokers,
+		groupID:      config.GroupID,
+		topic:        config.Topic,
+		config:       config,
+		numOfRetries: numOfRetriesFromEnv(),
+		writers:      make(map[string]Writer),
+		dlqWriters:   make(map[string]Writer),
+	}
 
-	return &missyReader{brokers: brokers, groupID: groupID, topic: topic, brokerReader: &readBroker{kafkaReader}}
+	for _, opt := range opts {
+		opt(mr)
+	}
+
+	topics := mr.config.GroupTopics
+	if mr.topic != "" {
+		topics = []string{mr.topic}
+	}
+	if len(mr.brokers) == 0 || len(topics) == 0 {
+		log.Panicf("# messaging # invalid reader config: Brokers and Topic or GroupTopics are required")
+	}
+	if len(mr.config.GroupTopics) > 0 && mr.groupID == "" {
+		log.Panicf("# messaging # invalid reader config: GroupTopics require a GroupID")
+	}
+	if mr.config.MaxBytes > 0 && mr.config.MinBytes > mr.config.MaxBytes {
+		log.Panicf("# messaging # invalid reader config: MinBytes (%d) must not be greater than MaxBytes (%d)", mr.config.MinBytes, mr.config.MaxBytes)
+	}
+	heartbeat := durationOr(mr.config.HeartbeatInterval, defaultHeartbeatInterval)
+	session := durationOr(mr.config.SessionTimeout, defaultSessionTimeout)
+	if heartbeat >= session {
+		log.Panicf("# messaging # invalid reader config: the heartbeat interval (%s) must be lower than the session timeout (%s)", heartbeat, session)
+	}
+	if mr.maxUncommitted > 0 && !mr.manualCommit && mr.commitInterval <= 0 && mr.commitBatchSize >= mr.maxUncommitted {
+		log.Panicf("# messaging # invalid reader config: the commit batch size (%d) must be smaller than the max uncommitted messages (%d) without a commit interval", mr.commitBatchSize, mr.maxUncommitted)
+	}
+	mr.validateProcessing()
+
+	kr := kafka.NewReader(mr.config)
+	mr.brokerReader = &readBroker{kr}
+	if mr.groupID == "" {
+		mr.usePartitionReader(kr)
+	} else if mr.topic != "" {
+		mr.seek = kafkaSeek(mr.brokers, mr.groupID, mr.topic, mr.config.Dialer)
+		mr.seekTime = kafkaSeekTime(mr.brokers, mr.groupID, mr.topic, mr.config.Dialer)
+		mr.fetchLag = kafkaLag(mr.brokers, mr.groupID, mr.topic, mr.config.Dialer)
+		mr.peek = kafkaPeek(mr.config)
+	} else {
+		mr.seek = func(ctx context.Context, partition int, offset int64) error { return errMultiTopic }
+		mr.seekTime = func(ctx context.Context, t time.Time) error { return errMultiTopic }
+		mr.fetchLag = func(ctx context.Context) (map[int]int64, error) { return nil, errMultiTopic }
+		mr.peek = func(ctx context.Context, n int) ([]Message, error) { return nil, errMultiTopic }
+	}
+
+	mr.createWriters(topics)
+
+	return mr
+}
+
+// durationOr returns d, or def if d is not set
+func durationOr(d time.Duration, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// createWriters creates the retry and DLQ writers which have not been injected. Retried messages go back to the topic
+// they have been read from, failed messages to the DLQ of their topic unless a single DLQ has been set. The writers
+// connect on first use only.
+func (mr *missyReader) createWriters(topics []string) {
+	dialer := withDialer(mr.config.Dialer)
+	mr.newWriter = func(topic string) Writer { return NewWriter(mr.brokers, topic, dialer, mr.writerLogger()) }
+	if !mr.writerInjected {
+		if mr.groupID == "" {
+			// a reader without a consumer group only reads its own partition, so retries go back to it
+			newRetryWriter := func(topic string) Writer {
+				return NewWriter(mr.brokers, topic, dialer, mr.writerLogger(), WithPartition(mr.config.Partition))
+			}
+			mr.writer = newLazyWriter(newRetryWriter, topics[0])
+		} else if len(topics) == 1 {
+			mr.writer = newLazyWriter(mr.newWriter, topics[0])
+		} else {
+			for _, topic := range topics {
+				mr.writers[topic] = newLazyWriter(mr.newWriter, topic)
+			}
+		}
+	}
+
+	if mr.dlqDisabled || mr.dlqWriterInjected {
+		return
+	}
+	if len(topics) > 1 && mr.dlqTopic == "" {
+		for _, topic := range topics {
+			mr.dlqWriters[topic] = newLazyWriter(mr.newWriter, topic+".dlq")
+		}
+		return
+	}
+	if mr.dlqTopic == "" {
+		mr.dlqTopic = topics[0] + ".dlq"
+	}
+	mr.dlqWriter = newLazyWriter(mr.newWriter, mr.dlqTopic)
+}
+
+// numOfRetriesFromEnv reads the number of retries from the environment and falls back to the default if it is unset or
+// invalid. 0 sends failed messages to the DLQ right away, -1 retries them infinitely.
+func numOfRetriesFromEnv() int {
+	value, present := os.LookupEnv(envNumberOfRetries)
+	if !present {
+		return defaultNumOfRetries
+	}
+
+	numOfRetries, err := strconv.Atoi(value)
+	if err != nil || numOfRetries < InfiniteRetries {
+		defaultLogger.Warnf("# messaging # invalid value \"%s\" for %s, using default %d", value, envNumberOfRetries, defaultNumOfRetries)
+		return defaultNumOfRetries
+	}
+
+	return numOfRetries
 }
 
 // Read start reading goroutine that calls msgFunc on new message, you need to close it after use
 func (mr *missyReader) Read(msgFunc ReadMessageFunc) error {
+	return mr.ReadContext(context.Background(), msgFunc)
+}
+
+// ReadContext works like Read, but the reading goroutine stops as soon as ctx is cancelled. A message which is
+// currently handled by msgFunc is still committed before the goroutine returns. Once the goroutine has returned,
+// reading can be started again unless the reader has been closed.
+func (mr *missyReader) ReadContext(ctx context.Context, msgFunc ReadMessageFunc) error {
+	if msgFunc == nil {
+		return errNilReadFunc
+	}
+	return mr.ReadCtx(ctx, func(_ context.Context, msg Message) error {
+		return msgFunc(msg)
+	})
+}
+
+// ReadCtx works like ReadContext, but msgFunc receives a context. It is cancelled when ctx is cancelled, Close is
+// called or the timeout set with WithProcessTimeout expires, Shutdown lets msgFunc finish. Loggers returned by
+// log.WithContext for the context log the topic, partition, offset, retry, key and trace id of the message.
+func (mr *missyReader) ReadCtx(ctx context.Context, msgFunc ReadMessageFuncCtx) error {
+	_, err := mr.start(ctx, msgFunc, 0)
+	return err
+}
+
+// ReadN reads n messages and returns once they have been handled and committed, e.g. for batch jobs draining a topic.
+// Failed messages count as read, they are re-enqueued or sent to the DLQ like by Read. It returns the error of ctx if
+// ctx is done before, ErrReaderClosed if the reader is closed meanwhile or the error which stopped reading, see Done.
+func (mr *missyReader) ReadN(ctx context.Context, n int, msgFunc ReadMessageFunc) error {
+	if msgFunc == nil {
+		return errNilReadFunc
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	done, err := mr.start(ctx, func(_ context.Context, msg Message) error {
+		return msgFunc(msg)
+	}, n)
+	if err != nil {
+		return err
+	}
+	stopped := mr.Done()
+	<-done
+
+	if err := <-stopped; err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if mr.closed {
+		return ErrReaderClosed
+	}
+	return nil
+}
+
+// start starts the reading goroutine, which stops after limit messages if limit is greater than 0. The returned
+// channel is closed once reading stopped.
+func (mr *missyReader) start(ctx context.Context, msgFunc ReadMessageFuncCtx, limit int) (<-chan struct{}, error) {
+	if msgFunc == nil {
+		return nil, errNilReadFunc
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	// a closed reader cannot be restarted because its connection to the broker is gone
+	if mr.closed {
+		return nil, ErrReaderClosed
+	}
+
 	// we've got a read function on this reader, return error
 	if mr.readFunc != nil {
-		return errors.New("this reader is currently reading from underlying broker")
+		return nil, errors.New("this reader is currently reading from underlying broker")
 	}
 
 	// set current read func
 	mr.readFunc = &msgFunc
+	mr.tracker = newCommitTracker(mr.commitReady)
+	mr.batcher = nil
+	if !mr.manualCommit && (mr.commitBatchSize > 1 || mr.commitInterval > 0) {
+		mr.batcher = newCommitBatcher(mr.commitBatchSize, mr.commit)
+	}
+	mr.uncommitted = nil
+	if mr.maxUncommitted > 0 {
+		mr.uncommitted = newUncommittedLimit(mr.maxUncommitted)
+	}
+	procCtx, cancelProc := context.WithCancel(ctx)
+	mr.cancelProc = cancelProc
+	handle := mr.withProcessContext(procCtx, msgFunc)
+	// commit retries continue during Shutdown, they stop with the processing context: on Close, when the Shutdown
+	// deadline expired or once reading has stopped
+	mr.stopping = procCtx.Done()
+	ctx, mr.cancel = context.WithCancel(ctx)
+	done := make(chan struct{})
+	mr.done = done
+
+	stopped := make(chan error, 1)
+	mr.stopped = stopped
+	mr.readErr = nil
 
 	// start reading goroutine
 	go func() {
-		for {
-			ctx := context.Background()
+		var fetchErr error
+		defer close(done)
+		defer func() {
+			if fetchErr == errStopped {
+				fetchErr = nil
+			}
+			if fetchErr == nil {
+				fetchErr = mr.readError()
+			}
+			stopped <- fetchErr
+			close(stopped)
+		}()
+		defer cancelProc()
+		// allow to read again, e.g. after ctx has been cancelled
+		defer mr.resetReadFunc()
+		// commit what is left of the current batch
+		defer mr.flushCommits()
+		// wait for delayed retries before signalling that reading is done
+		defer mr.retries.Wait()
+
+		if mr.batcher != nil && mr.commitInterval > 0 {
+			go mr.batcher.flushEvery(ctx, mr.commitInterval)
+		}
+		if mr.groupID != "" {
+			watchCtx, stopWatching := context.WithCancel(ctx)
+			watching := make(chan struct{})
+			go func() {
+				defer close(watching)
+				mr.watchRebalances(watchCtx)
+			}()
+			// stop polling the stats before signalling that reading is done
+			defer func() {
+				stopWatching()
+				<-watching
+			}()
+		}
 
-			m, err := mr.brokerReader.FetchMessage(ctx)
-			if err != nil {
-				break
+		next := mr.fetcher(ctx, limit)
+		if mr.concurrency > 1 {
+			fetchErr = mr.readConcurrently(ctx, next, handle, limit)
+			return
+		}
+
+		for i := 0; limit <= 0 || i < limit; i++ {
+			var m Message
+			if m, fetchErr = next(); fetchErr != nil {
+				return
 			}
 
-			log.Infof("# messaging # new message: [topic] %v; [part] %v; [offset] %v; %s = %s\n", m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value))
-			if err := msgFunc(m); err != nil {
-				log.Errorf("# messaging # cannot commit a message: %v", err)
-				continue
+			mr.process(ctx, mr.track(m), handle)
+		}
+	}()
+
+	return done, nil
+}
+
+// Done returns a channel which receives the error which stopped the current or last reading and is closed afterwards.
+// The error is nil if reading stopped because ctx was cancelled, the reader has been closed or ReadN read all
+// messages. With WithFailFast, it is the error fetching the first message, e.g. because the brokers are unreachable.
+// It is the write error if a failed message could neither be re-enqueued nor sent to the DLQ.
+// Done returns nil if reading has not been started yet.
+func (mr *missyReader) Done() <-chan error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.stopped
+}
+
+// validateProcessing panics on combinations of options which would not process messages the way they promise
+func (mr *missyReader) validateProcessing() {
+	if mr.keyOrdering && mr.concurrency <= 1 {
+		log.Panicf("# messaging # invalid reader config: WithKeyOrdering requires WithConcurrency of more than 1 worker")
+	}
+	if mr.inPlaceRetry && mr.concurrency > 1 && !mr.keyOrdering {
+		log.Panicf("# messaging # invalid reader config: WithInPlaceRetry with WithConcurrency(%d) requires WithKeyOrdering to keep messages in order", mr.concurrency)
+	}
+}
+
+// readError returns the error reading has been stopped with by stopWithError
+func (mr *missyReader) readError() error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	return mr.readErr
+}
+
+// withProcessContext binds msgFunc to ctx and limits the time it may take per message to the process timeout. Panics
+// in msgFunc are turned into errors unless panic recovery has been disabled.
+func (mr *missyReader) withProcessContext(ctx context.Context, msgFunc ReadMessageFuncCtx) ReadMessageFunc {
+	return func(m Message) (err error) {
+		if !mr.panicRecoveryDisabled {
+			defer func() {
+				if r := recover(); r != nil {
+					mr.messageLogger(m).Errorf("# messaging # recovered from panic processing message: %v\n%s", r, debug.Stack())
+					err = fmt.Errorf("panic processing message: %v", r)
+				}
+			}()
+		}
+
+		// log.WithContext(ctx) in msgFunc logs the fields of the message
+		msgCtx := log.NewContext(ctx, m.logFields())
+		if mr.tracing != nil {
+			var end func(err error)
+			msgCtx, end = mr.tracing.StartSpan(msgCtx, m)
+			defer func() { end(err) }()
+		}
+		if mr.processTimeout <= 0 {
+			return mr.withMiddleware(msgCtx, msgFunc)(m)
+		}
+
+		msgCtx, cancel := context.WithTimeout(msgCtx, mr.processTimeout)
+		defer cancel()
+
+		err = mr.withMiddleware(msgCtx, msgFunc)(m)
+		if err != nil && msgCtx.Err() == context.DeadlineExceeded {
+			// the message is retried like after any other error
+			return fmt.Errorf("processing timed out after %s: %w", mr.processTimeout, err)
+		}
+		return err
+	}
+}
+
+// readConcurrently hands fetched messages to a pool of workers and commits them in the order they were fetched, it
+// stops after limit messages if limit is greater than 0. It returns the error which stopped fetching.
+func (mr *missyReader) readConcurrently(ctx context.Context, next func() (Message, error), msgFunc ReadMessageFunc, limit int) error {
+	// all workers share one queue, with key ordering every worker has its own
+	queues := make([]chan *trackedMessage, 1)
+	if mr.keyOrdering {
+		queues = make([]chan *trackedMessage, mr.concurrency)
+	}
+	for i := range queues {
+		queues[i] = make(chan *trackedMessage)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < mr.concurrency; i++ {
+		wg.Add(1)
+		go func(jobs <-chan *trackedMessage) {
+			defer wg.Done()
+			for tm := range jobs {
+				mr.process(ctx, tm, msgFunc)
 			}
+		}(queues[i%len(queues)])
+	}
+
+	var err error
+	for i := 0; limit <= 0 || i < limit; i++ {
+		var m Message
+		if m, err = next(); err != nil {
+			break
+		}
+		queues[workerFor(m, i, len(queues))] <- mr.track(m)
+	}
+
+	for _, jobs := range queues {
+		close(jobs)
+	}
+	wg.Wait()
+	return err
+}
+
+// workerFor returns the queue of the n queues the i-th fetched message goes to. Messages with the same key always go
+// to the same queue, messages without a key are spread over all queues.
+func workerFor(m Message, i int, n int) int {
+	if n == 1 {
+		return 0
+	}
+	if len(m.Key) == 0 {
+		return i % n
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(m.Key)
+	return int(h.Sum32() % uint32(n))
+}
+
+// fetch returns the next message from the broker, it returns errStopped if reading should stop. Transient fetch errors
+// are retried with a growing delay, reading only stops if ctx is cancelled or the reader has been closed. With
+// WithFailFast, an error fetching the first message is returned instead of being retried.
+func (mr *missyReader) fetch(ctx context.Context, first bool) (Message, error) {
+	if mr.breaker != nil {
+		if err := mr.breaker.wait(ctx); err != nil {
+			mr.getLogger().Debugf("# messaging # stopped reading: %v", err)
+			return Message{}, errStopped
+		}
+	}
+	if mr.rateLimiter != nil {
+		if err := mr.rateLimiter.wait(ctx); err != nil {
+			mr.getLogger().Debugf("# messaging # stopped reading: %v", err)
+			return Message{}, errStopped
+		}
+	}
+	if mr.uncommitted != nil {
+		if err := mr.uncommitted.wait(ctx); err != nil {
+			mr.getLogger().Debugf("# messaging # stopped reading: %v", err)
+			return Message{}, errStopped
+		}
+	}
+
+	backoff, maxBackoff := defaultFetchBackoffMin, defaultFetchBackoffMax
+	if mr.fetchBackoffMin > 0 {
+		backoff, maxBackoff = mr.fetchBackoffMin, mr.fetchBackoffMax
+	}
+	for {
+		m, err := mr.fetchMessage(ctx)
+		if err == nil {
+			mr.messageLogger(m).Infof("# messaging # new message")
+			getReaderMetrics().onFetched(m)
+			mr.assign(m)
+			return m, nil
+		}
+
+		if ctx.Err() != nil {
+			mr.getLogger().Debugf("# messaging # stopped reading: %v", ctx.Err())
+			return Message{}, errStopped
+		}
 
-			// commit message if no error
-			if err := mr.brokerReader.CommitMessages(ctx, m); err != nil {
-				// should we do something else to just logging not committed message?
-				log.Errorf("cannot commit message [%s] %v/%v: %s = %s; with error: %v", m.Topic, m.Partition, m.Offset, string(m.Key), string(m.Value), err)
+		if err == errPollTimeout {
+			if mr.idleCallback != nil {
+				mr.idleCallback()
 			}
+			continue
 		}
-	}()
 
+		// kafka-go returns io.EOF once the reader has been closed
+		if err == io.EOF {
+			mr.getLogger().Debugf("# messaging # stopped reading: reader has been closed")
+			return Message{}, errStopped
+		}
+
+		mr.reportError(FetchError, nil, err)
+		if first && mr.failFast {
+			mr.getLogger().Errorf("# messaging # cannot fetch the first message, stopped reading: %v", err)
+			return Message{}, err
+		}
+		mr.getLogger().Warnf("# messaging # cannot fetch message, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			mr.getLogger().Debugf("# messaging # stopped reading: %v", ctx.Err())
+			return Message{}, errStopped
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// fetchMessage fetches the next message from the broker, it returns errPollTimeout if no message arrived within the
+// poll timeout
+func (mr *missyReader) fetchMessage(ctx context.Context) (Message, error) {
+	if mr.pollTimeout <= 0 {
+		return mr.brokerReader.FetchMessage(ctx)
+	}
+
+	pollCtx, cancel := context.WithTimeout(ctx, mr.pollTimeout)
+	defer cancel()
+
+	m, err := mr.brokerReader.FetchMessage(pollCtx)
+	if err != nil && ctx.Err() == nil && pollCtx.Err() == context.DeadlineExceeded {
+		return Message{}, errPollTimeout
+	}
+	return m, err
+}
+
+// track registers a fetched message with the commit tracker, unless the application commits itself
+func (mr *missyReader) track(m Message) *trackedMessage {
+	if mr.manualCommit {
+		return &trackedMessage{msg: m}
+	}
+	return mr.tracker.add(m)
+}
+
+// complete marks a message as handled, it is committed as soon as all previous messages of its partition are handled
+func (mr *missyReader) complete(tm *trackedMessage) {
+	if mr.uncommitted != nil {
+		mr.uncommitted.processed(tm.msg)
+	}
+	// in manual commit mode the application decides when to commit
+	if mr.manualCommit {
+		return
+	}
+	mr.tracker.done(tm)
+}
+
+// process calls msgFunc for a message and re-enqueues it if msgFunc fails
+func (mr *missyReader) process(ctx context.Context, tm *trackedMessage, msgFunc ReadMessageFunc) {
+	m := tm.msg
+	logger := mr.messageLogger(m)
+	if mr.filter != nil && !mr.filter(m) {
+		getReaderMetrics().onFiltered(m)
+		if mr.breaker != nil {
+			mr.breaker.skipped()
+		}
+		mr.complete(tm)
+		return
+	}
+	if mr.dedup != nil && mr.dedup.contains(m) {
+		logger.Infof("# messaging # skipping duplicate message")
+		if mr.breaker != nil {
+			mr.breaker.skipped()
+		}
+		mr.complete(tm)
+		return
+	}
+
+	start := time.Now()
+	err := msgFunc(m)
+	if err != nil && mr.inPlaceRetry {
+		var handled bool
+		if m, handled, err = mr.retryInPlace(ctx, m, msgFunc, err); !handled {
+			// reading stops, the message is delivered again after the last committed offset
+			return
+		}
+	}
+	skipped := errors.Is(err, ErrSkip)
+	if skipped {
+		getReaderMetrics().onSkipped(m)
+	} else {
+		getReaderMetrics().onProcessed(m, start)
+	}
+	if mr.breaker != nil {
+		// skipped and dropped messages and messages sent to the DLQ right away have been handled by msgFunc
+		mr.breaker.record(err != nil && !skipped && !errors.Is(err, ErrDrop) && !errors.Is(err, ErrSendToDLQ))
+	}
+	if mr.dedup != nil && (err == nil || skipped || errors.Is(err, ErrDrop)) {
+		// failed messages are not remembered, their retries carry the same id
+		mr.dedup.add(m)
+	}
+	if skipped {
+		logger.Debugf("# messaging # skipped message")
+		mr.complete(tm)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, ErrDrop) {
+			logger.Warnf("# messaging # dropping message: %v", err)
+			mr.complete(tm)
+			return
+		}
+		logger.Errorf("# messaging # cannot process a message: %v", err)
+
+		// delay the retry without blocking the reading goroutine, the message is committed after it has been re-enqueued
+		if delay := mr.retryDelay(m); delay > 0 && !errors.Is(err, ErrSendToDLQ) {
+			mr.retries.Add(1)
+			go func() {
+				defer mr.retries.Done()
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					// re-enqueue right away when reading stops
+				}
+				if mr.reenqueue(m, err) == nil {
+					mr.complete(tm)
+				}
+			}()
+			return
+		}
+
+		// a message which could not be re-enqueued is not committed, so it is delivered again after a restart
+		if mr.reenqueue(m, err) != nil {
+			return
+		}
+	}
+
+	mr.complete(tm)
+}
+
+// retryDelay returns how long to wait before a failed message is re-enqueued. The delay doubles with every retry of
+// the message and is capped at the configured maximum. Messages which go to the DLQ are not delayed.
+func (mr *missyReader) retryDelay(m Message) time.Duration {
+	if mr.retryBackoffBase <= 0 || mr.retriesExhausted(m) {
+		return 0
+	}
+
+	delay := mr.retryBackoffBase
+	// with infinite retries the counter grows without bounds, stop doubling before the delay overflows
+	for i := 0; i < m.RetryCounter && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+		if mr.retryBackoffMax > 0 && delay >= mr.retryBackoffMax {
+			delay = mr.retryBackoffMax
+			break
+		}
+	}
+
+	if mr.retryBackoffMax > 0 && delay > mr.retryBackoffMax {
+		delay = mr.retryBackoffMax
+	}
+
+	// shorten the delay by a random part, so messages failing at the same time are not re-enqueued all at once
+	if mr.retryJitter > 0 {
+		delay -= time.Duration(mr.retryJitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// commitReady commits a message whose predecessors are all handled, or collects it for the next batch commit
+func (mr *missyReader) commitReady(m Message) {
+	if mr.batcher != nil {
+		mr.batcher.add(m)
+		return
+	}
+	mr.commit(m)
+}
+
+// commit commits handled messages, they are committed even if the reading context has been cancelled meanwhile
+func (mr *missyReader) commit(msgs ...Message) {
+	if err := mr.commitWithPolicy(msgs); err != nil {
+		for i, m := range msgs {
+			mr.messageLogger(m).Errorf("# messaging # cannot commit message: %v", err)
+			mr.reportError(CommitError, &msgs[i], err)
+		}
+		return
+	}
+	for _, m := range msgs {
+		getReaderMetrics().onCommitted(m)
+	}
+	mr.notifyCommitted(msgs)
+}
+
+// notifyCommitted releases the committed messages from the limit of uncommitted messages and calls the commit callback
+// for every committed message
+func (mr *missyReader) notifyCommitted(msgs []Message) {
+	if mr.uncommitted != nil {
+		mr.uncommitted.committed(msgs)
+	}
+	if mr.commitCallback == nil {
+		return
+	}
+	for _, m := range msgs {
+		mr.commitCallback(m.Topic, m.Partition, m.Offset)
+	}
+}
+
+// Topic returns the topic the reader reads, it is empty for readers of multiple topics
+func (mr *missyReader) Topic() string {
+	return mr.topic
+}
+
+// GroupID returns the consumer group of the reader, it is empty for readers of a single partition
+func (mr *missyReader) GroupID() string {
+	return mr.groupID
+}
+
+// Brokers returns a copy of the brokers the reader connects to
+func (mr *missyReader) Brokers() []string {
+	return append([]string(nil), mr.brokers...)
+}
+
+// Stats returns the statistics of the underlying kafka reader. Counters like the number of fetched messages and bytes
+// count since the previous call of Stats, they are reset on every call.
+func (mr *missyReader) Stats() kafka.ReaderStats {
+	mr.statsMu.Lock()
+	stats := mr.brokerReader.Stats()
+	rebalances := stats.Rebalances
+	mr.brokerFetched = mr.brokerFetched || stats.Fetches > 0
+	// include what has been collected while watching for rebalances since the previous call
+	stats = addReaderStats(mr.unreported, stats)
+	mr.unreported = kafka.ReaderStats{}
+	mr.statsMu.Unlock()
+
+	mr.rebalanced(rebalances)
+	return stats
+}
+
+// CommitMessages commits the given messages to the broker. It is meant to be used with WithManualCommit, committing
+// a message commits all previous messages of the same partition as well.
+func (mr *missyReader) CommitMessages(msgs ...Message) error {
+	if err := mr.brokerReader.CommitMessages(context.Background(), msgs...); err != nil {
+		return err
+	}
+	mr.notifyCommitted(msgs)
 	return nil
 }
 
-// Close used to close underlying connection with broker
+// CommitOffset commits the offset of the last processed message of a partition, like committing that message with
+// CommitMessages, for sinks which keep track of their positions themselves. The partition must have been assigned to
+// the reader, that is a message of it has been fetched since the last rebalance, and offset must not be greater than
+// the offset of the last fetched message of the partition.
+func (mr *missyReader) CommitOffset(topic string, partition int, offset int64) error {
+	mr.mu.Lock()
+	closed := mr.closed
+	mr.mu.Unlock()
+	if closed {
+		return ErrReaderClosed
+	}
+
+	mr.assignedMu.Lock()
+	fetched, ok := mr.assigned[partitionKey{topic: topic, partition: partition}]
+	mr.assignedMu.Unlock()
+	if !ok {
+		return fmt.Errorf("cannot commit partition %d of topic %s: %w", partition, topic, ErrNotAssigned)
+	}
+	if offset < 0 || offset > fetched {
+		return fmt.Errorf("cannot commit offset %d of partition %d of topic %s, the last fetched offset is %d", offset, partition, topic, fetched)
+	}
+
+	return mr.CommitMessages(Message{Topic: topic, Partition: partition, Offset: offset})
+}
+
+// assign records the offset of a fetched message as the last fetched one of its partition. The assignment callback
+// is called before the first message of a partition is processed.
+func (mr *missyReader) assign(m Message) {
+	mr.assignedMu.Lock()
+	if mr.assigned == nil {
+		mr.assigned = make(map[partitionKey]int64)
+	}
+	key := partitionKey{topic: m.Topic, partition: m.Partition}
+	offset, ok := mr.assigned[key]
+	if !ok || m.Offset > offset {
+		mr.assigned[key] = m.Offset
+	}
+	mr.assignedMu.Unlock()
+
+	if !ok && mr.assignmentCallback != nil {
+		mr.assignmentCallback([]int{m.Partition}, nil)
+	}
+}
+
+// unassign forgets the partitions fetched from before n rebalances, they may belong to another member of the group
+// now, and reports them as revoked to the assignment callback. The first rebalance is joining the group.
+func (mr *missyReader) unassign(n int64) {
+	mr.assignedMu.Lock()
+	var revoked []int
+	if mr.joined || n > 1 {
+		revoked = assignedPartitions(mr.assigned)
+		mr.assigned = nil
+	}
+	mr.joined = true
+	mr.assignedMu.Unlock()
+
+	if len(revoked) > 0 && mr.assignmentCallback != nil {
+		mr.assignmentCallback(nil, revoked)
+	}
+}
+
+// retriesExhausted reports whether a message has used up its retries or has been retried for longer than
+// the maximum retry age, whichever comes first. With 0 retries a failed message is exhausted right away, with
+// InfiniteRetries only the maximum retry age applies.
+func (mr *missyReader) retriesExhausted(m Message) bool {
+	if mr.numOfRetries != InfiniteRetries && m.RetryCounter >= mr.numOfRetries {
+		return true
+	}
+	if mr.maxRetryAge <= 0 {
+		return false
+	}
+	first := originalTime(m)
+	return !first.IsZero() && time.Since(first) > mr.maxRetryAge
+}
+
+// retry re-enqueues a failed message with an incremented retry counter or sends it to the DLQ once all retries are used up
+// or msgFunc returned ErrSendToDLQ.
+// It returns an error if the message could not be written, in that case the message must not be committed.
+func (mr *missyReader) retry(m Message, processErr error) error {
+	exhausted := mr.retriesExhausted(m) || errors.Is(processErr, ErrSendToDLQ)
+	logger := mr.messageLogger(m)
+	var dlqMsg Message
+	if exhausted {
+		dlqMsg = dlqMessage(m, processErr)
+		if mr.dlqCallback != nil && !mr.dlqCallback(&dlqMsg, processErr) {
+			logger.Warnf("# messaging # retries exhausted, dropping message as requested by the DLQ callback")
+			return nil
+		}
+	}
+	if exhausted && mr.dlqDisabled {
+		logger.Errorf("# messaging # retries exhausted, dropping message")
+		return nil
+	}
+
+	if exhausted {
+		dlqTopic, dlqWriter := mr.dlqWriterFor(m)
+		logger.Errorf("# messaging # retries exhausted, sending message to the dead letter queue %s", dlqTopic)
+		if err := dlqWriter.WriteMessage(dlqMsg); err != nil {
+			logger.Errorf("# messaging # cannot write message to the dead letter queue: %v", err)
+			mr.reportError(DLQError, &m, err)
+			return err
+		}
+		getReaderMetrics().onDLQ(m)
+		return nil
+	}
+
+	if err := mr.writerFor(m).WriteMessage(retryMessage(m)); err != nil {
+		logger.Errorf("# messaging # cannot re-enqueue message for retry: %v", err)
+		mr.reportError(RetryError, &m, err)
+		return err
+	}
+	getReaderMetrics().onRetried(m)
+	return nil
+}
+
+// writerFor returns the writer re-enqueuing messages to the topic chosen by the retry topic func or to the topic m has
+// been read from
+func (mr *missyReader) writerFor(m Message) Writer {
+	if mr.retryTopicFunc != nil {
+		if topic := mr.retryTopicFunc(m.RetryCounter + 1); topic != "" {
+			return mr.retryTopicWriter(topic)
+		}
+	}
+	if w, ok := mr.writers[m.Topic]; ok {
+		return w
+	}
+	return mr.writer
+}
+
+// retryTopicWriter returns the writer of a topic returned by the retry topic func, it is created on first use
+func (mr *missyReader) retryTopicWriter(topic string) Writer {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if w, ok := mr.retryTopicWriters[topic]; ok {
+		return w
+	}
+	if mr.retryTopicWriters == nil {
+		mr.retryTopicWriters = make(map[string]Writer)
+	}
+	w := mr.newWriter(topic)
+	mr.retryTopicWriters[topic] = w
+	return w
+}
+
+// dlqWriterFor returns the DLQ of the topic m has been read from and its writer
+func (mr *missyReader) dlqWriterFor(m Message) (string, Writer) {
+	if w, ok := mr.dlqWriters[m.Topic]; ok {
+		return m.Topic + ".dlq", w
+	}
+	return mr.dlqTopic, mr.dlqWriter
+}
+
+// flushCommits commits all messages collected for the next batch commit
+func (mr *missyReader) flushCommits() {
+	if mr.batcher != nil {
+		mr.batcher.flush()
+	}
+}
+
+// resetReadFunc marks the reader as not reading anymore
+func (mr *missyReader) resetReadFunc() {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.readFunc = nil
+}
+
+// Close stops the reading goroutine and waits up to closeTimeout for it to return. Delayed retries are re-enqueued
+// right away. Afterwards the connections of the reader and its retry and DLQ writers are closed. A closed reader
+// cannot be restarted, Read and ReadContext return ErrReaderClosed.
 func (mr *missyReader) Close() error {
-	return mr.brokerReader.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	mr.cancelProcessing()
+	if err := mr.stop(ctx); err != nil {
+		mr.getLogger().Warnf("# messaging # reading goroutine did not stop within %s", closeTimeout)
+	}
+
+	return mr.closeConnections()
+}
+
+// Shutdown stops fetching new messages and waits until the message being processed, or those of all workers, have
+// been handled and committed before the connections are closed. If ctx expires first, the connections are closed
+// anyway and ctx.Err() is returned. Like after Close, the reader cannot be restarted.
+func (mr *missyReader) Shutdown(ctx context.Context) error {
+	err := mr.stop(ctx)
+	if err != nil {
+		mr.cancelProcessing()
+	}
+	if cErr := mr.closeConnections(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// cancelProcessing cancels the context passed to msgFunc
+func (mr *missyReader) cancelProcessing() {
+	mr.mu.Lock()
+	cancelProc := mr.cancelProc
+	mr.mu.Unlock()
+
+	if cancelProc != nil {
+		cancelProc()
+	}
+}
+
+// stop marks the reader as closed, stops the reading goroutine and waits until it returned or ctx expires
+func (mr *missyReader) stop(ctx context.Context) error {
+	mr.mu.Lock()
+	mr.closed = true
+	cancel, done := mr.cancel, mr.done
+	mr.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	var err error
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	mr.resetReadFunc()
+
+	return err
+}
+
+// closeConnections closes the connections of the reader and its retry and DLQ writers
+func (mr *missyReader) closeConnections() error {
+	err := mr.brokerReader.Close()
+	// injected writers may be shared with other readers, they are closed by their owner
+	var writers []Writer
+	if !mr.writerInjected {
+		writers = append(writers, mr.writer)
+	}
+	if !mr.dlqWriterInjected {
+		writers = append(writers, mr.dlqWriter)
+	}
+	for _, w := range mr.writers {
+		writers = append(writers, w)
+	}
+	for _, w := range mr.dlqWriters {
+		writers = append(writers, w)
+	}
+	mr.mu.Lock()
+	for _, w := range mr.retryTopicWriters {
+		writers = append(writers, w)
+	}
+	mr.mu.Unlock()
+	for _, w := range writers {
+		if w == nil {
+			continue
+		}
+		if wErr := w.Close(); wErr != nil && err == nil {
+			err = wErr
+		}
+	}
+
+	return err
 }
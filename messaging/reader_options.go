@@ -0,0 +1,462 @@
+package messaging
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/microdevs/missy/log"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// defaultMinBytes is the default minimum batch size the reader fetches from the broker
+const defaultMinBytes = 10e3 // 10KB
+
+// defaultMaxBytes is the default maximum batch size the reader fetches from the broker
+const defaultMaxBytes = 10e6 // 10MB
+
+// defaultSessionTimeout is the session timeout kafka-go uses if none is set
+const defaultSessionTimeout = 30 * time.Second
+
+// defaultHeartbeatInterval is the heartbeat interval kafka-go uses if none is set
+const defaultHeartbeatInterval = 3 * time.Second
+
+// ReaderOption configures the Reader returned by NewReader
+type ReaderOption func(*missyReader)
+
+// WithMinBytes sets the minimum batch size the reader waits for before a fetch returns
+func WithMinBytes(minBytes int) ReaderOption {
+	return func(mr *missyReader) {
+		mr.config.MinBytes = minBytes
+	}
+}
+
+// WithMaxBytes sets the maximum batch size the reader accepts from the broker in a single fetch
+func WithMaxBytes(maxBytes int) ReaderOption {
+	return func(mr *missyReader) {
+		mr.config.MaxBytes = maxBytes
+	}
+}
+
+// WithManualCommit disables committing messages after msgFunc returns. The application has to commit messages itself
+// with Reader.CommitMessages. Messages which have not been committed when the process dies are delivered again to the
+// consumer group, starting after the last committed offset of each partition.
+func WithManualCommit() ReaderOption {
+	return func(mr *missyReader) {
+		mr.manualCommit = true
+	}
+}
+
+// WithConcurrency processes up to n messages at the same time with a pool of n workers. Messages are still committed
+// in the order they have been fetched, a message is only committed when all previous messages of its partition are done.
+func WithConcurrency(n int) ReaderOption {
+	return func(mr *missyReader) {
+		if n < 1 {
+			log.Panicf("# messaging # invalid concurrency %d: must be at least 1", n)
+		}
+		mr.concurrency = n
+	}
+}
+
+// WithKeyOrdering makes the workers of WithConcurrency process messages with the same key one after another in the
+// order they have been fetched, while messages with different keys are still processed at the same time. Messages are
+// assigned to a worker by the hash of their key, so a slow message delays the following messages of its worker.
+// A single worker keeps the order anyway, so NewReader panics without WithConcurrency of more than 1.
+func WithKeyOrdering() ReaderOption {
+	return func(mr *missyReader) {
+		mr.keyOrdering = true
+	}
+}
+
+// WithRateLimit calls msgFunc at most perSecond times per second. The reader waits before fetching the next message,
+// so messages are delayed and never dropped, waiting stops when reading is stopped.
+func WithRateLimit(perSecond int) ReaderOption {
+	return func(mr *missyReader) {
+		if perSecond <= 0 {
+			log.Panicf("# messaging # invalid rate limit %d: must be greater than 0", perSecond)
+		}
+		mr.rateLimiter = newRateLimiter(perSecond)
+	}
+}
+
+// WithCircuitBreaker pauses fetching after threshold consecutive failures of msgFunc, e.g. while a downstream system is
+// down, instead of retrying every message until it ends up in the DLQ. After cooldown a single message is fetched to
+// probe: if it succeeds fetching continues, if it fails fetching is paused for another cooldown. Messages dropped with
+// ErrDrop or sent to the DLQ with ErrSendToDLQ do not count as failures.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		if threshold <= 0 {
+			log.Panicf("# messaging # invalid circuit breaker threshold %d: must be greater than 0", threshold)
+		}
+		mr.breaker = newCircuitBreaker(threshold, cooldown, mr.breakerChanged)
+	}
+}
+
+// WithPollTimeout stops waiting for the next message after d, the reader calls the callback of WithIdleCallback and
+// waits again. A poll timeout is not a fetch error, it is neither logged nor emitted on Errors.
+func WithPollTimeout(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		if d <= 0 {
+			log.Panicf("# messaging # invalid poll timeout %s: must be greater than 0", d)
+		}
+		mr.pollTimeout = d
+	}
+}
+
+// WithIdleCallback calls fn whenever no message arrived within the poll timeout, e.g. to run periodic maintenance while
+// a topic is empty. It requires WithPollTimeout and is called by the reading goroutine, so no message is fetched until
+// fn returns.
+func WithIdleCallback(fn func()) ReaderOption {
+	return func(mr *missyReader) {
+		mr.idleCallback = fn
+	}
+}
+
+// WithRebalanceCallback calls fn after the partitions of the consumer group have been assigned again, including the
+// first assignment after joining the group, e.g. to flush state kept per partition. Rebalances are detected by polling
+// the stats of the kafka reader every second, so fn is called up to a second late and messages fetched before the
+// rebalance may already be delivered to another member of the group.
+func WithRebalanceCallback(fn RebalanceCallback) ReaderOption {
+	return func(mr *missyReader) {
+		mr.rebalanceCallback = fn
+	}
+}
+
+// WithAssignmentCallback calls fn when the partitions owned by the reader change, e.g. to load and evict state kept per
+// partition. kafka-go does not expose the assignments of a generation, so they are derived from the fetched messages and
+// the rebalances counted in its stats: a partition is reported as assigned right before its first message is
+// processed, and all partitions are reported as revoked once a rebalance has been detected, up to a second late.
+// Partitions without messages are not reported.
+func WithAssignmentCallback(fn AssignmentCallback) ReaderOption {
+	return func(mr *missyReader) {
+		mr.assignmentCallback = fn
+	}
+}
+
+// WithFailFast stops reading if the first message cannot be fetched instead of retrying, the error is sent on Done.
+// It makes misconfigured brokers or credentials fail at startup, later fetch errors are still retried.
+func WithFailFast() ReaderOption {
+	return func(mr *missyReader) {
+		mr.failFast = true
+	}
+}
+
+// WithFetchBackoff sets how long the reader waits before fetching again after a fetch error, e.g. while a broker is
+// restarted. The first retry waits min, every further one twice as long as the previous one, but never longer than
+// max. The delay starts at min again after a successful fetch. Defaults to 100ms and 10s.
+func WithFetchBackoff(min time.Duration, max time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		if min <= 0 || max < min {
+			log.Panicf("# messaging # invalid fetch backoff %s to %s: min must be greater than 0 and not greater than max", min, max)
+		}
+		mr.fetchBackoffMin = min
+		mr.fetchBackoffMax = max
+	}
+}
+
+// WithRetries sets how many times a failed message is retried before it goes to the DLQ, overriding
+// MESSAGING_NUMBER_OF_RETRIES and the default of 5. 0 sends failed messages to the DLQ right away, InfiniteRetries
+// retries them until they succeed, so they only go to the DLQ with ErrSendToDLQ or WithMaxRetryAge.
+func WithRetries(n int) ReaderOption {
+	return func(mr *missyReader) {
+		if n < InfiniteRetries {
+			log.Panicf("# messaging # invalid number of retries %d: must not be negative or InfiniteRetries", n)
+		}
+		mr.numOfRetries = n
+	}
+}
+
+// WithRetryBackoff delays re-enqueuing a failed message. The first retry waits base, every further retry of the same
+// message waits twice as long as the previous one, but never longer than max. Other messages are read meanwhile.
+func WithRetryBackoff(base time.Duration, max time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		mr.retryBackoffBase = base
+		mr.retryBackoffMax = max
+	}
+}
+
+// WithInPlaceRetry retries a failed message by calling msgFunc again for the same offset instead of re-enqueuing it to
+// the topic, so it is not reordered relative to the following messages of its partition. The retries wait with
+// WithRetryBackoff, by default starting at 100ms, and the message goes to the DLQ once its retries are exhausted.
+// kafka-go cannot pause single partitions, so no further message is processed while a message is retried, all
+// partitions of the reader wait for it. With WithConcurrency it requires WithKeyOrdering, NewReader panics without
+// it: the messages with the key of the retried message wait for it, while the other workers keep processing.
+func WithInPlaceRetry() ReaderOption {
+	return func(mr *missyReader) {
+		mr.inPlaceRetry = true
+	}
+}
+
+// WithRetryJitter randomizes the delays of WithRetryBackoff, each delay is shortened by a random part of up to
+// fraction of it. Spreading the retries avoids re-enqueuing all messages of an outage at the same time.
+func WithRetryJitter(fraction float64) ReaderOption {
+	return func(mr *missyReader) {
+		if fraction < 0 || fraction > 1 {
+			log.Panicf("# messaging # invalid retry jitter %v: must be between 0 and 1", fraction)
+		}
+		mr.retryJitter = fraction
+	}
+}
+
+// WithMaxRetryAge sends a failed message to the DLQ once d has passed since it has originally been written, even if it
+// has retries left. It is combined with the number of retries, whichever limit is reached first applies.
+func WithMaxRetryAge(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		mr.maxRetryAge = d
+	}
+}
+
+// WithProcessTimeout cancels the context passed to a ReadMessageFuncCtx after d, an error returned after the timeout is
+// retried like any other error. Handlers not respecting the context are not interrupted.
+func WithProcessTimeout(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		mr.processTimeout = d
+	}
+}
+
+// WithPanicRecovery toggles recovering from panics in msgFunc, which is enabled by default. A recovered panic is logged
+// with its stack trace and the message is retried like after an error. Disable it to let panics crash the process.
+func WithPanicRecovery(enabled bool) ReaderOption {
+	return func(mr *missyReader) {
+		mr.panicRecoveryDisabled = !enabled
+	}
+}
+
+// WithDedup skips messages whose id has already been processed within window and commits them right away. The id is
+// read from keyHeader, MessageIDHeader if it is empty, messages without it are always processed. Deduplication is
+// best-effort: the ids are only remembered in memory of this process, not across restarts or consumer group members.
+func WithDedup(window time.Duration, keyHeader string) ReaderOption {
+	return func(mr *missyReader) {
+		mr.dedup = newDedupCache(window, keyHeader)
+	}
+}
+
+// WithFilter commits messages for which filter returns false without calling msgFunc, they are counted in
+// missy_messaging_messages_filtered_total. Unlike returning ErrSkip from msgFunc, filtered messages never reach the
+// retry and DLQ handling. With WithManualCommit their offset is committed with the next message the application commits.
+func WithFilter(filter func(Message) bool) ReaderOption {
+	return func(mr *missyReader) {
+		mr.filter = filter
+	}
+}
+
+// WithPrefetch fetches up to n messages ahead while msgFunc is processing, so slow fetches do not add to the processing
+// time. The messages waiting in the buffer are exported as missy_messaging_prefetch_buffer_messages, a full buffer
+// means processing is the bottleneck. They are still committed in fetch order, and delivered again if reading stops
+// before they are processed.
+func WithPrefetch(n int) ReaderOption {
+	return func(mr *missyReader) {
+		if n <= 0 {
+			log.Panicf("# messaging # invalid prefetch %d: must be greater than 0", n)
+		}
+		mr.prefetch = n
+	}
+}
+
+// WithDeserializer decodes message values passed to Reader.Decode with d, e.g. JSONSerializer or an AvroSerializer.
+// By default Decode only reads values unchanged into a *[]byte.
+func WithDeserializer(d Deserializer) ReaderOption {
+	return func(mr *missyReader) {
+		mr.deserializer = d
+	}
+}
+
+// WithRetryWriter re-enqueues failed messages with w instead of a writer created for the topic of the reader, e.g. to
+// share its connection between readers. The reader does not close w. With NewMultiTopicReader, w is used for all
+// topics, so all retries go to the topic of w.
+func WithRetryWriter(w Writer) ReaderOption {
+	return func(mr *missyReader) {
+		mr.writer = w
+		mr.writerInjected = true
+	}
+}
+
+// WithRetryTopicFunc re-enqueues failed messages to the topic returned by fn instead of the topic they have been read
+// from, e.g. to delay later attempts with "orders.retry.1m" and "orders.retry.10m" topics read by their own readers.
+// fn gets the number of the attempt the message is re-enqueued for, starting at 1, an empty topic re-enqueues to the
+// topic of the message. Once the retries are used up, messages still go to the DLQ. It takes precedence over
+// WithRetryWriter, writers for the returned topics are created by the reader.
+func WithRetryTopicFunc(fn func(attempt int) string) ReaderOption {
+	return func(mr *missyReader) {
+		if fn == nil {
+			log.Panicf("# messaging # invalid retry topic func: must not be nil")
+		}
+		mr.retryTopicFunc = fn
+	}
+}
+
+// WithDLQWriter writes messages to the DLQ with w instead of a writer created for the DLQ topic. The reader does not
+// close w. It is ignored if the DLQ is disabled.
+func WithDLQWriter(w Writer) ReaderOption {
+	return func(mr *missyReader) {
+		mr.dlqWriter = w
+		mr.dlqWriterInjected = true
+	}
+}
+
+// WithDLQTopic sets the topic messages are written to once their retries are used up, defaults to <topic>.dlq
+func WithDLQTopic(name string) ReaderOption {
+	return func(mr *missyReader) {
+		mr.dlqTopic = name
+	}
+}
+
+// WithDLQCallback calls fn for every message whose retries are used up right before it is written to the DLQ, e.g. to
+// page someone. fn may change the message or return false to drop it instead, dropped messages are committed. With
+// WithDLQDisabled, fn is called as well but the message is dropped anyway.
+func WithDLQCallback(fn DLQCallback) ReaderOption {
+	return func(mr *missyReader) {
+		mr.dlqCallback = fn
+	}
+}
+
+// WithDLQDisabled drops messages once their retries are used up instead of writing them to a dead letter queue.
+// Dropped messages are logged and committed.
+func WithDLQDisabled() ReaderOption {
+	return func(mr *missyReader) {
+		mr.dlqDisabled = true
+	}
+}
+
+// WithTLS connects the reader and its retry and DLQ writers to the brokers using TLS
+func WithTLS(config *tls.Config) ReaderOption {
+	return func(mr *missyReader) {
+		mr.config.Dialer = withTLS(mr.config.Dialer, config)
+	}
+}
+
+// WithSASL authenticates the reader and its retry and DLQ writers with the brokers using the given SASL mechanism,
+// e.g. plain.Mechanism or a scram mechanism
+func WithSASL(mechanism sasl.Mechanism) ReaderOption {
+	return func(mr *missyReader) {
+		mr.config.Dialer = withSASL(mr.config.Dialer, mechanism)
+	}
+}
+
+// WithCommitFailurePolicy sets what happens when committing handled messages fails: CommitFailureRetry (the default)
+// retries the commit a few times, CommitFailureReport sends the error to the errors channel right away and
+// CommitFailurePanic crashes the process. Failures which are not retried or whose retries failed are reported as
+// CommitError on the errors channel.
+func WithCommitFailurePolicy(policy CommitFailurePolicy) ReaderOption {
+	return func(mr *missyReader) {
+		if policy < CommitFailureRetry || policy > CommitFailurePanic {
+			log.Panicf("# messaging # invalid commit failure policy %d", policy)
+		}
+		mr.commitFailurePolicy = policy
+	}
+}
+
+// CommitCallback is called with the offset of every message after it has been committed
+type CommitCallback func(topic string, partition int, offset int64)
+
+// WithCommitCallback calls fn synchronously after every successful commit, so without WithConcurrency and commit
+// batching it returns before the next message is fetched. fn is called in offset order per partition, e.g. to store
+// the offset alongside the state of the application. A panic in fn crashes the consume loop unless fn recovers it.
+func WithCommitCallback(fn CommitCallback) ReaderOption {
+	return func(mr *missyReader) {
+		mr.commitCallback = fn
+	}
+}
+
+// WithCommitBatchSize commits handled messages in batches of n instead of one by one. Messages are still committed in
+// order and pending messages are committed when reading stops. If the process dies, up to n handled messages per
+// reader have not been committed yet and are delivered again.
+func WithCommitBatchSize(n int) ReaderOption {
+	return func(mr *missyReader) {
+		mr.commitBatchSize = n
+	}
+}
+
+// WithCommitInterval commits handled messages every interval d instead of one by one. It can be combined with
+// WithCommitBatchSize, whichever comes first triggers the commit. If the process dies, the messages handled since the
+// last commit are delivered again.
+func WithCommitInterval(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		mr.commitInterval = d
+	}
+}
+
+// WithMaxUncommitted stops fetching while n messages have been processed but not committed yet, until some of them
+// are committed. It bounds the memory held for uncommitted messages if commits stall, e.g. with WithManualCommit when
+// the application does not commit, or with WithCommitBatchSize and WithCommitInterval while commits keep failing.
+// Without a commit interval n must be greater than the commit batch size, otherwise a batch is never complete.
+func WithMaxUncommitted(n int) ReaderOption {
+	return func(mr *missyReader) {
+		if n <= 0 {
+			log.Panicf("# messaging # invalid max uncommitted messages %d: must be greater than 0", n)
+		}
+		mr.maxUncommitted = n
+	}
+}
+
+// WithStartOffset sets where a consumer group starts reading partitions without a committed offset, either
+// kafka.FirstOffset to replay all messages or kafka.LastOffset to only read new messages. Partitions with a committed
+// offset continue after it, use Reader.SetOffset to move it.
+func WithStartOffset(offset int64) ReaderOption {
+	return func(mr *missyReader) {
+		if offset != kafka.FirstOffset && offset != kafka.LastOffset {
+			log.Panicf("# messaging # invalid start offset %d: must be kafka.FirstOffset or kafka.LastOffset", offset)
+		}
+		mr.config.StartOffset = offset
+	}
+}
+
+// WithGroupBalancers sets the strategies the consumer group uses to assign partitions to its members, in order of
+// preference, e.g. kafka.RoundRobinGroupBalancer{} or kafka.RackAffinityGroupBalancer{}. All members of a group have to
+// support the chosen strategy. Defaults to the balancers of kafka-go, range and round-robin.
+func WithGroupBalancers(balancers ...kafka.GroupBalancer) ReaderOption {
+	return func(mr *missyReader) {
+		if len(balancers) == 0 {
+			log.Panicf("# messaging # invalid group balancers: at least one is required")
+		}
+		for _, b := range balancers {
+			if b == nil {
+				log.Panicf("# messaging # invalid group balancers: must not be nil")
+			}
+		}
+		mr.config.GroupBalancers = balancers
+	}
+}
+
+// WithSessionTimeout sets how long the consumer group waits for heartbeats of the reader before it considers the reader
+// dead and assigns its partitions to other members. Short timeouts detect crashed readers faster, but readers missing a
+// few heartbeats, e.g. during a long GC pause, are kicked out. The broker only accepts timeouts between its
+// group.min.session.timeout.ms and group.max.session.timeout.ms, 6s and 30m by default. Defaults to 30s.
+func WithSessionTimeout(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		if d <= 0 {
+			log.Panicf("# messaging # invalid session timeout %s: must be greater than 0", d)
+		}
+		mr.config.SessionTimeout = d
+	}
+}
+
+// WithHeartbeatInterval sets how often the reader sends heartbeats to the consumer group, it has to be lower than the
+// session timeout, usually a third of it. Defaults to 3s.
+func WithHeartbeatInterval(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		if d <= 0 {
+			log.Panicf("# messaging # invalid heartbeat interval %s: must be greater than 0", d)
+		}
+		mr.config.HeartbeatInterval = d
+	}
+}
+
+// WithRebalanceTimeout sets how long the consumer group waits for all members to rejoin during a rebalance, members
+// which do not rejoin in time are removed from the group. Short timeouts make rebalances finish faster when a member
+// is gone. Defaults to 30s.
+func WithRebalanceTimeout(d time.Duration) ReaderOption {
+	return func(mr *missyReader) {
+		if d <= 0 {
+			log.Panicf("# messaging # invalid rebalance timeout %s: must be greater than 0", d)
+		}
+		mr.config.RebalanceTimeout = d
+	}
+}
+
+// WithLogger logs with logger instead of missy/log, the retry and DLQ writers created by the reader use it as well
+func WithLogger(logger Logger) ReaderOption {
+	return func(mr *missyReader) {
+		mr.logger = logger
+	}
+}
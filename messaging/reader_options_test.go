@@ -0,0 +1,249 @@
+package messaging
+
+import (
+	"crypto/tls"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+func TestNewReader_DefaultBytes(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "", "test").(*missyReader)
+
+	if r.config.MinBytes != defaultMinBytes {
+		t.Error(expected(strconv.Itoa(r.config.MinBytes), strconv.Itoa(defaultMinBytes)))
+	}
+	if r.config.MaxBytes != defaultMaxBytes {
+		t.Error(expected(strconv.Itoa(r.config.MaxBytes), strconv.Itoa(defaultMaxBytes)))
+	}
+}
+
+func TestNewReader_WithMinMaxBytes(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "", "test", WithMinBytes(1), WithMaxBytes(1e3)).(*missyReader)
+
+	if r.config.MinBytes != 1 {
+		t.Error(expected(strconv.Itoa(r.config.MinBytes), "1"))
+	}
+	if r.config.MaxBytes != 1e3 {
+		t.Error(expected(strconv.Itoa(r.config.MaxBytes), "1000"))
+	}
+}
+
+func TestNewReader_MinBytesGreaterThanMaxBytes(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("NewReader should panic if MinBytes is greater than MaxBytes")
+		}
+	}()
+
+	NewReader([]string{"localhost:9091"}, "", "test", WithMinBytes(1e3), WithMaxBytes(1))
+}
+
+func TestNewReader_WithGroupBalancers(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "group", "test").(*missyReader)
+	if r.config.GroupBalancers != nil {
+		t.Errorf("the default balancers of kafka-go should be used, got %v", r.config.GroupBalancers)
+	}
+
+	balancers := []kafka.GroupBalancer{kafka.RoundRobinGroupBalancer{}, kafka.RangeGroupBalancer{}}
+	r = NewReader([]string{"localhost:9091"}, "group", "test", WithGroupBalancers(balancers...)).(*missyReader)
+	if !reflect.DeepEqual(r.config.GroupBalancers, balancers) {
+		t.Errorf("expected group balancers %v, got %v", balancers, r.config.GroupBalancers)
+	}
+}
+
+func TestNewReader_WithoutGroupBalancers(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithGroupBalancers should panic without a balancer")
+		}
+	}()
+
+	WithGroupBalancers()(&missyReader{})
+}
+
+func TestNewReader_WithGroupTimeouts(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "group", "test",
+		WithSessionTimeout(10*time.Second), WithHeartbeatInterval(2*time.Second), WithRebalanceTimeout(15*time.Second)).(*missyReader)
+
+	if r.config.SessionTimeout != 10*time.Second || r.config.HeartbeatInterval != 2*time.Second || r.config.RebalanceTimeout != 15*time.Second {
+		t.Errorf("unexpected session timeout %s, heartbeat interval %s and rebalance timeout %s", r.config.SessionTimeout, r.config.HeartbeatInterval, r.config.RebalanceTimeout)
+	}
+	if kr := r.brokerReader.(*readBroker).Config(); kr.SessionTimeout != 10*time.Second || kr.HeartbeatInterval != 2*time.Second || kr.RebalanceTimeout != 15*time.Second {
+		t.Errorf("the timeouts should be passed to the kafka reader, got %+v", kr)
+	}
+}
+
+func TestNewReader_InvalidGroupTimeouts(t *testing.T) {
+	for name, opts := range map[string][]ReaderOption{
+		"session timeout":                 {WithSessionTimeout(0)},
+		"heartbeat interval":              {WithHeartbeatInterval(-time.Second)},
+		"rebalance timeout":               {WithRebalanceTimeout(0)},
+		"heartbeat above session timeout": {WithSessionTimeout(5 * time.Second), WithHeartbeatInterval(5 * time.Second)},
+		"heartbeat above default timeout": {WithHeartbeatInterval(time.Minute)},
+		"session below default heartbeat": {WithSessionTimeout(time.Second)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("NewReader should panic for invalid timeouts")
+				}
+			}()
+
+			NewReader([]string{"localhost:9091"}, "group", "test", opts...)
+		})
+	}
+}
+
+func TestNewReader_DLQTopic(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "", "test").(*missyReader)
+	if r.dlqTopic != "test.dlq" {
+		t.Error(expected(r.dlqTopic, "test.dlq"))
+	}
+
+	r = NewReader([]string{"localhost:9091"}, "", "test", WithDLQTopic("dlq.service.test")).(*missyReader)
+	if r.dlqTopic != "dlq.service.test" {
+		t.Error(expected(r.dlqTopic, "dlq.service.test"))
+	}
+}
+
+func TestNewReader_DLQDisabled(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "", "test", WithDLQDisabled()).(*missyReader)
+	if r.dlqWriter != nil {
+		t.Error("no DLQ writer should be created if the DLQ is disabled")
+	}
+}
+
+func TestNewReader_WithTLSAndSASL(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "kafka"}
+	mechanism := plain.Mechanism{Username: "user", Password: "secret"}
+
+	r := NewReader([]string{"localhost:9091"}, "", "test", WithTLS(tlsConfig), WithSASL(mechanism)).(*missyReader)
+
+	if r.config.Dialer == nil || r.config.Dialer.TLS != tlsConfig || r.config.Dialer.SASLMechanism != mechanism {
+		t.Fatal("TLS and SASL were not set on the reader dialer")
+	}
+
+	// retry and DLQ writers connect the same way as the reader
+	for _, w := range []Writer{r.writer, r.dlqWriter} {
+		if w.(*lazyWriter).writer().(*missyWriter).config.Dialer != r.config.Dialer {
+			t.Error("the reader's writers should use the reader dialer")
+		}
+	}
+
+	if kafka.DefaultDialer.TLS != nil || kafka.DefaultDialer.SASLMechanism != nil {
+		t.Error("the kafka default dialer must not be modified")
+	}
+}
+
+func TestNewReader_PlaintextByDefault(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "", "test").(*missyReader)
+
+	if r.config.Dialer != nil {
+		t.Error("the reader should use the default dialer without TLS and SASL")
+	}
+}
+
+func TestNewReader_WithStartOffset(t *testing.T) {
+	r := NewReader([]string{"localhost:9091"}, "", "test", WithStartOffset(kafka.LastOffset)).(*missyReader)
+	if r.config.StartOffset != kafka.LastOffset {
+		t.Error(expected(strconv.FormatInt(r.config.StartOffset, 10), strconv.FormatInt(kafka.LastOffset, 10)))
+	}
+}
+
+func TestNewReader_WithInvalidStartOffset(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithStartOffset should panic on offsets other than kafka.FirstOffset and kafka.LastOffset")
+		}
+	}()
+
+	NewReader([]string{"localhost:9091"}, "", "test", WithStartOffset(42))
+}
+
+func TestWithConcurrencyInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithConcurrency should panic on less than 1 worker")
+		}
+	}()
+
+	WithConcurrency(0)(&missyReader{})
+}
+
+func TestNewReader_KeyOrderingWithoutConcurrency(t *testing.T) {
+	for _, opts := range [][]ReaderOption{{WithKeyOrdering()}, {WithKeyOrdering(), WithConcurrency(1)}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Error("WithKeyOrdering should panic without concurrent workers")
+				}
+			}()
+			NewReader([]string{"localhost:9091"}, "", "test", opts...)
+		}()
+	}
+}
+
+func TestWithRetryJitterInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithRetryJitter should panic on fractions outside of [0, 1]")
+		}
+	}()
+
+	WithRetryJitter(1.5)(&missyReader{})
+}
+
+func TestWithFetchBackoffInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithFetchBackoff should panic if max is less than min")
+		}
+	}()
+
+	WithFetchBackoff(time.Second, time.Millisecond)(&missyReader{})
+}
+
+func TestNewReader_WithInjectedWriters(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	// no Close expectations, injected writers are closed by their owner
+	writerMock := NewMockWriter(mockCtrl)
+	dlqWriterMock := NewMockWriter(mockCtrl)
+
+	r := NewReader([]string{"localhost:9091"}, "", "test", WithRetryWriter(writerMock), WithDLQWriter(dlqWriterMock)).(*missyReader)
+
+	if r.writer != writerMock || r.dlqWriter != dlqWriterMock {
+		t.Error("injected writers should be used instead of creating new ones")
+	}
+
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+	r.brokerReader = brokerReaderMock
+	if err := r.Close(); err != nil {
+		t.Errorf("there is an error during Close call")
+	}
+	mockCtrl.Finish()
+}
+
+func TestWithCommitFailurePolicyInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("an invalid commit failure policy should panic")
+		}
+	}()
+	WithCommitFailurePolicy(CommitFailurePolicy(42))(&missyReader{})
+}
+
+func TestWithPrefetchInvalid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("a prefetch of 0 should panic")
+		}
+	}()
+	WithPrefetch(0)(&missyReader{})
+}
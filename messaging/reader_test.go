@@ -3,7 +3,15 @@ package messaging
 import (
 	"context"
 	"fmt"
+	"io"
+	"math/rand"
+	"os"
 	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -28,148 +36,1415 @@ func TestNewReader(t *testing.T) {
 
 }
 
+func TestMissyReader_Accessors(t *testing.T) {
+	brokers := []string{"localhost:9091", "localhost:9092"}
+	r := NewReader(brokers, "group", "orders")
+	defer r.Close()
+
+	if r.Topic() != "orders" || r.GroupID() != "group" {
+		t.Errorf("unexpected topic %s and group id %s", r.Topic(), r.GroupID())
+	}
+	if !reflect.DeepEqual(r.Brokers(), brokers) {
+		t.Errorf("expected brokers %v, got %v", brokers, r.Brokers())
+	}
+
+	// the returned brokers are a copy
+	r.Brokers()[0] = "changed"
+	if r.Brokers()[0] != "localhost:9091" {
+		t.Error("changing the returned brokers should not change the reader")
+	}
+
+	multi := NewMultiTopicReader(brokers, "group", []string{"a", "b"})
+	defer multi.Close()
+	if multi.Topic() != "" {
+		t.Errorf("expected no topic for a reader of multiple topics, got %s", multi.Topic())
+	}
+}
+
+func TestNewReaderWithConfig(t *testing.T) {
+	config := kafka.ReaderConfig{
+		Brokers:           []string{"localhost:9091"},
+		GroupID:           "group",
+		Topic:             "test",
+		QueueCapacity:     10,
+		HeartbeatInterval: time.Second,
+	}
+	r := NewReaderWithConfig(config, WithDLQTopic("test.failed")).(*missyReader)
+
+	if r.config.QueueCapacity != 10 || r.config.HeartbeatInterval != time.Second {
+		t.Errorf("config should be used as is, got %+v", r.config)
+	}
+	if r.config.MinBytes != 0 || r.config.MaxBytes != 0 {
+		t.Errorf("the defaults of NewReader should not be applied, got MinBytes %d and MaxBytes %d", r.config.MinBytes, r.config.MaxBytes)
+	}
+	if r.groupID != "group" || r.topic != "test" {
+		t.Errorf("unexpected group id %s and topic %s", r.groupID, r.topic)
+	}
+	if r.writer == nil || r.dlqWriter == nil || r.dlqTopic != "test.failed" {
+		t.Error("retry and DLQ writers should be created")
+	}
+}
+
+func TestNewReaderWithConfig_MissingTopic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("NewReaderWithConfig should panic without a topic")
+		}
+	}()
+
+	NewReaderWithConfig(kafka.ReaderConfig{Brokers: []string{"localhost:9091"}})
+}
+
+func TestNewMultiTopicReader(t *testing.T) {
+	r := NewMultiTopicReader([]string{"localhost:9091"}, "group", []string{"a", "b"}).(*missyReader)
+
+	if len(r.config.GroupTopics) != 2 || r.config.Topic != "" {
+		t.Errorf("topics should be consumed as group topics, got %+v", r.config)
+	}
+	for _, topic := range []string{"a", "b"} {
+		if r.writers[topic] == nil || r.dlqWriters[topic] == nil {
+			t.Errorf("missing retry or DLQ writer for topic %s", topic)
+		}
+	}
+	if _, err := r.Lag(); err != errMultiTopic {
+		t.Errorf("expected errMultiTopic, got %v", err)
+	}
+
+	r = NewMultiTopicReader([]string{"localhost:9091"}, "group", []string{"a", "b"}, WithDLQTopic("all.dlq")).(*missyReader)
+	if len(r.dlqWriters) != 0 || r.dlqWriter == nil || r.dlqTopic != "all.dlq" {
+		t.Error("WithDLQTopic should set one DLQ for all topics")
+	}
+}
+
+func TestMissyReader_MultiTopicRouting(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	retryA := Message{Topic: "a", Key: []byte("retry-a")}
+	dlqB := Message{Topic: "b", Key: []byte("dlq-b"), RetryCounter: 5}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(retryA, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(dlqB, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), retryA).Return(nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), dlqB).Return(nil)
+
+	// only the writers of the topic a message has been read from are expected to be called
+	writerA, writerB := NewMockWriter(mockCtrl), NewMockWriter(mockCtrl)
+	writerA.EXPECT().WriteMessage(Message{Key: retryA.Key, RetryCounter: 1, Headers: traceHeaders(retryA)}).Return(nil)
+	dlqWriterA, dlqWriterB := NewMockWriter(mockCtrl), NewMockWriter(mockCtrl)
+	dlqWriterB.EXPECT().WriteMessage(gomock.Any()).DoAndReturn(func(m Message) error {
+		if record, _ := ParseDLQMessage(m); record.OriginalTopic != "b" {
+			t.Error(expected(record.OriginalTopic, "b"))
+		}
+		return nil
+	})
+
+	reader := missyReader{
+		brokerReader: brokerReaderMock,
+		numOfRetries: 5,
+		writers:      map[string]Writer{"a": writerA, "b": writerB},
+		dlqWriters:   map[string]Writer{"a": dlqWriterA, "b": dlqWriterB},
+	}
+	if err := reader.Read(func(msg Message) error { return errors.New("error") }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestNewReader_NumOfRetries(t *testing.T) {
+	os.Setenv(envNumberOfRetries, "3")
+	defer os.Unsetenv(envNumberOfRetries)
+
+	r := NewReader([]string{"localhost:9091"}, "", "test").(*missyReader)
+
+	if r.numOfRetries != 3 {
+		t.Error(expected(strconv.Itoa(r.numOfRetries), "3"))
+	}
+}
+
+func TestNewReader_NumOfRetriesDefault(t *testing.T) {
+	os.Unsetenv(envNumberOfRetries)
+
+	r := NewReader([]string{"localhost:9091"}, "", "test").(*missyReader)
+
+	if r.numOfRetries != defaultNumOfRetries {
+		t.Error(expected(strconv.Itoa(r.numOfRetries), strconv.Itoa(defaultNumOfRetries)))
+	}
+
+	os.Setenv(envNumberOfRetries, "not a number")
+	defer os.Unsetenv(envNumberOfRetries)
+
+	r = NewReader([]string{"localhost:9091"}, "", "test").(*missyReader)
+
+	if r.numOfRetries != defaultNumOfRetries {
+		t.Error(expected(strconv.Itoa(r.numOfRetries), strconv.Itoa(defaultNumOfRetries)))
+	}
+}
+
+func TestNewReader_NumOfRetriesInfiniteFromEnv(t *testing.T) {
+	os.Setenv(envNumberOfRetries, "-1")
+	defer os.Unsetenv(envNumberOfRetries)
+
+	r := NewReader([]string{"localhost:9091"}, "group", "test").(*missyReader)
+	if r.numOfRetries != InfiniteRetries {
+		t.Error(expected(strconv.Itoa(r.numOfRetries), strconv.Itoa(InfiniteRetries)))
+	}
+
+	os.Setenv(envNumberOfRetries, "-2")
+	r = NewReader([]string{"localhost:9091"}, "group", "test").(*missyReader)
+	if r.numOfRetries != defaultNumOfRetries {
+		t.Error(expected(strconv.Itoa(r.numOfRetries), strconv.Itoa(defaultNumOfRetries)))
+	}
+}
+
+func TestWithRetries_Zero(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("zero").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	r := im.NewReader("group", "zero", WithRetries(0))
+	defer r.Close()
+
+	calls := 0
+	if err := r.ReadN(context.Background(), 1, func(m Message) error {
+		calls++
+		return errors.New("error")
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != 1 || len(im.Messages("zero")) != 1 {
+		t.Errorf("expected the message not to be retried, got %d calls and %d messages", calls, len(im.Messages("zero")))
+	}
+	if len(im.Messages("zero.dlq")) != 1 {
+		t.Error("expected the message to go to the DLQ on the first failure")
+	}
+}
+
+func TestWithRetries_Infinite(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("infinite").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	r := im.NewReader("group", "infinite", WithRetries(InfiniteRetries))
+	defer r.Close()
+
+	// far more failures than the default number of retries
+	failures := 3 * defaultNumOfRetries
+	calls := 0
+	if err := r.ReadN(context.Background(), failures+1, func(m Message) error {
+		calls++
+		if m.RetryCounter < failures {
+			return errors.New("error")
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if calls != failures+1 {
+		t.Errorf("expected %d calls, got %d", failures+1, calls)
+	}
+	if len(im.Messages("infinite.dlq")) != 0 {
+		t.Error("a message with infinite retries should never go to the DLQ")
+	}
+	if exhausted := r.(*inMemoryReader).retriesExhausted(Message{RetryCounter: 1 << 30}); exhausted {
+		t.Error("retries should never be exhausted")
+	}
+}
+
+func TestWithRetries_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a negative number of retries other than InfiniteRetries")
+		}
+	}()
+	WithRetries(-2)(&missyReader{})
+}
+
 func TestReader_ReadSuccess(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	brokerReaderMock := NewMockBrokerReader(mockCtrl)
-	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
-	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, nil)
-	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).AnyTimes().Return(nil)
+	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).AnyTimes().Return(nil)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+
+	readFunc := func(msg Message) error {
+		if msg.Topic != "test" {
+			t.Error(expected(msg.Topic, "test"))
+		}
+		if string(msg.Key) != "key" {
+			t.Error(expected(string(msg.Key), "key"))
+		}
+		if string(msg.Value) != "value" {
+			t.Error(expected(string(msg.Value), "value"))
+		}
+		if msg.Partition != 0 {
+			t.Error(expected(strconv.Itoa(msg.Partition), "0"))
+		}
+		if msg.Offset != 0 {
+			t.Error(expected(strconv.FormatInt(msg.Offset, 10), "0"))
+		}
+		return nil
+	}
+
+	err := reader.Read(readFunc)
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	err = reader.Read(readFunc)
+
+	if err == nil {
+		t.Errorf("error during read function expected, bacause readFunc is set!")
+	}
+
+	// todo how to test internal goroutines loops better?
+	time.Sleep(time.Millisecond)
+}
+
+func TestReader_ReadErrorOnFetch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, errors.New("ferch error"))
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+
+	readFunc := func(msg Message) error {
+		if msg.Topic != "test" {
+			t.Error(expected(msg.Topic, "test"))
+		}
+		if string(msg.Key) != "key" {
+			t.Error(expected(string(msg.Key), "key"))
+		}
+		if string(msg.Value) != "value" {
+			t.Error(expected(string(msg.Value), "value"))
+		}
+		if msg.Partition != 0 {
+			t.Error(expected(strconv.Itoa(msg.Partition), "0"))
+		}
+		if msg.Offset != 0 {
+			t.Error(expected(strconv.FormatInt(msg.Offset, 10), "0"))
+		}
+		return nil
+	}
+
+	err := reader.Read(readFunc)
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	// todo how to test internal goroutines loops better?
+	time.Sleep(time.Millisecond)
+}
+
+func TestMissyReader_ReadRetriesTransientFetchError(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value")}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, errors.New("network blip")),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		// reader has been closed
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+
+	handled := false
+	err := reader.Read(func(msg Message) error {
+		handled = true
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case <-reader.done:
+	case <-time.After(time.Second):
+		t.Fatal("reader did not stop after it has been closed")
+	}
+
+	if !handled {
+		t.Error("message after a transient fetch error was not handled")
+	}
+}
+
+func TestMissyReader_FetchBackoff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value")}
+	fetchErr := errors.New("broker unavailable")
+
+	var mu sync.Mutex
+	var fetchedAt []time.Time
+	record := func(ctx context.Context) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetchedAt = append(fetchedAt, time.Now())
+	}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Do(record).Return(Message{}, fetchErr).Times(4),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Do(record).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Do(record).Return(Message{}, fetchErr),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Do(record).Return(msg, nil),
+		// reader has been closed
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil).Times(2)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithFetchBackoff(20*time.Millisecond, 80*time.Millisecond)(&reader)
+
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-reader.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader did not stop after it has been closed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the waits double up to max and start at min again after the successful fetch
+	minimums := []time.Duration{20, 40, 80, 80, 0, 20}
+	for i, min := range minimums {
+		if wait := fetchedAt[i+1].Sub(fetchedAt[i]); wait < min*time.Millisecond {
+			t.Errorf("expected fetch %d to wait at least %dms, waited %s", i+2, min, wait)
+		}
+	}
+	if wait := fetchedAt[6].Sub(fetchedAt[5]); wait >= 80*time.Millisecond {
+		t.Errorf("expected the backoff to be reset after a successful fetch, waited %s", wait)
+	}
+}
+
+func TestMissyReader_ReadErrorOnCommit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).AnyTimes().Return(errors.New("error"))
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+
+	readFunc := func(msg Message) error {
+		if msg.Topic != "test" {
+			t.Error(expected(msg.Topic, "test"))
+		}
+		if string(msg.Key) != "key" {
+			t.Error(expected(string(msg.Key), "key"))
+		}
+		if string(msg.Value) != "value" {
+			t.Error(expected(string(msg.Value), "value"))
+		}
+		if msg.Partition != 0 {
+			t.Error(expected(strconv.Itoa(msg.Partition), "0"))
+		}
+		if msg.Offset != 0 {
+			t.Error(expected(strconv.FormatInt(msg.Offset, 10), "0"))
+		}
+		return nil
+	}
+
+	err := reader.Read(readFunc)
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	// todo how to test internal goroutines loops better?
+	time.Sleep(time.Millisecond)
+}
+
+func TestMissyReader_ReadErrorOnReadFunc(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).AnyTimes().Return(nil)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().WriteMessage(Message{Key: msg.Key, Value: msg.Value, RetryCounter: 1, Headers: traceHeaders(*msg)}).AnyTimes().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+
+	readFunc := func(msg Message) error {
+		return errors.New("error")
+	}
+
+	err := reader.Read(readFunc)
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	// todo how to test internal goroutines loops better?
+	time.Sleep(time.Millisecond)
+}
+
+func TestMissyReader_ReadNilFunc(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	// no FetchMessage expectation, reading must not start
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	if err := reader.Read(nil); err == nil {
+		t.Error("error was expected")
+	}
+	if err := reader.ReadCtx(context.Background(), nil); err == nil {
+		t.Error("error was expected")
+	}
+	if reader.readFunc != nil || reader.done != nil {
+		t.Error("reading should not be started with a nil msgFunc")
+	}
+}
+
+func TestMissyReader_ReadContextCancel(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
+	fetched := make(chan struct{})
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(*msg, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		close(fetched)
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).Return(nil)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := reader.ReadContext(ctx, func(msg Message) error {
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	<-fetched
+	cancel()
+
+	select {
+	case <-reader.done:
+	case <-time.After(time.Second):
+		t.Error("reading goroutine did not exit after context was cancelled")
+	}
+}
+
+func TestMissyReader_ManualCommit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
+	handled := make(chan Message)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(*msg, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	// the only commit is the one triggered by the application
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).Times(1).Return(nil)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithManualCommit()(&reader)
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := reader.ReadContext(ctx, func(msg Message) error {
+		handled <- msg
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	if err := reader.CommitMessages(<-handled); err != nil {
+		t.Errorf("error during commit unexpected!")
+	}
+
+	cancel()
+	<-reader.done
+}
+
+func TestMissyReader_ReadConcurrently(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	for i := 0; i < 4; i++ {
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{Topic: "test", Offset: int64(i)}, nil)
+	}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+
+	var commits []int64
+	allCommitted := make(chan struct{})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		commits = append(commits, msgs[0].Offset)
+		if msgs[0].Offset == 3 {
+			close(allCommitted)
+		}
+		return nil
+	})
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithConcurrency(4)(&reader)
+	defer reader.Close()
+
+	// the first message finishes last
+	var others sync.WaitGroup
+	others.Add(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := reader.ReadContext(ctx, func(msg Message) error {
+		if msg.Offset == 0 {
+			others.Wait()
+			return nil
+		}
+		others.Done()
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case <-allCommitted:
+	case <-time.After(time.Second):
+		t.Fatal("messages have not been committed")
+	}
+	cancel()
+	<-reader.done
+
+	for i := 1; i < len(commits); i++ {
+		if commits[i] <= commits[i-1] {
+			t.Errorf("commits are not monotonic: %v", commits)
+		}
+	}
+}
+
+func TestMissyReader_RetryDelay(t *testing.T) {
+	reader := missyReader{numOfRetries: 10}
+	WithRetryBackoff(10*time.Millisecond, time.Second)(&reader)
+
+	var last time.Duration
+	for i := 0; i < 10; i++ {
+		delay := reader.retryDelay(Message{RetryCounter: i})
+		if delay < last {
+			t.Errorf("retry delay should grow with the retry counter, got %v after %v", delay, last)
+		}
+		if delay > time.Second {
+			t.Errorf("retry delay %v exceeds the maximum", delay)
+		}
+		last = delay
+	}
+
+	if delay := reader.retryDelay(Message{RetryCounter: 2}); delay != 40*time.Millisecond {
+		t.Error(expected(delay.String(), "40ms"))
+	}
+
+	if last != time.Second {
+		t.Error(expected(last.String(), "1s"))
+	}
+
+	if delay := reader.retryDelay(Message{RetryCounter: 10}); delay != 0 {
+		t.Errorf("messages going to the DLQ should not be delayed, got %v", delay)
+	}
+}
+
+func TestMissyReader_RetryJitter(t *testing.T) {
+	reader := missyReader{numOfRetries: 10}
+	WithRetryBackoff(100*time.Millisecond, time.Second)(&reader)
+	WithRetryJitter(0.5)(&reader)
+
+	const n = 1000
+	var sum time.Duration
+	min, max := time.Hour, time.Duration(0)
+	for i := 0; i < n; i++ {
+		delay := reader.retryDelay(Message{RetryCounter: 0})
+		if delay < 50*time.Millisecond || delay > 100*time.Millisecond {
+			t.Fatalf("jittered delay %v out of range [50ms, 100ms]", delay)
+		}
+		sum += delay
+		if delay < min {
+			min = delay
+		}
+		if delay > max {
+			max = delay
+		}
+	}
+
+	// uniformly distributed delays average at 75ms and cover most of the range
+	if mean := sum / n; mean < 70*time.Millisecond || mean > 80*time.Millisecond {
+		t.Errorf("mean of jittered delays %v should be close to 75ms", mean)
+	}
+	if max-min < 40*time.Millisecond {
+		t.Errorf("jittered delays should spread over the range, got [%v, %v]", min, max)
+	}
+}
+
+func TestMissyReader_RetryKeepsHeaders(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	written := time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Time: written, Headers: []Header{{Key: "trace-id", Value: []byte("abc")}}}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+	var retried Message
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().WriteMessage(gomock.Any()).DoAndReturn(func(m Message) error {
+		retried = m
+		return nil
+	})
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+	if err := reader.Read(func(msg Message) error { return errors.New("error") }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+
+	if traceID, ok := retried.GetHeader("trace-id"); !ok || string(traceID) != "abc" {
+		t.Errorf("headers should survive a retry, got %v", retried.Headers)
+	}
+	if originalTime, _ := retried.GetHeader(OriginalTimeHeader); string(originalTime) != written.Format(time.RFC3339Nano) {
+		t.Error(expected(string(originalTime), written.Format(time.RFC3339Nano)))
+	}
+	if retried.RetryCounter != 1 {
+		t.Error(expected(strconv.Itoa(retried.RetryCounter), "1"))
+	}
+}
+
+func TestMissyReader_MaxRetryAge(t *testing.T) {
+	reader := missyReader{numOfRetries: 5}
+	WithMaxRetryAge(time.Minute)(&reader)
+
+	fresh := Message{Time: time.Now()}
+	if reader.retriesExhausted(fresh) {
+		t.Error("a fresh message should have retries left")
+	}
+
+	old := Message{Time: time.Now(), RetryCounter: 1}
+	old.SetHeader(OriginalTimeHeader, []byte(time.Now().Add(-time.Hour).Format(time.RFC3339Nano)))
+	if !reader.retriesExhausted(old) {
+		t.Error("a message retried for longer than the max retry age should go to the DLQ")
+	}
+
+	if !reader.retriesExhausted(Message{Time: time.Now(), RetryCounter: 5}) {
+		t.Error("a message without retries left should go to the DLQ regardless of its age")
+	}
+
+	if reader.retriesExhausted(Message{}) {
+		t.Error("a message without time should only be limited by the number of retries")
+	}
+}
+
+func TestMissyReader_MaxRetryAgeSendsToDLQ(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Time: time.Now().Add(-time.Hour)}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+	writerMock := NewMockWriter(mockCtrl)
+	dlqWriterMock := NewMockWriter(mockCtrl)
+	dlqWriterMock.EXPECT().WriteMessage(gomock.Any()).DoAndReturn(func(m Message) error {
+		record, err := ParseDLQMessage(m)
+		if err != nil {
+			t.Errorf("unexpected error parsing the DLQ message: %v", err)
+		}
+		if record.OriginalTopic != "test" || record.LastError != "error" || string(record.Message.Value) != "value" {
+			t.Errorf("unexpected DLQ record %+v", record)
+		}
+		return nil
+	})
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock, dlqWriter: dlqWriterMock}
+	WithMaxRetryAge(time.Minute)(&reader)
+	if err := reader.Read(func(msg Message) error { return errors.New("error") }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_ErrDrop(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("malformed")}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+	// no writer expectations, a dropped message is neither retried nor sent to the DLQ
+	writerMock := NewMockWriter(mockCtrl)
+	dlqWriterMock := NewMockWriter(mockCtrl)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock, dlqWriter: dlqWriterMock}
+	if err := reader.Read(func(msg Message) error { return fmt.Errorf("cannot parse payload: %w", ErrDrop) }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_ErrSendToDLQ(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value")}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+	// no writer expectation, the message skips its retries
+	writerMock := NewMockWriter(mockCtrl)
+	dlqWriterMock := NewMockWriter(mockCtrl)
+	dlqWriterMock.EXPECT().WriteMessage(gomock.Any()).DoAndReturn(func(m Message) error {
+		if record, _ := ParseDLQMessage(m); record.RetryCount != 0 {
+			t.Error(expected(strconv.Itoa(record.RetryCount), "0"))
+		}
+		return nil
+	})
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock, dlqWriter: dlqWriterMock}
+	// a backoff must not delay messages going to the DLQ
+	WithRetryBackoff(time.Hour, time.Hour)(&reader)
+	if err := reader.Read(func(msg Message) error { return ErrSendToDLQ }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_ProcessTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value")}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().WriteMessage(Message{Key: msg.Key, Value: msg.Value, RetryCounter: 1, Headers: traceHeaders(msg)}).Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+	WithProcessTimeout(10 * time.Millisecond)(&reader)
+	if err := reader.ReadCtx(context.Background(), func(ctx context.Context, msg Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_CloseCancelsProcessContext(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value")}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+			<-ctx.Done()
+			return Message{}, ctx.Err()
+		}),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	started := make(chan struct{})
+	if err := reader.ReadCtx(context.Background(), func(ctx context.Context, msg Message) error {
+		close(started)
+		<-ctx.Done()
+		return nil
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	<-started
+	if err := reader.Close(); err != nil {
+		t.Errorf("there is an error during Close call")
+	}
+	select {
+	case <-reader.done:
+	default:
+		t.Error("Close should cancel the context of msgFunc and wait for it to return")
+	}
+}
+
+func TestMissyReader_CommitCallback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msgs := []Message{{Topic: "test", Partition: 1, Offset: 7}, {Topic: "test", Partition: 1, Offset: 8}}
+	var calls []string
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msgs[0], nil),
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msgs[0]).Return(nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+			calls = append(calls, "fetch")
+			return msgs[1], nil
+		}),
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msgs[1]).Times(1+commitRetries).Return(errors.New("error")),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithCommitCallback(func(topic string, partition int, offset int64) {
+		calls = append(calls, fmt.Sprintf("%s/%d/%d", topic, partition, offset))
+	})(&reader)
+
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+
+	// the callback runs before the next fetch and not for failed commits
+	if fmt.Sprint(calls) != "[test/1/7 fetch]" {
+		t.Error(expected(fmt.Sprint(calls), "[test/1/7 fetch]"))
+	}
+}
+
+func TestMissyReader_PanicRecovery(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	bad := Message{Topic: "test", Key: []byte("bad"), Offset: 0}
+	good := Message{Topic: "test", Key: []byte("good"), Offset: 1}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(bad, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(good, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), bad).Return(nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), good).Return(nil)
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().WriteMessage(Message{Key: bad.Key, RetryCounter: 1, Headers: traceHeaders(bad)}).Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+	if err := reader.Read(func(msg Message) error {
+		if string(msg.Key) == "bad" {
+			panic("bad message")
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_PanicRecoveryDisabled(t *testing.T) {
+	reader := missyReader{}
+	WithPanicRecovery(false)(&reader)
+
+	handle := reader.withProcessContext(context.Background(), func(ctx context.Context, msg Message) error {
+		panic("bad message")
+	})
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("the panic should not be recovered")
+		}
+	}()
+	handle(Message{})
+}
+
+func TestMissyReader_Dedup(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	first := Message{Topic: "test", Offset: 0, Headers: []Header{{Key: MessageIDHeader, Value: []byte("id-1")}}}
+	redelivered := Message{Topic: "test", Offset: 1, Headers: []Header{{Key: MessageIDHeader, Value: []byte("id-1")}}}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(first, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(redelivered, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), first).Return(nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), redelivered).Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithDedup(time.Minute, "")(&reader)
+
+	processed := 0
+	if err := reader.Read(func(msg Message) error {
+		processed++
+		return nil
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+
+	if processed != 1 {
+		t.Errorf("the duplicate should be committed without processing it, processed %d messages", processed)
+	}
+}
+
+func TestMissyReader_RetryBackoffDoesNotBlockReading(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	failing := Message{Topic: "test", Key: []byte("fail"), Partition: 0, Offset: 0}
+	other := Message{Topic: "test", Key: []byte("other"), Partition: 1, Offset: 0}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(failing, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(other, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), other).Return(nil)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), failing).Return(nil)
 	brokerReaderMock.EXPECT().Close().Return(nil)
-
-	reader := missyReader{brokerReader: brokerReaderMock}
-
-	readFunc := func(msg Message) error {
-		if msg.Topic != "test" {
-			t.Error(expected(msg.Topic, "test"))
-		}
-		if string(msg.Key) != "key" {
-			t.Error(expected(string(msg.Key), "key"))
-		}
-		if string(msg.Value) != "value" {
-			t.Error(expected(string(msg.Value), "value"))
-		}
-		if msg.Partition != 0 {
-			t.Error(expected(string(msg.Partition), string(0)))
-		}
-		if msg.Offset != 0 {
-			t.Error(expected(string(msg.Offset), string(0)))
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().WriteMessage(Message{Key: failing.Key, Value: failing.Value, RetryCounter: 1, Headers: traceHeaders(failing)}).Return(nil)
+	writerMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+	WithRetryBackoff(time.Hour, time.Hour)(&reader)
+	defer reader.Close()
+
+	handled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	err := reader.ReadContext(ctx, func(msg Message) error {
+		if string(msg.Key) == "fail" {
+			return errors.New("error")
 		}
+		close(handled)
 		return nil
+	})
+
+	if err != nil {
+		t.Errorf("error during read function unexpected!")
 	}
 
-	err := reader.Read(readFunc)
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("reading was blocked by the delayed retry")
+	}
+
+	// cancelling re-enqueues the delayed message right away
+	cancel()
+	<-reader.done
+}
+
+func TestMissyReader_DLQDisabled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), RetryCounter: 5}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	committed := make(chan struct{})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		close(committed)
+		return nil
+	})
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	// neither a retry nor a DLQ write may happen
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().Close().Return(nil)
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+	WithDLQDisabled()(&reader)
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := reader.ReadContext(ctx, func(msg Message) error {
+		return errors.New("error")
+	})
 
 	if err != nil {
 		t.Errorf("error during read function unexpected!")
 	}
 
-	err = reader.Read(readFunc)
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Error("dropped message was not committed")
+	}
+	cancel()
+	<-reader.done
+}
 
-	if err == nil {
-		t.Errorf("error during read function expected, bacause readFunc is set!")
+func TestMissyReader_CloseStopsReading(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	brokerReaderMock.EXPECT().Close().Return(nil)
+	writerMock := NewMockWriter(mockCtrl)
+	writerMock.EXPECT().Close().Return(nil)
+	dlqWriterMock := NewMockWriter(mockCtrl)
+	dlqWriterMock.EXPECT().Close().Return(nil)
+
+	goroutines := runtime.NumGoroutine()
+
+	reader := missyReader{brokerReader: brokerReaderMock, writer: writerMock, dlqWriter: dlqWriterMock}
+	WithConcurrency(4)(&reader)
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Errorf("error during read function unexpected!")
 	}
 
-	// todo how to test internal goroutines loops better?
-	time.Sleep(time.Millisecond)
+	if err := reader.Close(); err != nil {
+		t.Errorf("there is an error during Close call")
+	}
+
+	if reader.readFunc != nil {
+		t.Error("readFunc should be reset after Close")
+	}
+
+	// goroutines may take a moment to be cleaned up by the runtime
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("reader leaked %d goroutines", n-goroutines)
+	}
 }
 
-func TestReader_ReadErrorOnFetch(t *testing.T) {
+func TestMissyReader_ReadAgainAfterStop(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	brokerReaderMock := NewMockBrokerReader(mockCtrl)
-	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
-	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, errors.New("ferch error"))
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
 	brokerReaderMock.EXPECT().Close().Return(nil)
 
 	reader := missyReader{brokerReader: brokerReaderMock}
+	readFunc := func(msg Message) error { return nil }
 
-	readFunc := func(msg Message) error {
-		if msg.Topic != "test" {
-			t.Error(expected(msg.Topic, "test"))
-		}
-		if string(msg.Key) != "key" {
-			t.Error(expected(string(msg.Key), "key"))
-		}
-		if string(msg.Value) != "value" {
-			t.Error(expected(string(msg.Value), "value"))
-		}
-		if msg.Partition != 0 {
-			t.Error(expected(string(msg.Partition), string(0)))
-		}
-		if msg.Offset != 0 {
-			t.Error(expected(string(msg.Offset), string(0)))
-		}
-		return nil
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := reader.ReadContext(ctx, readFunc); err != nil {
+		t.Errorf("error during read function unexpected!")
 	}
+	cancel()
+	<-reader.done
 
-	err := reader.Read(readFunc)
+	if err := reader.Read(readFunc); err != nil {
+		t.Errorf("reading again after the goroutine stopped should be possible, got %v", err)
+	}
 
-	if err != nil {
-		t.Errorf("error during read function unexpected!")
+	if err := reader.Close(); err != nil {
+		t.Errorf("there is an error during Close call")
 	}
 
-	// todo how to test internal goroutines loops better?
-	time.Sleep(time.Millisecond)
+	if err := reader.Read(readFunc); err != ErrReaderClosed {
+		t.Errorf("expected ErrReaderClosed after Close, got %v", err)
+	}
 }
 
-func TestMissyReader_ReadErrorOnCommit(t *testing.T) {
+func TestMissyReader_CommitBatchSize(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	brokerReaderMock := NewMockBrokerReader(mockCtrl)
-	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
-	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, nil)
-	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), *msg).AnyTimes().Return(errors.New("error"))
+
+	msgs := []Message{{Topic: "test", Offset: 0}, {Topic: "test", Offset: 1}, {Topic: "test", Offset: 2}}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msgs[0], nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msgs[1], nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msgs[2], nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF),
+	)
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msgs[0], msgs[1]).Return(nil),
+		// the incomplete batch is committed when reading stops
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msgs[2]).Return(nil),
+	)
 
 	reader := missyReader{brokerReader: brokerReaderMock}
+	WithCommitBatchSize(2)(&reader)
 
-	readFunc := func(msg Message) error {
-		if msg.Topic != "test" {
-			t.Error(expected(msg.Topic, "test"))
-		}
-		if string(msg.Key) != "key" {
-			t.Error(expected(string(msg.Key), "key"))
-		}
-		if string(msg.Value) != "value" {
-			t.Error(expected(string(msg.Value), "value"))
-		}
-		if msg.Partition != 0 {
-			t.Error(expected(string(msg.Partition), string(0)))
-		}
-		if msg.Offset != 0 {
-			t.Error(expected(string(msg.Offset), string(0)))
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_CommitInterval(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+
+	msg := Message{Topic: "test", Offset: 0}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+			<-ctx.Done()
+			return Message{}, ctx.Err()
+		}),
+	)
+	committed := make(chan struct{})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		close(committed)
+		return nil
+	})
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	WithCommitInterval(10 * time.Millisecond)(&reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := reader.ReadContext(ctx, func(msg Message) error { return nil }); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Error("message should be committed after the commit interval")
+	}
+	cancel()
+	<-reader.done
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_ShutdownCommitsInFlightMessage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Offset: 0}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+			<-ctx.Done()
+			return Message{}, ctx.Err()
+		}),
+	)
+	var committed int32
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		atomic.StoreInt32(&committed, 1)
+		return nil
+	})
+	brokerReaderMock.EXPECT().Close().DoAndReturn(func() error {
+		if atomic.LoadInt32(&committed) == 0 {
+			t.Error("connection should be closed after the in-flight message has been committed")
 		}
 		return nil
+	})
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	started := make(chan struct{})
+	if err := reader.Read(func(msg Message) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
 	}
 
-	err := reader.Read(readFunc)
+	<-started
+	if err := reader.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error during Shutdown: %v", err)
+	}
+	if atomic.LoadInt32(&committed) == 0 {
+		t.Error("in-flight message should be committed before Shutdown returns")
+	}
+	if err := reader.Read(func(msg Message) error { return nil }); err != ErrReaderClosed {
+		t.Errorf("expected ErrReaderClosed after Shutdown, got %v", err)
+	}
+}
 
-	if err != nil {
+func TestMissyReader_ShutdownRetriesFailedCommit(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Offset: 0}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+			<-ctx.Done()
+			return Message{}, ctx.Err()
+		}),
+	)
+	var committed int32
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(errors.New("commit error")),
+		brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+			atomic.StoreInt32(&committed, 1)
+			return nil
+		}),
+	)
+	brokerReaderMock.EXPECT().Close().Return(nil)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	started := make(chan struct{})
+	if err := reader.Read(func(msg Message) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}); err != nil {
 		t.Errorf("error during read function unexpected!")
 	}
 
-	// todo how to test internal goroutines loops better?
-	time.Sleep(time.Millisecond)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := reader.Shutdown(ctx); err != nil {
+		t.Errorf("unexpected error during Shutdown: %v", err)
+	}
+	if atomic.LoadInt32(&committed) == 0 {
+		t.Error("the failed commit of the in-flight message should be retried during Shutdown")
+	}
+	select {
+	case err := <-reader.Errors():
+		t.Errorf("a commit succeeding on retry should not be reported, got %v", err)
+	default:
+	}
 }
 
-func TestMissyReader_ReadErrorOnReadFunc(t *testing.T) {
+func TestMissyReader_ShutdownDeadline(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	brokerReaderMock := NewMockBrokerReader(mockCtrl)
-	msg := &Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), Partition: 0, Offset: 0}
-	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().Return(*msg, nil)
+	msg := Message{Topic: "test", Offset: 0}
+	gomock.InOrder(
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil),
+		brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+			<-ctx.Done()
+			return Message{}, ctx.Err()
+		}),
+	)
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).Return(nil)
 	brokerReaderMock.EXPECT().Close().Return(nil)
 
 	reader := missyReader{brokerReader: brokerReaderMock}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	if err := reader.Read(func(msg Message) error {
+		close(started)
+		<-release
+		return nil
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
 
-	readFunc := func(msg Message) error {
-		return errors.New("error")
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := reader.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
 	}
 
-	err := reader.Read(readFunc)
+	close(release)
+	<-reader.done
+}
+
+func TestMissyReader_DLQWriteFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value"), RetryCounter: 5}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	// no CommitMessages expectation, the message must not be committed
+	dlqErr := errors.New("dlq error")
+	dlqWriterMock := NewMockWriter(mockCtrl)
+	dlqWriterMock.EXPECT().WriteMessage(gomock.Any()).Times(1 + reenqueueRetries).Return(dlqErr)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, dlqWriter: dlqWriterMock}
 
+	err := reader.Read(func(msg Message) error {
+		return errors.New("error")
+	})
 	if err != nil {
 		t.Errorf("error during read function unexpected!")
 	}
 
-	// todo how to test internal goroutines loops better?
-	time.Sleep(time.Millisecond)
+	// the partition cannot be committed any further, so reading stops instead of processing more messages
+	select {
+	case err := <-reader.Done():
+		if err != dlqErr {
+			t.Errorf("expected reading to stop with the DLQ write error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading did not stop after the DLQ write failed")
+	}
+}
+
+func TestMissyReader_RetryWriteFailsOnce(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msg := Message{Topic: "test", Key: []byte("key"), Value: []byte("value")}
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(msg, nil)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+	committed := make(chan struct{})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), msg).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		close(committed)
+		return nil
+	})
+	writerMock := NewMockWriter(mockCtrl)
+	gomock.InOrder(
+		writerMock.EXPECT().WriteMessage(gomock.Any()).Return(errors.New("write error")),
+		writerMock.EXPECT().WriteMessage(gomock.Any()).Return(nil),
+	)
+
+	reader := missyReader{brokerReader: brokerReaderMock, numOfRetries: 5, writer: writerMock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := reader.ReadContext(ctx, func(msg Message) error {
+		return errors.New("error")
+	}); err != nil {
+		t.Errorf("error during read function unexpected!")
+	}
+
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message should be committed once it has been re-enqueued")
+	}
+	cancel()
+	if err := <-reader.Done(); err != nil {
+		t.Errorf("expected reading to stop without an error, got %v", err)
+	}
+}
+
+func TestMissyReader_Stats(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	stats := kafka.ReaderStats{Topic: "test", Messages: 42, Bytes: 1024, Rebalances: 1, Lag: 7}
+	brokerReaderMock.EXPECT().Stats().Return(stats)
+
+	reader := missyReader{brokerReader: brokerReaderMock}
+	got := reader.Stats()
+
+	if got.Topic != "test" || got.Messages != 42 || got.Bytes != 1024 || got.Rebalances != 1 || got.Lag != 7 {
+		t.Errorf("unexpected stats %+v", got)
+	}
 }
 
 func TestMissyReader_Close(t *testing.T) {
@@ -230,10 +1505,10 @@ func TestReadBroker_FetchMessage(t *testing.T) {
 		t.Error(expected(string(msg.Value), "value"))
 	}
 	if msg.Partition != 0 {
-		t.Error(expected(string(msg.Partition), string(0)))
+		t.Error(expected(strconv.Itoa(msg.Partition), "0"))
 	}
 	if msg.Offset != 0 {
-		t.Error(expected(string(msg.Offset), string(0)))
+		t.Error(expected(strconv.FormatInt(msg.Offset, 10), "0"))
 	}
 
 }
@@ -307,10 +1582,10 @@ func TestReadBroker_ReadMessage(t *testing.T) {
 		t.Error(expected(string(msg.Value), "value"))
 	}
 	if msg.Partition != 0 {
-		t.Error(expected(string(msg.Partition), string(0)))
+		t.Error(expected(strconv.Itoa(msg.Partition), "0"))
 	}
 	if msg.Offset != 0 {
-		t.Error(expected(string(msg.Offset), string(0)))
+		t.Error(expected(strconv.FormatInt(msg.Offset, 10), "0"))
 	}
 }
 
@@ -359,7 +1634,7 @@ func TestReadBroker_CommitMessages(t *testing.T) {
 	// using monkey patching to patch underlying function call (https://github.com/bouk/monkey)
 	monkey.PatchInstanceMethod(reflect.TypeOf(kr), "CommitMessages", func(_ *kafka.Reader, ctx context.Context, msgs ...kafka.Message) error {
 		if len(messages) != len(msgs) {
-			t.Errorf("not equal messages len: %s", expected(string(len(messages)), string(len(msgs))))
+			t.Errorf("not equal messages len: %s", expected(strconv.Itoa(len(messages)), strconv.Itoa(len(msgs))))
 		}
 		exec = true
 		return nil
@@ -396,7 +1671,7 @@ func TestReadBroker_CommitMessages_Error(t *testing.T) {
 	// using monkey patching to patch underlying function call (https://github.com/bouk/monkey)
 	monkey.PatchInstanceMethod(reflect.TypeOf(kr), "CommitMessages", func(_ *kafka.Reader, ctx context.Context, msgs ...kafka.Message) error {
 		if len(messages) != len(msgs) {
-			t.Errorf("not equal messages len: %s", expected(string(len(messages)), string(len(msgs))))
+			t.Errorf("not equal messages len: %s", expected(strconv.Itoa(len(messages)), strconv.Itoa(len(msgs))))
 		}
 		exec = true
 		return errors.New("commit error")
@@ -479,3 +1754,181 @@ func TestReadBroker_Close_Error(t *testing.T) {
 	}
 
 }
+
+func TestMissyReader_ReadN(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		im := NewInMemory()
+		w := im.NewWriter("orders")
+		for i := 0; i < 5; i++ {
+			if err := w.Write(nil, []byte(strconv.Itoa(i))); err != nil {
+				t.Fatalf("unexpected error writing: %v", err)
+			}
+		}
+
+		r := im.NewReader("group", "orders", WithConcurrency(concurrency))
+		var mu sync.Mutex
+		var values []string
+		err := r.ReadN(context.Background(), 3, func(m Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			values = append(values, string(m.Value))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(values) != 3 {
+			t.Errorf("expected 3 messages to be read with concurrency %d, got %v", concurrency, values)
+		}
+		if lag := im.lag("group", "orders"); lag != 2 {
+			t.Errorf("expected the 3 messages to be committed with concurrency %d, got lag %d", concurrency, lag)
+		}
+
+		// the reader can read again, the next call continues after the committed messages
+		values = nil
+		if err := r.ReadN(context.Background(), 2, func(m Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			values = append(values, string(m.Value))
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		sort.Strings(values)
+		if !reflect.DeepEqual(values, []string{"3", "4"}) {
+			t.Errorf("expected the remaining messages with concurrency %d, got %v", concurrency, values)
+		}
+		r.Close()
+	}
+}
+
+func TestMissyReader_ReadNContextCancelled(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("orders").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var read int32
+	err := r.ReadN(ctx, 2, func(m Message) error {
+		atomic.AddInt32(&read, 1)
+		return nil
+	})
+
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if read != 1 {
+		t.Errorf("expected the available message to be read, got %d", read)
+	}
+	if err := r.ReadN(context.Background(), 0, func(m Message) error { return nil }); err != nil {
+		t.Errorf("reading no message should return right away, got %v", err)
+	}
+}
+
+func TestMissyReader_FailFastUnreachableBrokers(t *testing.T) {
+	reader := NewReader([]string{"127.0.0.1:1"}, "", "test", WithFailFast())
+	defer reader.Close()
+
+	if reader.Done() != nil {
+		t.Error("Done should return nil before reading has been started")
+	}
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err := <-reader.Done():
+		if err == nil {
+			t.Error("expected the fetch error of the unreachable brokers")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the reader did not stop after failing to fetch the first message")
+	}
+}
+
+func TestMissyReader_DoneAfterClose(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, errors.New("network blip"))
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).Return(Message{}, io.EOF)
+
+	// without WithFailFast the fetch error is retried and closing the reader stops it without an error
+	reader := missyReader{brokerReader: brokerReaderMock}
+	if err := reader.Read(func(msg Message) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case err, ok := <-reader.Done():
+		if err != nil || !ok {
+			t.Errorf("expected nil once reading stopped, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading did not stop")
+	}
+	if _, ok := <-reader.Done(); ok {
+		t.Error("expected Done to be closed after the error has been received")
+	}
+}
+
+func TestMissyReader_KeyOrdering(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	keys := []string{"a", "b", "c", "d"}
+	for i := 0; i < 20; i++ {
+		for _, key := range keys {
+			if err := w.Write([]byte(key), []byte(strconv.Itoa(i))); err != nil {
+				t.Fatalf("unexpected error writing: %v", err)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	processed := make(map[string][]int)
+	r := im.NewReader("group", "orders", WithConcurrency(4), WithKeyOrdering())
+	defer r.Close()
+	err := r.ReadN(context.Background(), 20*len(keys), func(m Message) error {
+		// later messages of a key would overtake earlier ones without key ordering
+		i, _ := strconv.Atoi(string(m.Value))
+		time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		processed[string(m.Key)] = append(processed[string(m.Key)], i)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range keys {
+		if !sort.IntsAreSorted(processed[key]) || len(processed[key]) != 20 {
+			t.Errorf("expected the messages of key %s in order, got %v", key, processed[key])
+		}
+	}
+}
+
+func TestWorkerFor(t *testing.T) {
+	m := Message{Key: []byte("customer-1")}
+	worker := workerFor(m, 0, 8)
+	for i := 1; i < 10; i++ {
+		if w := workerFor(m, i, 8); w != worker {
+			t.Errorf("expected messages with the same key to go to worker %d, got %d", worker, w)
+		}
+	}
+
+	seen := make(map[int]bool)
+	for i := 0; i < 8; i++ {
+		seen[workerFor(Message{}, i, 8)] = true
+	}
+	if len(seen) != 8 {
+		t.Errorf("expected messages without a key to be spread over all workers, got %d workers", len(seen))
+	}
+}
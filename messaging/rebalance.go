@@ -0,0 +1,131 @@
+package messaging
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// rebalancePollInterval is how often a reading consumer group member checks the stats of the kafka reader for rebalances
+const rebalancePollInterval = time.Second
+
+// RebalanceCallback is called after the partitions of the consumer group have been assigned again
+type RebalanceCallback func()
+
+// AssignmentCallback is called with the partitions the reader has been assigned or which have been revoked from it
+type AssignmentCallback func(assigned []int, revoked []int)
+
+// watchRebalances polls the stats of the broker reader until ctx is done. kafka-go has no rebalance hook, but counts the
+// generations of the group in its stats.
+func (mr *missyReader) watchRebalances(ctx context.Context) {
+	ticker := time.NewTicker(rebalancePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		mr.pollStats()
+	}
+}
+
+// pollStats reads the stats of the broker reader, which resets its counters, and keeps them for the next call of Stats
+func (mr *missyReader) pollStats() kafka.ReaderStats {
+	mr.statsMu.Lock()
+	stats := mr.brokerReader.Stats()
+	mr.unreported = addReaderStats(mr.unreported, stats)
+	mr.brokerFetched = mr.brokerFetched || stats.Fetches > 0
+	mr.statsMu.Unlock()
+
+	mr.rebalanced(stats.Rebalances)
+	return stats
+}
+
+// rebalanced counts n rebalances seen in the stats of the broker reader and calls the rebalance callback
+func (mr *missyReader) rebalanced(n int64) {
+	if n <= 0 {
+		return
+	}
+	mr.getLogger().Infof("# messaging # consumer group %s rebalanced, partitions have been assigned again", mr.groupID)
+	mr.unassign(n)
+	getReaderMetrics().onRebalanced(mr.groupID, mr.topic, n)
+	if mr.rebalanceCallback != nil {
+		mr.rebalanceCallback()
+	}
+}
+
+// assignedPartitions returns the sorted partition numbers of the assigned partitions
+func assignedPartitions(assigned map[partitionKey]int64) []int {
+	seen := make(map[int]bool, len(assigned))
+	var partitions []int
+	for key := range assigned {
+		if !seen[key.partition] {
+			seen[key.partition] = true
+			partitions = append(partitions, key.partition)
+		}
+	}
+	sort.Ints(partitions)
+	return partitions
+}
+
+// addReaderStats adds the counters and summaries of b to a, the gauges of b are taken as they are newer
+func addReaderStats(a kafka.ReaderStats, b kafka.ReaderStats) kafka.ReaderStats {
+	sum := b
+	sum.Dials += a.Dials
+	sum.Fetches += a.Fetches
+	sum.Messages += a.Messages
+	sum.Bytes += a.Bytes
+	sum.Rebalances += a.Rebalances
+	sum.Timeouts += a.Timeouts
+	sum.Errors += a.Errors
+	sum.DeprecatedFetchesWithTypo += a.DeprecatedFetchesWithTypo
+	sum.DialTime = addDurationStats(a.DialTime, b.DialTime)
+	sum.ReadTime = addDurationStats(a.ReadTime, b.ReadTime)
+	sum.WaitTime = addDurationStats(a.WaitTime, b.WaitTime)
+	sum.FetchSize = addSummaryStats(a.FetchSize, b.FetchSize)
+	sum.FetchBytes = addSummaryStats(a.FetchBytes, b.FetchBytes)
+	return sum
+}
+
+// addDurationStats merges two duration summaries
+func addDurationStats(a kafka.DurationStats, b kafka.DurationStats) kafka.DurationStats {
+	if a.Count == 0 {
+		return b
+	}
+	if b.Count == 0 {
+		return a
+	}
+	sum := kafka.DurationStats{Min: a.Min, Max: a.Max, Count: a.Count + b.Count, Sum: a.Sum + b.Sum}
+	if b.Min < sum.Min {
+		sum.Min = b.Min
+	}
+	if b.Max > sum.Max {
+		sum.Max = b.Max
+	}
+	sum.Avg = sum.Sum / time.Duration(sum.Count)
+	return sum
+}
+
+// addSummaryStats merges two summaries
+func addSummaryStats(a kafka.SummaryStats, b kafka.SummaryStats) kafka.SummaryStats {
+	if a.Count == 0 {
+		return b
+	}
+	if b.Count == 0 {
+		return a
+	}
+	sum := kafka.SummaryStats{Min: a.Min, Max: a.Max, Count: a.Count + b.Count, Sum: a.Sum + b.Sum}
+	if b.Min < sum.Min {
+		sum.Min = b.Min
+	}
+	if b.Max > sum.Max {
+		sum.Max = b.Max
+	}
+	sum.Avg = sum.Sum / sum.Count
+	return sum
+}
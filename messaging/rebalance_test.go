@@ -0,0 +1,177 @@
+package messaging
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMissyReader_RebalanceCallback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).DoAndReturn(func(ctx context.Context) (Message, error) {
+		<-ctx.Done()
+		return Message{}, ctx.Err()
+	})
+
+	var mu sync.Mutex
+	polls := 0
+	brokerReaderMock.EXPECT().Stats().AnyTimes().DoAndReturn(func() kafka.ReaderStats {
+		mu.Lock()
+		defer mu.Unlock()
+		polls++
+		// the group is joined before the first poll
+		if polls == 1 {
+			return kafka.ReaderStats{Rebalances: 1, Messages: 3, Lag: 7}
+		}
+		return kafka.ReaderStats{Messages: 2, Lag: 5}
+	})
+
+	rebalanced := make(chan struct{}, 10)
+	reader := missyReader{brokerReader: brokerReaderMock, groupID: "rebalance", topic: "orders"}
+	WithRebalanceCallback(func() { rebalanced <- struct{}{} })(&reader)
+
+	m := getReaderMetrics()
+	m.rebalances.Reset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := reader.ReadContext(ctx, func(msg Message) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-rebalanced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the rebalance callback has not been called")
+	}
+	cancel()
+	<-reader.done
+
+	if v := testutil.ToFloat64(m.rebalances.WithLabelValues("rebalance", "orders")); v != 1 {
+		t.Errorf("expected 1 rebalance, got %v", v)
+	}
+	if len(rebalanced) != 0 {
+		t.Errorf("expected the callback to be called once, got %d more calls", len(rebalanced))
+	}
+
+	// the counters polled while watching are not lost for callers of Stats
+	stats := reader.Stats()
+	mu.Lock()
+	want := int64(3 + 2*(polls-1))
+	mu.Unlock()
+	if stats.Messages != want || stats.Rebalances != 1 || stats.Lag != 5 {
+		t.Errorf("expected %d messages, 1 rebalance and the current lag, got %+v", want, stats)
+	}
+}
+
+func TestMissyReader_AssignmentCallback(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerReaderMock := NewMockBrokerReader(mockCtrl)
+	msgs := make(chan Message, 10)
+	brokerReaderMock.EXPECT().FetchMessage(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) (Message, error) {
+		select {
+		case m := <-msgs:
+			return m, nil
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	})
+	brokerReaderMock.EXPECT().CommitMessages(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+
+	var mu sync.Mutex
+	// the group is joined before the first poll
+	rebalances := int64(1)
+	brokerReaderMock.EXPECT().Stats().AnyTimes().DoAndReturn(func() kafka.ReaderStats {
+		mu.Lock()
+		defer mu.Unlock()
+		stats := kafka.ReaderStats{Rebalances: rebalances}
+		rebalances = 0
+		return stats
+	})
+
+	type assignment struct{ assigned, revoked []int }
+	assignments := make(chan assignment, 10)
+	reader := missyReader{brokerReader: brokerReaderMock, groupID: "assignment", topic: "orders"}
+	WithAssignmentCallback(func(assigned, revoked []int) { assignments <- assignment{assigned, revoked} })(&reader)
+
+	next := func() assignment {
+		select {
+		case a := <-assignments:
+			return a
+		case <-time.After(5 * time.Second):
+			t.Fatal("the assignment callback has not been called")
+		}
+		return assignment{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := reader.ReadContext(ctx, func(msg Message) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	msgs <- Message{Topic: "orders", Partition: 1, Offset: 0}
+	msgs <- Message{Topic: "orders", Partition: 1, Offset: 1}
+	msgs <- Message{Topic: "orders", Partition: 0, Offset: 0}
+	if a := next(); !reflect.DeepEqual(a.assigned, []int{1}) || a.revoked != nil {
+		t.Errorf("expected partition 1 to be assigned, got %+v", a)
+	}
+	if a := next(); !reflect.DeepEqual(a.assigned, []int{0}) || a.revoked != nil {
+		t.Errorf("expected partition 0 to be assigned, got %+v", a)
+	}
+
+	// rebalance after joining the group
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return rebalances == 0
+	})
+	mu.Lock()
+	rebalances = 1
+	mu.Unlock()
+	if a := next(); a.assigned != nil || !reflect.DeepEqual(a.revoked, []int{0, 1}) {
+		t.Errorf("expected partitions 0 and 1 to be revoked, got %+v", a)
+	}
+
+	msgs <- Message{Topic: "orders", Partition: 1, Offset: 2}
+	if a := next(); !reflect.DeepEqual(a.assigned, []int{1}) || a.revoked != nil {
+		t.Errorf("expected partition 1 to be assigned again, got %+v", a)
+	}
+
+	cancel()
+	<-reader.done
+	if len(assignments) != 0 {
+		t.Errorf("unexpected assignment changes: %d", len(assignments))
+	}
+}
+
+func TestAddReaderStats(t *testing.T) {
+	a := kafka.ReaderStats{
+		Fetches:  2,
+		ReadTime: kafka.DurationStats{Min: time.Millisecond, Max: 3 * time.Millisecond, Count: 2, Sum: 4 * time.Millisecond},
+		Lag:      10,
+	}
+	b := kafka.ReaderStats{
+		Fetches:   1,
+		ReadTime:  kafka.DurationStats{Min: 2 * time.Millisecond, Max: 5 * time.Millisecond, Count: 2, Sum: 8 * time.Millisecond},
+		FetchSize: kafka.SummaryStats{Min: 1, Max: 1, Avg: 1, Count: 1, Sum: 1},
+		Lag:       4,
+	}
+
+	sum := addReaderStats(a, b)
+	if sum.Fetches != 3 || sum.Lag != 4 {
+		t.Errorf("expected the counters to be added and the latest gauges, got %+v", sum)
+	}
+	want := kafka.DurationStats{Min: time.Millisecond, Max: 5 * time.Millisecond, Avg: 3 * time.Millisecond, Count: 4, Sum: 12 * time.Millisecond}
+	if sum.ReadTime != want {
+		t.Errorf("expected read time %+v, got %+v", want, sum.ReadTime)
+	}
+	if sum.FetchSize != b.FetchSize {
+		t.Errorf("expected an empty summary to be ignored, got %+v", sum.FetchSize)
+	}
+}
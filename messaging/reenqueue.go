@@ -0,0 +1,53 @@
+package messaging
+
+import (
+	"time"
+)
+
+const (
+	// reenqueueRetries is how often a failed retry or DLQ write of a message is tried again before reading stops
+	reenqueueRetries = 3
+	// reenqueueBackoff is the delay before the first try again, it doubles with every try
+	reenqueueBackoff = 100 * time.Millisecond
+)
+
+// reenqueue re-enqueues a failed message or sends it to the DLQ like retry and tries again if the write fails, until
+// it succeeds, the retries are used up or the reader is closed. Afterwards, the message cannot be committed, and as
+// committing a later offset would commit it as well, reading stops with the error instead of keeping on processing
+// messages of a partition which is not committed any further.
+func (mr *missyReader) reenqueue(m Message, processErr error) error {
+	err := mr.retry(m, processErr)
+
+	backoff := reenqueueBackoff
+	for attempt := 1; attempt <= reenqueueRetries && err != nil; attempt++ {
+		mr.messageLogger(m).Warnf("# messaging # cannot re-enqueue message, trying again in %s (%d/%d): %v", backoff, attempt, reenqueueRetries, err)
+		select {
+		case <-time.After(backoff):
+		case <-mr.stopping:
+			mr.stopWithError(err)
+			return err
+		}
+		backoff *= 2
+		err = mr.retry(m, processErr)
+	}
+
+	if err != nil {
+		mr.stopWithError(err)
+	}
+	return err
+}
+
+// stopWithError stops reading because of err, Done receives the first of such errors
+func (mr *missyReader) stopWithError(err error) {
+	mr.mu.Lock()
+	if mr.readErr == nil {
+		mr.readErr = err
+	}
+	cancel := mr.cancel
+	mr.mu.Unlock()
+
+	mr.getLogger().Errorf("# messaging # stopped reading: %v", err)
+	if cancel != nil {
+		cancel()
+	}
+}
@@ -0,0 +1,110 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/microdevs/missy/log"
+	"github.com/segmentio/kafka-go"
+)
+
+// reprocessWait is how long Reprocess waits for the next DLQ message before it treats the DLQ as exhausted, the first
+// fetch of a kafka reader includes joining the consumer group
+const reprocessWait = 10 * time.Second
+
+// DLQReprocessor writes messages of a dead letter queue back to the topic they failed on, e.g. after fixing the bug
+// which made them fail
+type DLQReprocessor struct {
+	dlqTopic     string
+	targetTopic  string
+	brokerReader BrokerReader
+	writer       Writer
+	wait         time.Duration
+}
+
+// NewDLQReprocessor returns a DLQReprocessor reading dlqTopic with the consumer group "<dlqTopic>.reprocessor" and
+// writing to targetTopic. The group commits the messages it has reprocessed or skipped, so every DLQ message is offered
+// once, ResetOffsets on the group offers them again.
+func NewDLQReprocessor(brokers []string, dlqTopic string, targetTopic string) *DLQReprocessor {
+	if len(brokers) == 0 || dlqTopic == "" || targetTopic == "" {
+		log.Panicf("# messaging # invalid DLQ reprocessor config: Brokers, a DLQ topic and a target topic are required")
+	}
+
+	return &DLQReprocessor{
+		dlqTopic:    dlqTopic,
+		targetTopic: targetTopic,
+		brokerReader: &readBroker{kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     brokers,
+			GroupID:     dlqTopic + ".reprocessor",
+			Topic:       dlqTopic,
+			StartOffset: kafka.FirstOffset,
+		})},
+		writer: NewWriter(brokers, targetTopic),
+		wait:   reprocessWait,
+	}
+}
+
+// Reprocess writes the messages of the DLQ for which filter returns true to the target topic and returns how many
+// have been written. A nil filter reprocesses all messages. The messages are written without the DLQ headers and with
+// a retry counter of 0, so readers of the target topic retry them again. Reprocess returns once no new DLQ message
+// arrives for a while, or with the error of the first message which cannot be parsed or written, which is offered
+// again by the next call.
+func (p *DLQReprocessor) Reprocess(ctx context.Context, filter func(Message) bool) (int, error) {
+	n := 0
+	for {
+		m, err := p.fetch(ctx)
+		if err == context.DeadlineExceeded && ctx.Err() == nil {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		msg := m
+		record, err := ParseDLQMessage(m)
+		switch {
+		case err == nil:
+			msg = record.Message
+		case !errors.Is(err, ErrNoDLQMessage):
+			return n, fmt.Errorf("cannot reprocess message %d of partition %d of %s: %w", m.Offset, m.Partition, p.dlqTopic, err)
+		}
+
+		// a reprocessed message starts over, its maximum retry age counts from the time it is written again
+		headers := make([]Header, 0, len(msg.Headers))
+		for _, h := range msg.Headers {
+			if h.Key != OriginalTimeHeader {
+				headers = append(headers, h)
+			}
+		}
+		msg = Message{Key: msg.Key, Value: msg.Value, Headers: headers}
+		if filter == nil || filter(msg) {
+			if err := p.writer.WriteMessage(msg); err != nil {
+				return n, fmt.Errorf("cannot reprocess message %d of partition %d of %s to %s: %w", m.Offset, m.Partition, p.dlqTopic, p.targetTopic, err)
+			}
+			n++
+		}
+
+		if err := p.brokerReader.CommitMessages(ctx, m); err != nil {
+			return n, err
+		}
+	}
+}
+
+// fetch fetches the next DLQ message, waiting up to the configured wait
+func (p *DLQReprocessor) fetch(ctx context.Context) (Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.wait)
+	defer cancel()
+
+	return p.brokerReader.FetchMessage(ctx)
+}
+
+// Close closes the connections of the reprocessor
+func (p *DLQReprocessor) Close() error {
+	rErr := p.brokerReader.Close()
+	if err := p.writer.Close(); err != nil {
+		return err
+	}
+	return rErr
+}
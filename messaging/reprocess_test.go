@@ -0,0 +1,81 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestDLQReprocessor_Reprocess(t *testing.T) {
+	im := NewInMemory()
+	dlq := im.NewWriter("orders.dlq")
+	for _, key := range []string{"a", "b", "a"} {
+		m := Message{Topic: "orders", Key: []byte(key), Value: []byte("value-" + key), RetryCounter: 5, Headers: []Header{{Key: "tenant", Value: []byte("t1")}}}
+		m.SetHeader(OriginalTimeHeader, []byte(time.Now().Add(-time.Hour).Format(time.RFC3339Nano)))
+		if err := dlq.WriteMessage(dlqMessage(m, errors.New("failed"))); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	p := im.NewDLQReprocessor("orders.dlq", "orders")
+	defer p.Close()
+
+	n, err := p.Reprocess(context.Background(), func(m Message) bool { return string(m.Key) == "a" })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 reprocessed messages, got %d", n)
+	}
+
+	msgs := im.Messages("orders")
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages in the target topic, got %d", len(msgs))
+	}
+	for _, m := range msgs {
+		if string(m.Key) != "a" || string(m.Value) != "value-a" {
+			t.Errorf("expected the messages passing the filter, got %s = %s", m.Key, m.Value)
+		}
+		if m.RetryCounter != 0 {
+			t.Errorf("expected the retry counter to be reset, got %d", m.RetryCounter)
+		}
+		if _, ok := m.GetHeader(DLQOriginalTopicHeader); ok {
+			t.Errorf("expected the DLQ headers to be stripped, got %v", m.Headers)
+		}
+		if _, ok := m.GetHeader(OriginalTimeHeader); ok {
+			t.Errorf("expected the original time header to be stripped, got %v", m.Headers)
+		}
+		if tenant, _ := m.GetHeader("tenant"); string(tenant) != "t1" {
+			t.Errorf("expected the original headers to be kept, got %v", m.Headers)
+		}
+	}
+
+	// the reprocessed and skipped messages have been committed
+	if n, err := p.Reprocess(context.Background(), nil); err != nil || n != 0 {
+		t.Errorf("expected no messages to be offered again, got %d and error %v", n, err)
+	}
+}
+
+func TestDLQReprocessor_ReprocessInvalidMessage(t *testing.T) {
+	im := NewInMemory()
+	m := dlqMessage(Message{Topic: "orders", Value: []byte("value")}, errors.New("failed"))
+	m.SetHeader(DLQRetryCountHeader, []byte("invalid"))
+	if err := im.NewWriter("orders.dlq").WriteMessage(m); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	p := im.NewDLQReprocessor("orders.dlq", "orders")
+	defer p.Close()
+
+	if _, err := p.Reprocess(context.Background(), nil); err == nil {
+		t.Error("error was expected")
+	}
+	if len(im.Messages("orders")) != 0 {
+		t.Error("the invalid message should not be reprocessed")
+	}
+	if lag := im.lag("orders.dlq.reprocessor", "orders.dlq"); lag != 1 {
+		t.Errorf("the invalid message should not be committed, got lag %d", lag)
+	}
+}
@@ -0,0 +1,176 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// resetTimeout is the maximum time ResetOffsets waits for the brokers to answer
+const resetTimeout = 30 * time.Second
+
+// ErrGroupActive is returned by ResetOffsets if the consumer group has members, they would overwrite the offsets with
+// their next commit
+var ErrGroupActive = errors.New("consumer group has active members")
+
+// OffsetSpec tells ResetOffsets where a consumer group continues reading, it is Earliest, Latest or OffsetAt(t)
+type OffsetSpec struct {
+	offset int64
+	at     time.Time
+}
+
+var (
+	// Earliest resets a consumer group to the oldest messages still kept by the broker
+	Earliest = OffsetSpec{offset: kafka.FirstOffset}
+	// Latest resets a consumer group to the end of the partitions, skipping all messages written so far
+	Latest = OffsetSpec{offset: kafka.LastOffset}
+)
+
+// OffsetAt resets a consumer group to the first messages written at or after t, partitions without such messages are set
+// to their end
+func OffsetAt(t time.Time) OffsetSpec {
+	return OffsetSpec{at: t}
+}
+
+// String returns earliest, latest or the time of the spec
+func (s OffsetSpec) String() string {
+	switch {
+	case !s.at.IsZero():
+		return s.at.Format(time.RFC3339Nano)
+	case s.offset == kafka.FirstOffset:
+		return "earliest"
+	case s.offset == kafka.LastOffset:
+		return "latest"
+	}
+	return "unknown"
+}
+
+// offsetAdmin are the admin requests of kafka.Client used by ResetOffsets
+type offsetAdmin interface {
+	DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error)
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error)
+	OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error)
+}
+
+// ResetOffsets commits new offsets of all partitions of topic for the consumer group groupID, e.g. to replay a topic
+// or to skip messages which cannot be processed. All readers of the group have to be stopped first, ResetOffsets
+// returns ErrGroupActive while the group has members and does not change any offset then.
+func ResetOffsets(brokers []string, groupID string, topic string, to OffsetSpec) error {
+	ctx, cancel := context.WithTimeout(context.Background(), resetTimeout)
+	defer cancel()
+
+	return resetOffsets(ctx, newKafkaClient(brokers, nil), groupID, topic, to)
+}
+
+// resetOffsets checks that the group is empty, resolves the offsets of to and commits them
+func resetOffsets(ctx context.Context, admin offsetAdmin, groupID string, topic string, to OffsetSpec) error {
+	if groupID == "" || topic == "" {
+		return errors.New("a group id and a topic are required to reset offsets")
+	}
+	if to.at.IsZero() && to.offset != kafka.FirstOffset && to.offset != kafka.LastOffset {
+		return errors.New("invalid offset spec, use Earliest, Latest or OffsetAt")
+	}
+
+	groups, err := admin.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{groupID}})
+	if err != nil {
+		return err
+	}
+	for _, g := range groups.Groups {
+		if g.Error != nil {
+			return g.Error
+		}
+		if len(g.Members) > 0 {
+			return fmt.Errorf("cannot reset offsets of group %s with %d members: %w", groupID, len(g.Members), ErrGroupActive)
+		}
+	}
+
+	partitions, err := topicPartitions(ctx, admin, topic)
+	if err != nil {
+		return err
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("topic %s has no partitions", topic)
+	}
+
+	offsets, err := resolveOffsets(ctx, admin, topic, partitions, to)
+	if err != nil {
+		return err
+	}
+
+	if err := commitOffsets(ctx, admin, groupID, topic, offsets); err != nil {
+		return fmt.Errorf("cannot reset offsets of topic %s to %s: %w", topic, to, err)
+	}
+	return nil
+}
+
+// commitOffsets commits the offsets per partition for the consumer group
+func commitOffsets(ctx context.Context, admin offsetAdmin, groupID string, topic string, offsets map[int]int64) error {
+	commits := make([]kafka.OffsetCommit, 0, len(offsets))
+	for p, offset := range offsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: p, Offset: offset})
+	}
+	// a generation of -1 commits outside of a group session, which the broker only accepts while the group is empty
+	res, err := admin.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range res.Topics[topic] {
+		if p.Error != nil {
+			return fmt.Errorf("partition %d: %w", p.Partition, p.Error)
+		}
+	}
+	return nil
+}
+
+// resolveOffsets returns the offset of every partition the spec points to
+func resolveOffsets(ctx context.Context, admin offsetAdmin, topic string, partitions []int, to OffsetSpec) (map[int]int64, error) {
+	var requests []kafka.OffsetRequest
+	for _, p := range partitions {
+		requests = append(requests, kafka.LastOffsetOf(p))
+		switch {
+		case !to.at.IsZero():
+			requests = append(requests, kafka.TimeOffsetOf(p, to.at))
+		case to.offset == kafka.FirstOffset:
+			requests = append(requests, kafka.FirstOffsetOf(p))
+		}
+	}
+	res, err := admin.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int]int64)
+	for _, p := range res.Topics[topic] {
+		if p.Error != nil {
+			return nil, p.Error
+		}
+		offset := p.LastOffset
+		switch {
+		case !to.at.IsZero():
+			// the broker answers -1 if no message has been written at or after the time
+			for o := range p.Offsets {
+				if o >= 0 {
+					offset = o
+				}
+			}
+		case to.offset == kafka.FirstOffset:
+			offset = p.FirstOffset
+		}
+		offsets[p.Partition] = offset
+	}
+
+	for _, p := range partitions {
+		if offset, ok := offsets[p]; !ok || offset < 0 {
+			return nil, fmt.Errorf("no offset for partition %d of topic %s", p, topic)
+		}
+	}
+	return offsets, nil
+}
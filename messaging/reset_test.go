@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeAdmin answers admin requests like a broker keeping the message times per partition of a single topic
+type fakeAdmin struct {
+	topic     string
+	members   int
+	first     map[int]int64
+	times     map[int][]time.Time
+	committed map[int]int64
+}
+
+func (fa *fakeAdmin) DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error) {
+	group := kafka.DescribeGroupsResponseGroup{GroupID: req.GroupIDs[0], Members: make([]kafka.DescribeGroupsResponseMember, fa.members)}
+	return &kafka.DescribeGroupsResponse{Groups: []kafka.DescribeGroupsResponseGroup{group}}, nil
+}
+
+func (fa *fakeAdmin) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	topic := kafka.Topic{Name: fa.topic}
+	for p := range fa.times {
+		topic.Partitions = append(topic.Partitions, kafka.Partition{Topic: fa.topic, ID: p})
+	}
+	return &kafka.MetadataResponse{Topics: []kafka.Topic{topic}}, nil
+}
+
+func (fa *fakeAdmin) ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error) {
+	offsets := make(map[int]kafka.PartitionOffsets)
+	for _, r := range req.Topics[fa.topic] {
+		po, ok := offsets[r.Partition]
+		if !ok {
+			po = kafka.PartitionOffsets{Partition: r.Partition, FirstOffset: -1, LastOffset: -1, Offsets: make(map[int64]time.Time)}
+		}
+		times := fa.times[r.Partition]
+		switch r.Timestamp {
+		case kafka.FirstOffset:
+			po.FirstOffset = fa.first[r.Partition]
+		case kafka.LastOffset:
+			po.LastOffset = fa.first[r.Partition] + int64(len(times))
+		default:
+			offset := int64(-1)
+			for i, t := range times {
+				if t.UnixNano()/int64(time.Millisecond) >= r.Timestamp {
+					offset = fa.first[r.Partition] + int64(i)
+					break
+				}
+			}
+			po.Offsets[offset] = time.Time{}
+		}
+		offsets[r.Partition] = po
+	}
+
+	res := &kafka.ListOffsetsResponse{Topics: make(map[string][]kafka.PartitionOffsets)}
+	for _, po := range offsets {
+		res.Topics[fa.topic] = append(res.Topics[fa.topic], po)
+	}
+	return res, nil
+}
+
+func (fa *fakeAdmin) OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error) {
+	if req.GenerationID != -1 {
+		return nil, errors.New("unexpected generation")
+	}
+	fa.committed = make(map[int]int64)
+	res := &kafka.OffsetCommitResponse{Topics: make(map[string][]kafka.OffsetCommitPartition)}
+	for _, c := range req.Topics[fa.topic] {
+		fa.committed[c.Partition] = c.Offset
+		res.Topics[fa.topic] = append(res.Topics[fa.topic], kafka.OffsetCommitPartition{Partition: c.Partition})
+	}
+	return res, nil
+}
+
+func newFakeAdmin(start time.Time) *fakeAdmin {
+	return &fakeAdmin{
+		topic: "orders",
+		first: map[int]int64{0: 10, 1: 0},
+		times: map[int][]time.Time{
+			0: {start, start.Add(time.Minute), start.Add(2 * time.Minute)},
+			1: {start, start.Add(30 * time.Second)},
+		},
+	}
+}
+
+func TestResetOffsets(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		to   OffsetSpec
+		want map[int]int64
+	}{
+		{"earliest", Earliest, map[int]int64{0: 10, 1: 0}},
+		{"latest", Latest, map[int]int64{0: 13, 1: 2}},
+		{"time", OffsetAt(start.Add(45 * time.Second)), map[int]int64{0: 11, 1: 2}},
+		{"time before all messages", OffsetAt(start.Add(-time.Hour)), map[int]int64{0: 10, 1: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin := newFakeAdmin(start)
+			if err := resetOffsets(context.Background(), admin, "group", "orders", tt.to); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(admin.committed, tt.want) {
+				t.Errorf("expected offsets %v, got %v", tt.want, admin.committed)
+			}
+		})
+	}
+}
+
+func TestResetOffsets_ActiveGroup(t *testing.T) {
+	admin := newFakeAdmin(time.Now())
+	admin.members = 1
+
+	if err := resetOffsets(context.Background(), admin, "group", "orders", Earliest); !errors.Is(err, ErrGroupActive) {
+		t.Errorf("expected ErrGroupActive, got %v", err)
+	}
+	if admin.committed != nil {
+		t.Errorf("no offsets should be committed while the group is active, got %v", admin.committed)
+	}
+}
+
+func TestResetOffsets_InvalidSpec(t *testing.T) {
+	admin := newFakeAdmin(time.Now())
+
+	if err := resetOffsets(context.Background(), admin, "group", "orders", OffsetSpec{}); err == nil {
+		t.Error("error was expected")
+	}
+	if err := resetOffsets(context.Background(), admin, "", "orders", Earliest); err == nil {
+		t.Error("error was expected")
+	}
+}
@@ -0,0 +1,46 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// The in-place retries double their delay from defaultInPlaceRetryBackoff up to defaultInPlaceRetryBackoffMax if
+// WithRetryBackoff is not used
+const (
+	defaultInPlaceRetryBackoff    = 100 * time.Millisecond
+	defaultInPlaceRetryBackoffMax = 10 * time.Second
+)
+
+// retryInPlace calls msgFunc again for a failed message until it succeeds or its retries are exhausted, waiting with
+// the retry backoff in between. The retry counter of m is incremented for every retry. It returns false if ctx is done
+// before the message has been handled, the message must not be committed then.
+func (mr *missyReader) retryInPlace(ctx context.Context, m Message, msgFunc ReadMessageFunc, err error) (Message, bool, error) {
+	for err != nil && !errors.Is(err, ErrDrop) && !errors.Is(err, ErrSendToDLQ) && !errors.Is(err, ErrSkip) && !mr.retriesExhausted(m) {
+		delay := mr.retryDelay(m)
+		if mr.retryBackoffBase <= 0 {
+			delay = defaultInPlaceRetryBackoff
+			for i := 0; i < m.RetryCounter && delay < defaultInPlaceRetryBackoffMax; i++ {
+				delay *= 2
+			}
+			if delay > defaultInPlaceRetryBackoffMax {
+				delay = defaultInPlaceRetryBackoffMax
+			}
+		}
+		mr.messageLogger(m).Warnf("# messaging # cannot process a message, retrying in place in %s: %v", delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return m, false, err
+		}
+
+		getReaderMetrics().onRetried(m)
+		m.RetryCounter++
+		err = msgFunc(m)
+	}
+	return m, true, err
+}
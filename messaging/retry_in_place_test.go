@@ -0,0 +1,110 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithInPlaceRetry_KeepsOrder(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for _, v := range []string{"a", "b"} {
+		if err := w.Write(nil, []byte(v)); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	r := im.NewReader("group", "orders", WithInPlaceRetry(), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	defer r.Close()
+
+	var mu sync.Mutex
+	var calls []string
+	r.Read(func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, string(m.Value)+string(rune('0'+m.RetryCounter)))
+		if string(m.Value) == "a" && m.RetryCounter < 2 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	waitFor(t, func() bool { return im.lag("group", "orders") == 0 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(calls, []string{"a0", "a1", "a2", "b0"}) {
+		t.Errorf("expected a to be retried before b is processed, got %v", calls)
+	}
+	if n := len(im.Messages("orders")); n != 2 {
+		t.Errorf("nothing should be re-enqueued to the topic, got %d messages", n)
+	}
+}
+
+func TestWithInPlaceRetry_DLQ(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("orders").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	r := im.NewReader("group", "orders", WithInPlaceRetry(), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	defer r.Close()
+	r.Read(func(m Message) error { return errors.New("failed") })
+
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+	record, err := ParseDLQMessage(im.Messages("orders.dlq")[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.RetryCount != defaultNumOfRetries {
+		t.Errorf("expected the message to be retried %d times, got %d", defaultNumOfRetries, record.RetryCount)
+	}
+	if n := len(im.Messages("orders")); n != 1 {
+		t.Errorf("nothing should be re-enqueued to the topic, got %d messages", n)
+	}
+}
+
+func TestWithInPlaceRetry_NotCommittedWhenStopped(t *testing.T) {
+	im := NewInMemory()
+	if err := im.NewWriter("orders").Write(nil, []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	r := im.NewReader("group", "orders", WithInPlaceRetry(), WithRetryBackoff(time.Hour, time.Hour))
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	failed := make(chan struct{})
+	var once sync.Once
+	r.ReadContext(ctx, func(m Message) error {
+		once.Do(func() { close(failed) })
+		return errors.New("failed")
+	})
+
+	<-failed
+	cancel()
+	r.Close()
+
+	if lag := im.lag("group", "orders"); lag != 1 {
+		t.Errorf("the message being retried should not be committed, got lag %d", lag)
+	}
+}
+
+func TestNewReader_InPlaceRetryWithConcurrency(t *testing.T) {
+	im := NewInMemory()
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("WithInPlaceRetry with WithConcurrency should panic without WithKeyOrdering")
+			}
+		}()
+		im.NewReader("group", "orders", WithInPlaceRetry(), WithConcurrency(4))
+	}()
+
+	r := im.NewReader("group", "orders", WithInPlaceRetry(), WithConcurrency(4), WithKeyOrdering())
+	r.Close()
+}
@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWithRetryTopicFunc(t *testing.T) {
+	im := NewInMemory()
+	retryTopic := func(attempt int) string {
+		if attempt == 1 {
+			return ""
+		}
+		return fmt.Sprintf("orders.retry.%d", attempt)
+	}
+	r := im.NewReader("group", "orders", WithRetryTopicFunc(retryTopic))
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.ReadContext(ctx, func(m Message) error {
+		return errors.New("failed")
+	})
+
+	if err := im.NewWriter("orders").Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	// the first attempt goes back to orders and is read again, later attempts are left to the readers of the retry topics
+	waitFor(t, func() bool { return len(im.Messages("orders.retry.2")) == 1 })
+
+	orders := im.Messages("orders")
+	if len(orders) != 2 || orders[1].RetryCounter != 1 {
+		t.Fatalf("expected the first retry to be re-enqueued to orders, got %+v", orders)
+	}
+	if m := im.Messages("orders.retry.2")[0]; m.RetryCounter != 2 || string(m.Value) != "value" {
+		t.Errorf("expected the second retry in orders.retry.2, got %+v", m)
+	}
+
+	// a reader of a retry topic keeps choosing topics by the attempt and sends the message to the DLQ of orders at last
+	retryReader := im.NewReader("group", "orders.retry.2", WithRetryTopicFunc(retryTopic), WithDLQTopic("orders.dlq"))
+	defer retryReader.Close()
+	go retryReader.ReadContext(ctx, func(m Message) error {
+		return errors.New("failed")
+	})
+	for attempt := 3; attempt <= defaultNumOfRetries; attempt++ {
+		retryReader := im.NewReader("group", fmt.Sprintf("orders.retry.%d", attempt), WithRetryTopicFunc(retryTopic), WithDLQTopic("orders.dlq"))
+		defer retryReader.Close()
+		go retryReader.ReadContext(ctx, func(m Message) error {
+			return errors.New("failed")
+		})
+	}
+
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+
+	var counters []int
+	for attempt := 2; attempt <= defaultNumOfRetries; attempt++ {
+		for _, m := range im.Messages(fmt.Sprintf("orders.retry.%d", attempt)) {
+			counters = append(counters, m.RetryCounter)
+		}
+	}
+	if !reflect.DeepEqual(counters, []int{2, 3, 4, 5}) {
+		t.Errorf("expected every attempt in its own retry topic, got retry counters %v", counters)
+	}
+	if len(im.Messages("orders.retry.6")) != 0 {
+		t.Error("exhausted messages must go to the DLQ, not to a retry topic")
+	}
+}
+
+func TestWithRetryTopicFunc_Nil(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a nil retry topic func")
+		}
+	}()
+	WithRetryTopicFunc(nil)(&missyReader{})
+}
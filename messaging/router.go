@@ -0,0 +1,64 @@
+package messaging
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/microdevs/missy/log"
+)
+
+// EventTypeHeader is the header NewRouter dispatches on by default
+const EventTypeHeader = "event-type"
+
+// Router dispatches the messages of a reader to the handler registered for the value of a header, for topics carrying
+// different event types. Pass Route as the msgFunc of the reader.
+type Router struct {
+	header string
+
+	mu             sync.RWMutex
+	handlers       map[string]ReadMessageFunc
+	defaultHandler ReadMessageFunc
+}
+
+// NewRouter returns a Router dispatching on header, an empty header dispatches on the EventTypeHeader
+func NewRouter(header string) *Router {
+	if header == "" {
+		header = EventTypeHeader
+	}
+	return &Router{header: header, handlers: make(map[string]ReadMessageFunc)}
+}
+
+// Handle registers the handler of messages whose header has value, a handler registered before for value is replaced
+func (r *Router) Handle(value string, handler ReadMessageFunc) {
+	if handler == nil {
+		log.Panicf("# messaging # invalid router config: the handler of %s %s must not be nil", r.header, value)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[value] = handler
+}
+
+// HandleDefault registers the handler of messages without a handler for their header value, including messages
+// without the header. Without a default handler these messages are sent to the DLQ.
+func (r *Router) HandleDefault(handler ReadMessageFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultHandler = handler
+}
+
+// Route calls the handler registered for the header value of m and returns its error
+func (r *Router) Route(m Message) error {
+	value, _ := m.GetHeader(r.header)
+
+	r.mu.RLock()
+	handler, ok := r.handlers[string(value)]
+	if !ok {
+		handler = r.defaultHandler
+	}
+	r.mu.RUnlock()
+
+	if handler == nil {
+		return fmt.Errorf("no handler for %s %q: %w", r.header, value, ErrSendToDLQ)
+	}
+	return handler(m)
+}
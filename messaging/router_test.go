@@ -0,0 +1,103 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func eventMessage(eventType string) Message {
+	m := Message{Value: []byte(eventType)}
+	if eventType != "" {
+		m.SetHeader(EventTypeHeader, []byte(eventType))
+	}
+	return m
+}
+
+func TestRouter_Route(t *testing.T) {
+	var routed []string
+	router := NewRouter("")
+	router.Handle("OrderCreated", func(m Message) error {
+		routed = append(routed, "created:"+string(m.Value))
+		return nil
+	})
+	router.Handle("OrderCancelled", func(m Message) error {
+		return errors.New("failed")
+	})
+	router.HandleDefault(func(m Message) error {
+		routed = append(routed, "default:"+string(m.Value))
+		return nil
+	})
+
+	for _, eventType := range []string{"OrderCreated", "OrderShipped", ""} {
+		if err := router.Route(eventMessage(eventType)); err != nil {
+			t.Errorf("unexpected error routing %q: %v", eventType, err)
+		}
+	}
+	if err := router.Route(eventMessage("OrderCancelled")); err == nil || err.Error() != "failed" {
+		t.Errorf("expected the error of the handler, got %v", err)
+	}
+
+	want := []string{"created:OrderCreated", "default:OrderShipped", "default:"}
+	if len(routed) != len(want) {
+		t.Fatalf("expected %v, got %v", want, routed)
+	}
+	for i := range want {
+		if routed[i] != want[i] {
+			t.Error(expected(routed[i], want[i]))
+		}
+	}
+}
+
+func TestRouter_RouteUnmatched(t *testing.T) {
+	router := NewRouter("type")
+	router.Handle("OrderCreated", func(m Message) error { return nil })
+
+	m := Message{}
+	m.SetHeader("type", []byte("OrderShipped"))
+	if err := router.Route(m); !errors.Is(err, ErrSendToDLQ) {
+		t.Errorf("expected unmatched messages to be sent to the DLQ, got %v", err)
+	}
+}
+
+func TestRouter_ReadUnmatchedToDLQ(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	var mu sync.Mutex
+	created := 0
+	router := NewRouter("")
+	router.Handle("OrderCreated", func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		created++
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := r.ReadContext(ctx, router.Route); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, m := range []Message{eventMessage("OrderCreated"), eventMessage("OrderShipped")} {
+		if err := w.WriteMessage(m); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+	if dlq := im.Messages("orders.dlq")[0]; string(dlq.Value) != "OrderShipped" {
+		t.Errorf("expected the unmatched message in the DLQ, got %+v", dlq)
+	}
+	waitFor(t, func() bool { return im.lag("group", "orders") == 0 })
+	mu.Lock()
+	defer mu.Unlock()
+	if created != 1 {
+		t.Errorf("expected 1 routed message, got %d", created)
+	}
+}
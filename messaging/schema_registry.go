@@ -0,0 +1,125 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// schemaRegistryContentType is the content type of requests to the Confluent Schema Registry
+const schemaRegistryContentType = "application/vnd.schemaregistry.v1+json"
+
+// schemaRegistryTimeout is the maximum time a request to the schema registry may take
+const schemaRegistryTimeout = 10 * time.Second
+
+// SchemaRegistry is a client of the Confluent Schema Registry REST API. Registered and fetched schemas are cached, so
+// the registry is only asked once per schema.
+type SchemaRegistry struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	schemas map[int]string
+	ids     map[string]int
+}
+
+// NewSchemaRegistry returns a client of the schema registry at the given url, e.g. http://localhost:8081
+func NewSchemaRegistry(registryURL string) *SchemaRegistry {
+	return &SchemaRegistry{
+		url:     strings.TrimSuffix(registryURL, "/"),
+		client:  &http.Client{Timeout: schemaRegistryTimeout},
+		schemas: make(map[int]string),
+		ids:     make(map[string]int),
+	}
+}
+
+// Register registers the Avro schema under subject, e.g. "<topic>-value", and returns its id. Registering a schema
+// which is already registered returns the existing id.
+func (sr *SchemaRegistry) Register(subject string, schema string) (int, error) {
+	return sr.RegisterType(subject, "AVRO", schema)
+}
+
+// RegisterType registers a schema of the given type, AVRO, PROTOBUF or JSON, under subject and returns its id
+func (sr *SchemaRegistry) RegisterType(subject string, schemaType string, schema string) (int, error) {
+	sr.mu.Lock()
+	id, ok := sr.ids[subject+"\x00"+schema]
+	sr.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	body, err := json.Marshal(struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}{schema, schemaType})
+	if err != nil {
+		return 0, err
+	}
+
+	var res struct {
+		ID int `json:"id"`
+	}
+	if err := sr.do(http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", body, &res); err != nil {
+		return 0, fmt.Errorf("could not register schema of subject %s: %w", subject, err)
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.ids[subject+"\x00"+schema] = res.ID
+	sr.schemas[res.ID] = schema
+	return res.ID, nil
+}
+
+// Schema returns the schema with the given id
+func (sr *SchemaRegistry) Schema(id int) (string, error) {
+	sr.mu.Lock()
+	schema, ok := sr.schemas[id]
+	sr.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	var res struct {
+		Schema string `json:"schema"`
+	}
+	if err := sr.do(http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &res); err != nil {
+		return "", fmt.Errorf("could not fetch schema %d: %w", id, err)
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.schemas[id] = res.Schema
+	return res.Schema, nil
+}
+
+// do sends a request to the schema registry and decodes the JSON response into res
+func (sr *SchemaRegistry) do(method string, path string, body []byte, res interface{}) error {
+	req, err := http.NewRequest(method, sr.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", schemaRegistryContentType)
+	if body != nil {
+		req.Header.Set("Content-Type", schemaRegistryContentType)
+	}
+
+	resp, err := sr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var registryErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&registryErr)
+		return fmt.Errorf("schema registry returned %s: %s", resp.Status, registryErr.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(res)
+}
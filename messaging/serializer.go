@@ -0,0 +1,79 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Serializer encodes values written with Writer.WriteValue into message values
+type Serializer interface {
+	Serialize(topic string, v interface{}) ([]byte, error)
+}
+
+// Deserializer decodes message values into the values passed to Reader.Decode
+type Deserializer interface {
+	Deserialize(topic string, data []byte, v interface{}) error
+}
+
+// RawSerializer is the default Serializer and Deserializer, it passes the message value through unchanged. It only
+// serializes []byte and deserializes into *[]byte.
+type RawSerializer struct{}
+
+// Serialize implements Serializer
+func (RawSerializer) Serialize(topic string, v interface{}) ([]byte, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("the raw serializer only writes []byte values, got %T", v)
+	}
+	return data, nil
+}
+
+// Deserialize implements Deserializer
+func (RawSerializer) Deserialize(topic string, data []byte, v interface{}) error {
+	target, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("the raw deserializer only reads into *[]byte values, got %T", v)
+	}
+	*target = data
+	return nil
+}
+
+// JSONSerializer encodes and decodes message values as JSON
+type JSONSerializer struct{}
+
+// Serialize implements Serializer
+func (JSONSerializer) Serialize(topic string, v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Deserialize implements Deserializer
+func (JSONSerializer) Deserialize(topic string, data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// WriteValue serializes v with the serializer of the writer and writes it as value of a new message
+func (mw *missyWriter) WriteValue(key []byte, v interface{}) error {
+	serializer := mw.serializer
+	if serializer == nil {
+		serializer = RawSerializer{}
+	}
+
+	value, err := serializer.Serialize(mw.topic, v)
+	if err != nil {
+		return fmt.Errorf("could not serialize value for topic %s: %w", mw.topic, err)
+	}
+	return mw.Write(key, value)
+}
+
+// Decode deserializes the value of m into v with the deserializer of the reader
+func (mr *missyReader) Decode(m Message, v interface{}) error {
+	deserializer := mr.deserializer
+	if deserializer == nil {
+		deserializer = RawSerializer{}
+	}
+
+	if err := deserializer.Deserialize(m.Topic, m.Value, v); err != nil {
+		return fmt.Errorf("could not deserialize message %d of topic %s partition %d: %w", m.Offset, m.Topic, m.Partition, err)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+)
+
+func TestMissyWriter_WriteValue(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	gomock.InOrder(
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Key: []byte("key"), Value: []byte("raw")}).Return(nil),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Key: []byte("key"), Value: []byte(`{"id":"order-1","amount":1.5}`)}).Return(nil),
+	)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, topic: "orders"}
+	if err := writer.WriteValue([]byte("key"), []byte("raw")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := writer.WriteValue([]byte("key"), order{ID: "order-1"}); err == nil {
+		t.Error("the raw serializer should only write []byte values")
+	}
+
+	WithSerializer(JSONSerializer{})(&writer)
+	if err := writer.WriteValue([]byte("key"), order{ID: "order-1", Amount: 1.5}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyReader_Decode(t *testing.T) {
+	reader := missyReader{}
+
+	var raw []byte
+	if err := reader.Decode(Message{Value: []byte("raw")}, &raw); err != nil || string(raw) != "raw" {
+		t.Errorf("expected the raw value, got %q, %v", raw, err)
+	}
+
+	WithDeserializer(JSONSerializer{})(&reader)
+	var decoded order
+	if err := reader.Decode(Message{Value: []byte(`{"id":"order-1","amount":1.5}`)}, &decoded); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if decoded != (order{ID: "order-1", Amount: 1.5}) {
+		t.Errorf("expected the decoded order, got %+v", decoded)
+	}
+	if err := reader.Decode(Message{Value: []byte("{")}, &decoded); err == nil {
+		t.Error("malformed values should fail")
+	}
+}
@@ -0,0 +1,59 @@
+package messaging
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/microdevs/missy/config"
+	"github.com/microdevs/missy/log"
+)
+
+// shutdownSignals are the signals ShutdownOnSignal shuts the readers down on
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// ShutdownOnSignal shuts the readers down once the process receives SIGTERM or an interrupt: they stop fetching, and the
+// messages being processed are handled and committed, so they are not delivered again after a restart. The returned
+// channel receives the first Shutdown error, or nil, once all readers are shut down or gracePeriod has expired, the
+// process should exit then. A gracePeriod of 0 uses 25 seconds. Services using service.Service register their readers
+// with RegisterReader instead, the service shuts them down on the same signals with the same default grace period.
+func ShutdownOnSignal(gracePeriod time.Duration, readers ...Reader) <-chan error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, shutdownSignals...)
+
+	return shutdownOn(signals, gracePeriod, readers, func() { signal.Stop(signals) })
+}
+
+// shutdownOn shuts the readers down once a signal is received and reports the result on the returned channel
+func shutdownOn(signals <-chan os.Signal, gracePeriod time.Duration, readers []Reader, stopSignals func()) <-chan error {
+	if gracePeriod <= 0 {
+		gracePeriod = config.DefaultShutdownGracePeriod
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+
+		sig := <-signals
+		stopSignals()
+		log.Infof("# messaging # received %s, shutting down %d readers within %s", sig, len(readers), gracePeriod)
+
+		ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+		defer cancel()
+
+		errs := make(chan error, len(readers))
+		for _, r := range readers {
+			go func(r Reader) { errs <- r.Shutdown(ctx) }(r)
+		}
+		var err error
+		for range readers {
+			if rErr := <-errs; rErr != nil && err == nil {
+				err = rErr
+			}
+		}
+		done <- err
+	}()
+	return done
+}
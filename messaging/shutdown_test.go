@@ -0,0 +1,91 @@
+package messaging
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownOn(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders")
+
+	processing := make(chan struct{})
+	var finished int32
+	if err := r.Read(func(m Message) error {
+		close(processing)
+		time.Sleep(200 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	stopped := false
+	done := shutdownOn(signals, time.Second, []Reader{r}, func() { stopped = true })
+
+	<-processing
+	signals <- syscall.SIGTERM
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the readers have not been shut down")
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("the message being processed should be finished before shutting down")
+	}
+	if lag := im.lag("group", "orders"); lag != 0 {
+		t.Errorf("the message being processed should be committed, got lag %d", lag)
+	}
+	if !stopped {
+		t.Error("the signals should not be received anymore after shutting down")
+	}
+	if err := r.Read(func(m Message) error { return nil }); err != ErrReaderClosed {
+		t.Errorf("expected ErrReaderClosed, got %v", err)
+	}
+}
+
+func TestShutdownOn_GracePeriodExpired(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	r := im.NewReader("group", "orders")
+
+	processing := make(chan struct{})
+	if err := r.ReadCtx(context.Background(), func(ctx context.Context, m Message) error {
+		close(processing)
+		<-ctx.Done()
+		return ctx.Err()
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	done := shutdownOn(signals, 100*time.Millisecond, []Reader{r}, func() {})
+
+	<-processing
+	signals <- os.Interrupt
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected the grace period to expire, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shutdown did not return after the grace period")
+	}
+}
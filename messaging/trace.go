@@ -0,0 +1,21 @@
+package messaging
+
+import (
+	"strconv"
+)
+
+// TraceIDHeader is the header carrying the id which correlates the log entries of a message. Messages without the
+// header are identified by their topic, partition and offset, retried messages and messages sent to the DLQ keep the id
+// of the message they have been created from.
+const TraceIDHeader = "missy-trace-id"
+
+// traceID returns the id of m from the TraceIDHeader or, if it has none, the id derived from its position
+func (m Message) traceID() string {
+	if id, ok := m.GetHeader(TraceIDHeader); ok && len(id) > 0 {
+		return string(id)
+	}
+	if m.Topic == "" {
+		return ""
+	}
+	return m.Topic + "-" + strconv.Itoa(m.Partition) + "-" + strconv.FormatInt(m.Offset, 10)
+}
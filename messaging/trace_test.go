@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// traceHeaders returns the headers of a message re-enqueued after m failed
+func traceHeaders(m Message) []Header {
+	return []Header{{Key: TraceIDHeader, Value: []byte(m.traceID())}}
+}
+
+func TestMessage_TraceID(t *testing.T) {
+	m := Message{Topic: "orders", Partition: 2, Offset: 42}
+	if id := m.traceID(); id != "orders-2-42" {
+		t.Error(expected(id, "orders-2-42"))
+	}
+
+	m.SetHeader(TraceIDHeader, []byte("abc"))
+	if id := m.traceID(); id != "abc" {
+		t.Error(expected(id, "abc"))
+	}
+	if id := m.logFields()["trace_id"]; id != "abc" {
+		t.Errorf("expected the trace id in the log fields, got %v", id)
+	}
+}
+
+func TestTraceID_StableAcrossRetries(t *testing.T) {
+	im := NewInMemory()
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	var mu sync.Mutex
+	var ids []string
+	r.ReadContext(context.Background(), func(m Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ids = append(ids, m.traceID())
+		return errors.New("failed")
+	})
+
+	if err := im.NewWriter("orders").Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+	waitFor(t, func() bool { return len(im.Messages("orders.dlq")) == 1 })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ids) != 6 {
+		t.Fatalf("expected the message to be processed 6 times, got %d", len(ids))
+	}
+	for _, id := range ids {
+		if id != "orders-0-0" {
+			t.Errorf("expected every attempt to have the trace id of the first one, got %v", ids)
+			break
+		}
+	}
+	if id := im.Messages("orders.dlq")[0].traceID(); id != "orders-0-0" {
+		t.Error(expected(id, "orders-0-0"))
+	}
+}
@@ -0,0 +1,40 @@
+package messaging
+
+import "context"
+
+// Tracing propagates the trace context of distributed tracing through message headers. The opentelemetry package
+// implements it for OpenTelemetry, it is a separate package so only users of tracing depend on OpenTelemetry.
+type Tracing interface {
+	// Inject adds the trace context of ctx to the headers of a message before it is written
+	Inject(ctx context.Context, m *Message)
+	// StartSpan starts the span around msgFunc for a read message and returns the context passed to msgFunc, end is
+	// called with the error msgFunc returned
+	StartSpan(ctx context.Context, m Message) (spanCtx context.Context, end func(err error))
+}
+
+// WithTracing starts a span of t around msgFunc for every message, continuing the trace context in its headers. Use a
+// ReadCtx msgFunc to create child spans from the context it receives.
+func WithTracing(t Tracing) ReaderOption {
+	return func(mr *missyReader) {
+		mr.tracing = t
+	}
+}
+
+// WithWriterTracing adds the trace context of the context passed to WriteContext and WriteBatchContext to the headers
+// of the written messages
+func WithWriterTracing(t Tracing) WriterOption {
+	return func(mw *missyWriter) {
+		mw.tracing = t
+	}
+}
+
+// inject returns msgs with the trace context of ctx added to their headers, the headers of msgs are not modified
+func (mw *missyWriter) inject(ctx context.Context, msgs []Message) []Message {
+	traced := make([]Message, len(msgs))
+	for i, m := range msgs {
+		m.Headers = append([]Header(nil), m.Headers...)
+		mw.tracing.Inject(ctx, &m)
+		traced[i] = m
+	}
+	return traced
+}
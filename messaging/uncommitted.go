@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+)
+
+// uncommittedLimit counts the messages which have been processed but not committed yet and blocks fetching while
+// there are max of them
+type uncommittedLimit struct {
+	mu      sync.Mutex
+	max     int
+	count   int
+	pending map[partitionKey][]int64
+	// freed is closed and replaced whenever messages have been committed, waiting fetches wait for it
+	freed chan struct{}
+}
+
+// newUncommittedLimit returns a limit of max processed but uncommitted messages
+func newUncommittedLimit(max int) *uncommittedLimit {
+	return &uncommittedLimit{max: max, pending: make(map[partitionKey][]int64), freed: make(chan struct{})}
+}
+
+// processed counts a message which has been handled and waits to be committed
+func (ul *uncommittedLimit) processed(m Message) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+
+	key := partitionKey{topic: m.Topic, partition: m.Partition}
+	ul.pending[key] = append(ul.pending[key], m.Offset)
+	ul.count++
+}
+
+// committed stops counting the messages up to the offsets of msgs, committing a message commits all previous
+// messages of its partition
+func (ul *uncommittedLimit) committed(msgs []Message) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+
+	before := ul.count
+	for _, m := range msgs {
+		key := partitionKey{topic: m.Topic, partition: m.Partition}
+		// with concurrent workers messages are not processed in offset order
+		kept := ul.pending[key][:0]
+		for _, offset := range ul.pending[key] {
+			if offset > m.Offset {
+				kept = append(kept, offset)
+			}
+		}
+		ul.count -= len(ul.pending[key]) - len(kept)
+		if len(kept) == 0 {
+			delete(ul.pending, key)
+		} else {
+			ul.pending[key] = kept
+		}
+	}
+	if ul.count < before {
+		close(ul.freed)
+		ul.freed = make(chan struct{})
+	}
+}
+
+// wait blocks while the limit is reached, it returns the error of ctx if ctx is done first
+func (ul *uncommittedLimit) wait(ctx context.Context) error {
+	for {
+		ul.mu.Lock()
+		if ul.count < ul.max {
+			ul.mu.Unlock()
+			return nil
+		}
+		freed := ul.freed
+		ul.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
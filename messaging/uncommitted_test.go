@@ -0,0 +1,98 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithMaxUncommitted_PausesFetching(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for _, v := range []string{"1", "2", "3", "4", "5"} {
+		if err := w.Write(nil, []byte(v)); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+
+	r := im.NewReader("group", "orders", WithManualCommit(), WithMaxUncommitted(2))
+	defer r.Close()
+
+	var mu sync.Mutex
+	var handled []Message
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled)
+	}
+	if err := r.Read(func(m Message) error {
+		mu.Lock()
+		handled = append(handled, m)
+		mu.Unlock()
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the application does not commit, so fetching pauses at the limit
+	waitFor(t, func() bool { return count() == 2 })
+	time.Sleep(100 * time.Millisecond)
+	if n := count(); n != 2 {
+		t.Fatalf("expected fetching to pause after 2 uncommitted messages, got %d", n)
+	}
+
+	// committing the first message frees a slot for one more message
+	mu.Lock()
+	first := handled[0]
+	mu.Unlock()
+	if err := r.CommitMessages(first); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return count() == 3 })
+	time.Sleep(100 * time.Millisecond)
+	if n := count(); n != 3 {
+		t.Fatalf("expected fetching to pause again after 3 messages, got %d", n)
+	}
+
+	// committing the last handled message commits all previous ones as well
+	mu.Lock()
+	last := handled[2]
+	mu.Unlock()
+	if err := r.CommitMessages(last); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return count() == 5 })
+}
+
+func TestUncommittedLimit_Wait(t *testing.T) {
+	ul := newUncommittedLimit(2)
+	ul.processed(Message{Topic: "orders", Partition: 0, Offset: 1})
+	ul.processed(Message{Topic: "orders", Partition: 1, Offset: 7})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := ul.wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected wait to block at the limit, got %v", err)
+	}
+
+	// a commit of another partition does not free the slots
+	ul.committed([]Message{{Topic: "orders", Partition: 2, Offset: 9}})
+	if ul.count != 2 {
+		t.Errorf("expected 2 uncommitted messages, got %d", ul.count)
+	}
+
+	ul.committed([]Message{{Topic: "orders", Partition: 1, Offset: 7}})
+	if err := ul.wait(context.Background()); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestNewReader_WithMaxUncommittedBelowBatchSize(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a batch size which can never be committed")
+		}
+	}()
+	NewReader([]string{"localhost:9091"}, "group", "orders", WithCommitBatchSize(10), WithMaxUncommitted(5))
+}
@@ -2,14 +2,32 @@ package messaging
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
-// Writer is used to write messages to underlying broker
+// Writer is used to write messages to underlying broker, tests injecting writers can use MockWriter which is generated
+// together with the BrokerWriter mock
 type Writer interface {
 	Write(key []byte, value []byte) error
+	WriteContext(ctx context.Context, key []byte, value []byte) error
+	WriteWithRetryCounter(key []byte, value []byte, retryCounter int) error
+	WriteWithHeaders(key []byte, value []byte, headers ...Header) error
+	WriteTo(topic string, key []byte, value []byte) error
+	WriteValue(key []byte, v interface{}) error
+	WriteString(key string, value []byte) error
+	WriteJSON(key string, v interface{}) error
+	WriteMessage(m Message) error
+	WriteBatch(msgs []Message) error
+	WriteBatchContext(ctx context.Context, msgs []Message) error
+	ProduceSync(ctx context.Context, msg Message) (partition int, offset int64, err error)
+	Flush(ctx context.Context) error
+	Stats() kafka.WriterStats
+	Ping(ctx context.Context) error
 	io.Closer
 }
 
@@ -17,6 +35,7 @@ type Writer interface {
 //go:generate mockgen -package=messaging -destination broker_writer_mock.go -source writer.go BrokerWriter
 type BrokerWriter interface {
 	WriteMessages(ctx context.Context, msgs ...Message) error
+	Stats() kafka.WriterStats
 	io.Closer
 }
 
@@ -24,7 +43,22 @@ type BrokerWriter interface {
 type missyWriter struct {
 	brokers      []string
 	topic        string
+	config       kafka.WriterConfig
 	brokerWriter BrokerWriter
+	produce      produceFunc
+	delivery     DeliveryCallback
+	pending      *pendingWrites
+	closeTimeout time.Duration
+
+	writeRetries      int
+	writeRetryBackoff time.Duration
+	idempotent        bool
+	requiredAcks      *kafka.RequiredAcks
+	compression       kafka.Compression
+	serializer        Serializer
+	maxMessageBytes   int
+	logger            Logger
+	tracing           Tracing
 }
 
 // writeBroker us as a wrapper for kafka.Writer implementation to fulfill BrokerWriter interface
@@ -38,7 +72,7 @@ func (wb *writeBroker) WriteMessages(ctx context.Context, msgs ...Message) error
 	kafkaMessages := make([]kafka.Message, len(msgs))
 
 	for i, m := range msgs {
-		kMessage := kafka.Message{Key: m.Key, Value: m.Value}
+		kMessage := kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value, Headers: toKafkaHeaders(m)}
 		kafkaMessages[i] = kMessage
 	}
 
@@ -50,30 +84,293 @@ func (wb *writeBroker) Close() error {
 	return wb.Writer.Close()
 }
 
+// keyBalancer is the default balancer of the writer. Messages with a key are assigned to a partition by the hash of
+// their key, so messages with the same key keep their order. Messages without a key go to the partition which
+// received the least bytes.
+type keyBalancer struct {
+	hash       kafka.Hash
+	leastBytes kafka.LeastBytes
+}
+
+// Balance implements kafka.Balancer
+func (kb *keyBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if len(msg.Key) > 0 {
+		return kb.hash.Balance(msg, partitions...)
+	}
+	return kb.leastBytes.Balance(msg, partitions...)
+}
+
+// partitionBalancer writes all messages to the same partition
+type partitionBalancer struct {
+	partition int
+}
+
+// Balance implements kafka.Balancer
+func (pb *partitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	return pb.partition
+}
+
 // NewWriter based on brokers hosts, consumerGroup and topic. You need to close it after use. (Close())
-// we are leaving using the missy config for now, because we don't know how we want to configure this yet.
-func NewWriter(brokers []string, topic string) Writer {
+// Use NewWriterFromConfig to read brokers and topic from the missy config instead.
+// The defaults of the underlying writer can be changed by passing WriterOptions.
+func NewWriter(brokers []string, topic string, opts ...WriterOption) Writer {
+
+	mw := &missyWriter{
+		brokers: brokers,
+		topic:   topic,
+		config: kafka.WriterConfig{
+			Brokers:  brokers,
+			Topic:    topic,
+			Balancer: &keyBalancer{},
+		},
+	}
+
+	for _, opt := range opts {
+		opt(mw)
+	}
 
 	// kafka writer
-	w := kafka.NewWriter(kafka.WriterConfig{
-		Brokers:  brokers,
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
-	})
+	kw := kafka.NewWriter(mw.config)
+	if mw.requiredAcks != nil {
+		// set on the kafka writer, its config treats 0 as the default instead of kafka.RequireNone
+		kw.RequiredAcks = *mw.requiredAcks
+	}
+	if mw.config.Async {
+		mw.pending = &pendingWrites{}
+		kw.Completion = mw.completed
+	}
+	mw.brokerWriter = &writeBroker{kw}
+	mw.produce = kafkaProduce(brokers, mw.config.Dialer, mw.config.Balancer, kw.RequiredAcks, mw.compression)
 
-	return &missyWriter{brokers: brokers, topic: topic, brokerWriter: &writeBroker{w}}
+	return mw
+}
+
+// NewMultiTopicWriter returns a Writer which is not bound to a topic, messages are written with WriteTo to any topic
+// over the same connections. Write, WriteMessage and the other methods without a topic fail on such a writer.
+func NewMultiTopicWriter(brokers []string, opts ...WriterOption) Writer {
+	return NewWriter(brokers, "", opts...)
 }
 
 // Write new message
 func (mw *missyWriter) Write(key []byte, value []byte) error {
+	return mw.WriteContext(context.Background(), key, value)
+}
+
+// WriteString writes a new message with a string key, like Write
+func (mw *missyWriter) WriteString(key string, value []byte) error {
+	return mw.Write([]byte(key), value)
+}
+
+// WriteContext writes a new message and gives up once ctx is cancelled or its deadline expires
+func (mw *missyWriter) WriteContext(ctx context.Context, key []byte, value []byte) error {
 	msg := Message{
 		Key:   key,
 		Value: value,
 	}
-	return mw.brokerWriter.WriteMessages(context.Background(), msg)
+	return mw.write(ctx, msg)
+}
+
+// WriteWithRetryCounter writes a new message marked with the number of times it has already been retried
+func (mw *missyWriter) WriteWithRetryCounter(key []byte, value []byte, retryCounter int) error {
+	msg := Message{
+		Key:          key,
+		Value:        value,
+		RetryCounter: retryCounter,
+	}
+	return mw.write(context.Background(), msg)
+}
+
+// WriteWithHeaders writes a new message with the given headers, e.g. to propagate a trace id
+func (mw *missyWriter) WriteWithHeaders(key []byte, value []byte, headers ...Header) error {
+	msg := Message{
+		Key:     key,
+		Value:   value,
+		Headers: headers,
+	}
+	return mw.write(context.Background(), msg)
+}
+
+// WriteTo writes a new message to the given topic. Writers created by NewWriter only write to their own topic.
+func (mw *missyWriter) WriteTo(topic string, key []byte, value []byte) error {
+	msg := Message{
+		Key:   key,
+		Value: value,
+	}
+	if mw.topic == "" {
+		msg.Topic = topic
+	} else if topic != mw.topic {
+		return fmt.Errorf("the writer of topic %s cannot write to topic %s, use NewMultiTopicWriter", mw.topic, topic)
+	}
+	return mw.write(context.Background(), msg)
+}
+
+// BatchError is returned by WriteBatch if some messages of the batch could not be written. Errors has an entry for
+// every message of the batch, which is nil if the message has been written.
+type BatchError struct {
+	Errors []error
+}
+
+// Error implements the error interface
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d messages could not be written", len(e.Failed()), len(e.Errors))
+}
+
+// Failed returns the indexes of the messages which could not be written
+func (e *BatchError) Failed() []int {
+	var failed []int
+	for i, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
 }
 
-// Close writer after use
+// ErrMessageTooLarge is matched by errors.Is for a *MessageTooLargeError
+var ErrMessageTooLarge = errors.New("message too large")
+
+// MessageTooLargeError is returned by writers with WithMaxMessageBytes for messages larger than the maximum, none of
+// the messages of the write have been sent then
+type MessageTooLargeError struct {
+	Size     int
+	MaxBytes int
+}
+
+// Error implements the error interface
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("message of %d bytes exceeds the maximum of %d bytes", e.Size, e.MaxBytes)
+}
+
+// Is makes errors.Is(err, ErrMessageTooLarge) match
+func (e *MessageTooLargeError) Is(target error) bool {
+	return target == ErrMessageTooLarge
+}
+
+// WriteMessage writes a message with its key, value, headers and retry counter to the topic of the writer. Topic,
+// partition and offset of the message are ignored, the partition is chosen by the balancer of the writer.
+func (mw *missyWriter) WriteMessage(m Message) error {
+	return mw.write(context.Background(), writableMessage(m))
+}
+
+// WriteBatch writes all messages with one request per partition, which is a lot faster than writing them one by one.
+// Like WriteMessage, only key, value, headers and retry counter are written. If some but not all messages could not be
+// written, a *BatchError telling which ones is returned, the other messages have been written. Other errors mean that
+// none of the messages have been written.
+func (mw *missyWriter) WriteBatch(msgs []Message) error {
+	return mw.WriteBatchContext(context.Background(), msgs)
+}
+
+// WriteBatchContext works like WriteBatch, but gives up once ctx is cancelled or its deadline expires
+func (mw *missyWriter) WriteBatchContext(ctx context.Context, msgs []Message) error {
+	batch := make([]Message, len(msgs))
+	for i, m := range msgs {
+		batch[i] = writableMessage(m)
+	}
+
+	err := mw.write(ctx, batch...)
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) && len(writeErrors) == len(msgs) {
+		return &BatchError{Errors: writeErrors}
+	}
+	return err
+}
+
+// writableMessage returns the parts of m which are written by the writer
+func writableMessage(m Message) Message {
+	return Message{
+		Key:          m.Key,
+		Value:        m.Value,
+		RetryCounter: m.RetryCounter,
+		Headers:      m.Headers,
+	}
+}
+
+// write passes the messages to the broker writer. Messages accepted by an async writer are pending until the broker
+// writer reports their outcome.
+func (mw *missyWriter) write(ctx context.Context, msgs ...Message) error {
+	msgs, err := mw.prepare(ctx, msgs)
+	if err != nil {
+		return err
+	}
+
+	if mw.pending == nil {
+		start := time.Now()
+		err = mw.writeWithRetries(ctx, msgs...)
+		getWriterMetrics().onWrite(mw.metricsTopic(msgs), start)
+		getWriterMetrics().onWritten(mw.topic, msgs, err)
+		return err
+	}
+
+	mw.pending.add(len(msgs))
+	err = mw.brokerWriter.WriteMessages(ctx, msgs...)
+	if err != nil {
+		// the messages have not been accepted, completed is not called for them
+		mw.pending.done(len(msgs))
+	}
+	return err
+}
+
+// prepare checks the size of the messages and adds the trace context and message ids to them before they are written
+func (mw *missyWriter) prepare(ctx context.Context, msgs []Message) ([]Message, error) {
+	if mw.maxMessageBytes > 0 {
+		for _, m := range msgs {
+			if size := m.Size(); size > mw.maxMessageBytes {
+				err := &MessageTooLargeError{Size: size, MaxBytes: mw.maxMessageBytes}
+				getWriterMetrics().onWritten(mw.topic, msgs, err)
+				return nil, err
+			}
+		}
+	}
+
+	if mw.tracing != nil {
+		msgs = mw.inject(ctx, msgs)
+	}
+
+	if mw.idempotent {
+		// the id is set once, so retries of the message carry the same id
+		identified := make([]Message, len(msgs))
+		for i, m := range msgs {
+			identified[i] = withMessageID(m)
+		}
+		msgs = identified
+	}
+	return msgs, nil
+}
+
+// metricsTopic returns the topic label of the write duration, a multi topic writer uses the topic of the first message
+func (mw *missyWriter) metricsTopic(msgs []Message) string {
+	if mw.topic == "" && len(msgs) > 0 {
+		return msgs[0].Topic
+	}
+	return mw.topic
+}
+
+// Stats returns the statistics of the underlying kafka writer. Like the reader statistics, counters are reset on every
+// call.
+func (mw *missyWriter) Stats() kafka.WriterStats {
+	return mw.brokerWriter.Stats()
+}
+
+// Close writer after use. An async writer waits up to the close timeout for pending messages to be written first, an
+// error is returned if some of them have not been written once the writer has been closed.
 func (mw *missyWriter) Close() error {
-	return mw.brokerWriter.Close()
+	timeout := mw.closeTimeout
+	if timeout <= 0 {
+		timeout = closeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := mw.Flush(ctx); err != nil {
+		mw.getLogger().Warnf("# messaging # timed out after %s waiting for pending messages of topic %s to be written", timeout, mw.topic)
+	}
+
+	if err := mw.brokerWriter.Close(); err != nil {
+		return err
+	}
+	if mw.pending != nil {
+		if n := mw.pending.len(); n > 0 {
+			return fmt.Errorf("%d messages of topic %s have not been written before closing the writer", n, mw.topic)
+		}
+	}
+	return nil
 }
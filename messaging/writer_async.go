@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DeliveryCallback is called by an async writer once a message has been written, err is nil on success
+type DeliveryCallback func(m Message, err error)
+
+// pendingWrites counts the messages an async writer has accepted but not written yet
+type pendingWrites struct {
+	mu      sync.Mutex
+	count   int
+	waiters []chan struct{}
+}
+
+// add counts n more pending messages
+func (pw *pendingWrites) add(n int) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.count += n
+}
+
+// done marks n pending messages as written and wakes up the waiters once no message is pending anymore
+func (pw *pendingWrites) done(n int) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.count -= n
+	if pw.count > 0 {
+		return
+	}
+	pw.count = 0
+	for _, w := range pw.waiters {
+		close(w)
+	}
+	pw.waiters = nil
+}
+
+// len returns the number of pending messages
+func (pw *pendingWrites) len() int {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.count
+}
+
+// wait blocks until no message is pending anymore or ctx is done
+func (pw *pendingWrites) wait(ctx context.Context) error {
+	pw.mu.Lock()
+	if pw.count == 0 {
+		pw.mu.Unlock()
+		return nil
+	}
+	idle := make(chan struct{})
+	pw.waiters = append(pw.waiters, idle)
+	pw.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush waits until all messages accepted by an async writer have been written or ctx is done. It returns right away
+// for writers which are not async, their writes return once the messages have been written.
+func (mw *missyWriter) Flush(ctx context.Context) error {
+	if mw.pending == nil {
+		return nil
+	}
+	return mw.pending.wait(ctx)
+}
+
+// completed is called by the kafka writer in async mode once messages have been written or failed
+func (mw *missyWriter) completed(msgs []kafka.Message, err error) {
+	delivered := make([]Message, len(msgs))
+	for i, m := range msgs {
+		delivered[i] = fromKafkaMessage(m)
+	}
+	getWriterMetrics().onWritten(mw.topic, delivered, err)
+
+	if mw.delivery != nil {
+		for _, msg := range delivered {
+			// the kafka writer reuses the message bytes once completed returns
+			msg.Key = append([]byte(nil), msg.Key...)
+			msg.Value = append([]byte(nil), msg.Value...)
+			mw.delivery(msg, err)
+		}
+	}
+	mw.pending.done(len(msgs))
+}
@@ -0,0 +1,171 @@
+package messaging
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/microdevs/missy/log"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+)
+
+// WriterOption configures the Writer returned by NewWriter
+type WriterOption func(*missyWriter)
+
+// WithWriterTLS connects the writer to the brokers using TLS
+func WithWriterTLS(config *tls.Config) WriterOption {
+	return func(mw *missyWriter) {
+		mw.config.Dialer = withTLS(mw.config.Dialer, config)
+	}
+}
+
+// WithWriterSASL authenticates the writer with the brokers using the given SASL mechanism, e.g. plain.Mechanism
+func WithWriterSASL(mechanism sasl.Mechanism) WriterOption {
+	return func(mw *missyWriter) {
+		mw.config.Dialer = withSASL(mw.config.Dialer, mechanism)
+	}
+}
+
+// WithCompression compresses the written messages with the given codec, e.g. kafka.Zstd. Messages are not compressed
+// by default. Readers decompress messages transparently.
+func WithCompression(codec kafka.Compression) WriterOption {
+	return func(mw *missyWriter) {
+		mw.config.CompressionCodec = codec.Codec()
+		mw.compression = codec
+	}
+}
+
+// WithBalancer sets how messages are distributed to the partitions of the topic, e.g. &kafka.Hash{},
+// &kafka.RoundRobin{}, &kafka.LeastBytes{} or a custom kafka.Balancer. By default messages with a key are hashed by key
+// to keep the order of messages with the same key, messages without a key go to the least loaded partition.
+func WithBalancer(balancer kafka.Balancer) WriterOption {
+	return func(mw *missyWriter) {
+		mw.config.Balancer = balancer
+	}
+}
+
+// WithPartition writes all messages to the given partition of the topic instead of distributing them with a balancer,
+// e.g. for a partition per tenant read with NewPartitionReader. The partition must exist, it is not checked by the
+// writer.
+func WithPartition(partition int) WriterOption {
+	return func(mw *missyWriter) {
+		if partition < 0 {
+			log.Panicf("# messaging # invalid partition %d: must not be negative", partition)
+		}
+		mw.config.Balancer = &partitionBalancer{partition: partition}
+	}
+}
+
+// WithAsync makes the writer asynchronous. Writes return once the messages are queued, they are written in batches in
+// the background and the outcome of every message is reported to callback, which may be nil. Errors of async writes
+// are not returned by the writes and messages are not written again if they failed. Messages with the same key keep
+// their order, messages of different partitions are written in any order. Flush waits for queued messages to be
+// written, Close does so as well before closing the writer.
+func WithAsync(callback DeliveryCallback) WriterOption {
+	return func(mw *missyWriter) {
+		mw.config.Async = true
+		mw.delivery = callback
+	}
+}
+
+// WithWriteRetries writes messages again up to n times if a write fails with a retryable error, like an unavailable
+// leader during a leader election. The first retry waits for backoff, which doubles for every further retry. Errors
+// like a too large message are returned right away. Writes of an async writer are not retried.
+func WithWriteRetries(n int, backoff time.Duration) WriterOption {
+	return func(mw *missyWriter) {
+		if n < 0 {
+			log.Panicf("# messaging # the number of write retries must not be negative, got %d", n)
+		}
+		mw.writeRetries = n
+		mw.writeRetryBackoff = backoff
+	}
+}
+
+// WithIdempotentProducer waits for all in-sync replicas to acknowledge writes and gives every message a unique id in
+// the MessageIDHeader, unless it already has one. A message which is written again by WithWriteRetries keeps its id, so
+// readers using WithDedup skip the duplicates. The kafka writer does not support the idempotent producer of the
+// broker, duplicates are only removed by the readers. Message headers require Kafka 0.11 or newer.
+func WithIdempotentProducer() WriterOption {
+	return func(mw *missyWriter) {
+		mw.idempotent = true
+		WithRequiredAcks(kafka.RequireAll)(mw)
+	}
+}
+
+// WithRequiredAcks sets how many replicas have to acknowledge a write before it succeeds. kafka.RequireAll, the
+// default, waits for all in-sync replicas and does not lose acknowledged messages as long as one of them survives.
+// kafka.RequireOne only waits for the leader, which is faster, but messages are lost if the leader fails before the
+// followers copied them. kafka.RequireNone does not wait at all, write errors of the broker are not noticed then.
+func WithRequiredAcks(acks kafka.RequiredAcks) WriterOption {
+	return func(mw *missyWriter) {
+		if acks < kafka.RequireAll {
+			log.Panicf("# messaging # invalid number of required acks %d", acks)
+		}
+		mw.requiredAcks = &acks
+	}
+}
+
+// WithSerializer encodes the values passed to WriteValue with s, e.g. JSONSerializer or an AvroSerializer. By
+// default WriteValue only writes []byte values unchanged.
+func WithSerializer(s Serializer) WriterOption {
+	return func(mw *missyWriter) {
+		mw.serializer = s
+	}
+}
+
+// WithWriterCloseTimeout sets how long Close waits for pending messages of an async writer to be written, the default
+// is 5 seconds
+func WithWriterCloseTimeout(timeout time.Duration) WriterOption {
+	return func(mw *missyWriter) {
+		mw.closeTimeout = timeout
+	}
+}
+
+// WithWriterLogger logs with logger instead of missy/log
+func WithWriterLogger(logger Logger) WriterOption {
+	return func(mw *missyWriter) {
+		mw.logger = logger
+	}
+}
+
+// WithMaxMessageBytes rejects messages whose key, value and headers are larger than n bytes with a
+// *MessageTooLargeError before they are sent, e.g. set to the max.message.bytes of the topic. By default the size is
+// only checked by the broker.
+func WithMaxMessageBytes(n int) WriterOption {
+	return func(mw *missyWriter) {
+		if n <= 0 {
+			log.Panicf("# messaging # invalid max message bytes %d: must be greater than 0", n)
+		}
+		mw.maxMessageBytes = n
+	}
+}
+
+// withDialer makes the writer connect with the given dialer, it is used to share the dialer of a reader
+func withDialer(dialer *kafka.Dialer) WriterOption {
+	return func(mw *missyWriter) {
+		mw.config.Dialer = dialer
+	}
+}
+
+// withTLS returns a dialer using TLS, based on the given dialer or the kafka default dialer
+func withTLS(dialer *kafka.Dialer, config *tls.Config) *kafka.Dialer {
+	d := copyDialer(dialer)
+	d.TLS = config
+	return d
+}
+
+// withSASL returns a dialer authenticating with SASL, based on the given dialer or the kafka default dialer
+func withSASL(dialer *kafka.Dialer, mechanism sasl.Mechanism) *kafka.Dialer {
+	d := copyDialer(dialer)
+	d.SASLMechanism = mechanism
+	return d
+}
+
+// copyDialer returns a copy of the given dialer or of the kafka default dialer if it is nil
+func copyDialer(dialer *kafka.Dialer) *kafka.Dialer {
+	if dialer == nil {
+		dialer = kafka.DefaultDialer
+	}
+	d := *dialer
+	return &d
+}
@@ -0,0 +1,172 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+)
+
+func TestNewWriter_WithTLSAndSASL(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "kafka"}
+	mechanism := plain.Mechanism{Username: "user", Password: "secret"}
+
+	w := NewWriter([]string{"localhost:9091"}, "test", WithWriterTLS(tlsConfig), WithWriterSASL(mechanism)).(*missyWriter)
+
+	if w.config.Dialer == nil || w.config.Dialer.TLS != tlsConfig || w.config.Dialer.SASLMechanism != mechanism {
+		t.Error("TLS and SASL were not set on the writer dialer")
+	}
+}
+
+func TestNewWriter_PlaintextByDefault(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test").(*missyWriter)
+
+	if w.config.Dialer != nil {
+		t.Error("the writer should use the default dialer without TLS and SASL")
+	}
+}
+
+func TestNewWriter_NoCompressionByDefault(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test").(*missyWriter)
+
+	if w.config.CompressionCodec != nil {
+		t.Errorf("messages should not be compressed by default, got %s", w.config.CompressionCodec.Name())
+	}
+}
+
+func TestNewWriter_WithCompression(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test", WithCompression(kafka.Zstd)).(*missyWriter)
+
+	if codec := w.config.CompressionCodec; codec == nil || codec.Code() != int8(kafka.Zstd) {
+		t.Fatal("the writer config should carry the zstd codec")
+	}
+	if compression := w.brokerWriter.(*writeBroker).Compression; compression != kafka.Zstd {
+		t.Errorf("the kafka writer should compress messages with zstd, got %v", compression)
+	}
+	if w.compression != kafka.Zstd {
+		t.Errorf("ProduceSync should compress messages with zstd, got %v", w.compression)
+	}
+}
+
+func TestNewWriter_WithCompressionRoundTrip(t *testing.T) {
+	im := NewInMemory()
+	w := im.NewWriter("orders", WithCompression(kafka.Zstd))
+	r := im.NewReader("group", "orders")
+	defer r.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Message, 1)
+	go r.ReadContext(ctx, func(m Message) error {
+		received <- m
+		return nil
+	})
+
+	payload := bytes.Repeat([]byte(`{"key":"value"}`), 100)
+	if err := w.Write([]byte("key"), payload); err != nil {
+		t.Fatalf("unexpected error writing: %v", err)
+	}
+
+	select {
+	case m := <-received:
+		if string(m.Key) != "key" || !bytes.Equal(m.Value, payload) {
+			t.Errorf("the compressed message should be read unchanged, got %s = %d bytes", m.Key, len(m.Value))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message was not delivered")
+	}
+}
+
+func TestNewWriter_DefaultBalancerHashesKeys(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test").(*missyWriter)
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	for _, key := range []string{"order-1", "order-2", "order-3"} {
+		first := w.config.Balancer.Balance(kafka.Message{Key: []byte(key)}, partitions...)
+		for i := 0; i < 10; i++ {
+			// interleave messages without key, which must not change the partition of a key
+			w.config.Balancer.Balance(kafka.Message{Value: []byte("value")}, partitions...)
+			if p := w.config.Balancer.Balance(kafka.Message{Key: []byte(key)}, partitions...); p != first {
+				t.Errorf("messages with key %s went to partitions %d and %d", key, first, p)
+			}
+		}
+	}
+}
+
+func TestNewWriter_WithBalancer(t *testing.T) {
+	balancer := &kafka.RoundRobin{}
+	w := NewWriter([]string{"localhost:9091"}, "test", WithBalancer(balancer)).(*missyWriter)
+
+	if w.config.Balancer != balancer {
+		t.Error("the given balancer should be used")
+	}
+}
+
+func TestNewWriter_WithPartition(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test", WithPartition(2)).(*missyWriter)
+
+	for _, key := range []string{"", "order-1", "order-2"} {
+		if p := w.config.Balancer.Balance(kafka.Message{Key: []byte(key)}, 0, 1, 2, 3); p != 2 {
+			t.Errorf("expected message with key %q to go to partition 2, got %d", key, p)
+		}
+	}
+}
+
+func TestNewWriter_WithAsync(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test", WithAsync(nil)).(*missyWriter)
+
+	if !w.config.Async || w.pending == nil {
+		t.Error("the writer should be async")
+	}
+	if w.brokerWriter.(*writeBroker).Completion == nil {
+		t.Error("the outcome of async writes should be reported to the writer")
+	}
+}
+
+func TestNewWriter_WithWriteRetriesNegative(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("a negative number of write retries should panic")
+		}
+	}()
+	NewWriter([]string{"localhost:9091"}, "test", WithWriteRetries(-1, time.Second))
+}
+
+func TestNewWriter_WithIdempotentProducer(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test", WithIdempotentProducer()).(*missyWriter)
+
+	if !w.idempotent {
+		t.Error("messages should get an id")
+	}
+	if acks := w.brokerWriter.(*writeBroker).RequiredAcks; acks != kafka.RequireAll {
+		t.Errorf("expected writes to be acknowledged by all replicas, got %d", acks)
+	}
+}
+
+func TestNewWriter_WithRequiredAcks(t *testing.T) {
+	w := NewWriter([]string{"localhost:9091"}, "test").(*missyWriter)
+	if acks := w.brokerWriter.(*writeBroker).RequiredAcks; acks != kafka.RequireAll {
+		t.Errorf("expected writes to be acknowledged by all replicas by default, got %d", acks)
+	}
+
+	for _, acks := range []kafka.RequiredAcks{kafka.RequireNone, kafka.RequireOne, kafka.RequireAll} {
+		w := NewWriter([]string{"localhost:9091"}, "test", WithRequiredAcks(acks)).(*missyWriter)
+		if got := w.brokerWriter.(*writeBroker).RequiredAcks; got != acks {
+			t.Errorf("expected %d required acks, got %d", acks, got)
+		}
+	}
+}
+
+func TestNewWriter_WithRequiredAcksInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("an invalid number of required acks should panic")
+		}
+	}()
+	NewWriter([]string{"localhost:9091"}, "test", WithRequiredAcks(-2))
+}
@@ -0,0 +1,87 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// retryableWriteError reports whether a write which failed with err may succeed when it is tried again, e.g. after a
+// leader election. Errors like a too large message or a cancelled context are not retried.
+func retryableWriteError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var writeErrors kafka.WriteErrors
+	if errors.As(err, &writeErrors) {
+		for _, e := range writeErrors {
+			if e != nil && !retryableWriteError(e) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+// writeWithRetries writes the messages and tries again up to writeRetries times with a doubling backoff as long as the
+// write fails with a retryable error. Only the failed messages of a partially failed batch are written again.
+func (mw *missyWriter) writeWithRetries(ctx context.Context, msgs ...Message) error {
+	err := mw.brokerWriter.WriteMessages(ctx, msgs...)
+
+	backoff := mw.writeRetryBackoff
+	for attempt := 1; attempt <= mw.writeRetries && retryableWriteError(err); attempt++ {
+		mw.getLogger().Warnf("# messaging # write to topic %s failed, retrying in %s (%d/%d): %v", mw.topic, backoff, attempt, mw.writeRetries, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+
+		var writeErrors kafka.WriteErrors
+		if errors.As(err, &writeErrors) && len(writeErrors) == len(msgs) {
+			err = mw.writeFailed(ctx, msgs, writeErrors)
+			continue
+		}
+		err = mw.brokerWriter.WriteMessages(ctx, msgs...)
+	}
+	return err
+}
+
+// writeFailed writes the messages of a batch which failed again and returns the errors of the whole batch afterwards
+func (mw *missyWriter) writeFailed(ctx context.Context, msgs []Message, writeErrors kafka.WriteErrors) error {
+	var failed []int
+	var retry []Message
+	for i, e := range writeErrors {
+		if e != nil {
+			failed = append(failed, i)
+			retry = append(retry, msgs[i])
+		}
+	}
+
+	errs := make(kafka.WriteErrors, len(writeErrors))
+	copy(errs, writeErrors)
+
+	err := mw.brokerWriter.WriteMessages(ctx, retry...)
+	var retryErrors kafka.WriteErrors
+	isWriteErrors := errors.As(err, &retryErrors) && len(retryErrors) == len(retry)
+	for j, i := range failed {
+		if isWriteErrors {
+			errs[i] = retryErrors[j]
+		} else {
+			errs[i] = err
+		}
+	}
+
+	if errs.Count() == 0 {
+		return nil
+	}
+	return errs
+}
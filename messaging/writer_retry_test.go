@@ -0,0 +1,198 @@
+package messaging
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestMissyWriter_WriteRetries(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	msg := Message{Key: []byte("key"), Value: []byte("value")}
+	gomock.InOrder(
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), msg).Return(kafka.LeaderNotAvailable),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), msg).Return(kafka.NotLeaderForPartition),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), msg).Return(nil),
+	)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	WithWriteRetries(3, time.Millisecond)(&writer)
+
+	if err := writer.Write(msg.Key, msg.Value); err != nil {
+		t.Errorf("expected the write to succeed after two retries, got %v", err)
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyWriter_WriteRetriesExhausted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(3).Return(kafka.LeaderNotAvailable)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	WithWriteRetries(2, time.Millisecond)(&writer)
+
+	if err := writer.Write([]byte("key"), []byte("value")); !errors.Is(err, kafka.LeaderNotAvailable) {
+		t.Errorf("expected %v once the retries are used up, got %v", kafka.LeaderNotAvailable, err)
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyWriter_WriteRetriesNotRetryable(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(kafka.MessageSizeTooLarge)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(context.Canceled)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	WithWriteRetries(3, time.Millisecond)(&writer)
+
+	if err := writer.Write([]byte("key"), []byte("value")); err != kafka.MessageSizeTooLarge {
+		t.Errorf("expected %v without retrying, got %v", kafka.MessageSizeTooLarge, err)
+	}
+	if err := writer.Write([]byte("key"), []byte("value")); err != context.Canceled {
+		t.Errorf("expected %v without retrying, got %v", context.Canceled, err)
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyWriter_WriteRetriesFailedPartOfBatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	msgs := []Message{{Key: []byte("a")}, {Key: []byte("b")}, {Key: []byte("c")}}
+	gomock.InOrder(
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), msgs[0], msgs[1], msgs[2]).Return(kafka.WriteErrors{nil, kafka.LeaderNotAvailable, kafka.RequestTimedOut}),
+		// only the failed messages are written again
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), msgs[1], msgs[2]).Return(kafka.WriteErrors{nil, kafka.MessageSizeTooLarge}),
+	)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	WithWriteRetries(3, time.Millisecond)(&writer)
+
+	err := writer.WriteBatch(msgs)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a *BatchError, got %v", err)
+	}
+	if failed := batchErr.Failed(); len(failed) != 1 || failed[0] != 2 {
+		t.Errorf("expected only message 2 to fail, got %v", failed)
+	}
+	if batchErr.Errors[2] != kafka.MessageSizeTooLarge {
+		t.Error(expected(batchErr.Errors[2].Error(), kafka.MessageSizeTooLarge.Error()))
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyWriter_WriteRetriesContextDone(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(kafka.LeaderNotAvailable)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	WithWriteRetries(3, time.Hour)(&writer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := writer.WriteContext(ctx, []byte("key"), []byte("value")); err != kafka.LeaderNotAvailable {
+		t.Errorf("expected the last error once the context is done, got %v", err)
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyWriter_IdempotentRetryIsDeduplicated(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+
+	// the first write reaches the broker, but the acknowledgement times out, so the message is written twice
+	var landed []Message
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		landed = append(landed, msgs...)
+		if len(landed) == 1 {
+			return kafka.RequestTimedOut
+		}
+		return nil
+	})
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	WithWriteRetries(1, time.Millisecond)(&writer)
+	WithIdempotentProducer()(&writer)
+
+	if err := writer.Write([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	mockCtrl.Finish()
+
+	if len(landed) != 2 {
+		t.Fatalf("expected the message to be written twice, got %d", len(landed))
+	}
+	dedup := newDedupCache(time.Minute, "")
+	if dedup.contains(landed[0]) {
+		t.Error("the first message should not be a duplicate")
+	}
+	dedup.add(landed[0])
+	if !dedup.contains(landed[1]) {
+		t.Errorf("the retried message should be skipped by readers, got ids %v and %v", landed[0].Headers, landed[1].Headers)
+	}
+}
+
+func TestMissyWriter_IdempotentProducerRetryIsDeduplicated(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	var attempts []Message
+	record := func(ctx context.Context, msgs ...Message) {
+		attempts = append(attempts, msgs...)
+	}
+	gomock.InOrder(
+		// the first write reaches the broker, but the acknowledgement is lost
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Do(record).Return(kafka.LeaderNotAvailable),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Do(record).Return(nil),
+	)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, topic: "orders"}
+	WithIdempotentProducer()(&writer)
+	WithWriteRetries(1, time.Millisecond)(&writer)
+
+	if err := writer.Write([]byte("key"), []byte("value")); err != nil {
+		t.Fatalf("expected the write to succeed after a retry, got %v", err)
+	}
+	mockCtrl.Finish()
+
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	first, _ := attempts[0].GetHeader(MessageIDHeader)
+	second, _ := attempts[1].GetHeader(MessageIDHeader)
+	if len(first) == 0 || string(first) != string(second) {
+		t.Fatalf("expected both attempts to carry the same id, got %q and %q", first, second)
+	}
+
+	// both copies end up in the topic, a reader using WithDedup processes the message once
+	im := NewInMemory()
+	w := im.NewWriter("orders")
+	for _, m := range attempts {
+		if err := w.WriteMessage(m); err != nil {
+			t.Fatalf("unexpected error writing: %v", err)
+		}
+	}
+	r := im.NewReader("group", "orders", WithDedup(time.Minute, ""))
+	defer r.Close()
+
+	var processed int32
+	if err := r.Read(func(m Message) error {
+		atomic.AddInt32(&processed, 1)
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, func() bool { return im.lag("group", "orders") == 0 })
+	if n := atomic.LoadInt32(&processed); n != 1 {
+		t.Errorf("expected the message to be processed once, got %d", n)
+	}
+}
@@ -3,8 +3,11 @@ package messaging
 import (
 	"context"
 	"fmt"
+	"io"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bouk/monkey"
 	"github.com/golang/mock/gomock"
@@ -44,6 +47,23 @@ func TestMissyWriter_WriteSuccess(t *testing.T) {
 
 }
 
+func TestMissyWriter_WriteString(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	// the same message as written by Write
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Key: []byte("key"), Value: []byte("value")}).Return(nil).Times(2)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+
+	if err := writer.WriteString("key", []byte("value")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := writer.Write([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
 func TestMissyWriter_WriteError(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
@@ -65,6 +85,72 @@ func TestMissyWriter_WriteError(t *testing.T) {
 
 }
 
+func TestMissyWriter_WriteBatch(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	msgs := []Message{
+		{Topic: "ignored", Key: []byte("a"), Value: []byte("1"), Headers: []Header{{Key: "trace-id", Value: []byte("abc")}}},
+		{Key: []byte("b"), Value: []byte("2")},
+	}
+
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Key: []byte("a"), Value: []byte("1"), Headers: msgs[0].Headers}, msgs[1]).Return(nil)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	if err := writer.WriteBatch(msgs); err != nil {
+		t.Errorf("there was an unexpected error during WriteBatch: %v", err)
+	}
+}
+
+func TestMissyWriter_WriteBatchPartialFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	msgs := []Message{{Key: []byte("a")}, {Key: []byte("b")}, {Key: []byte("c")}}
+
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(kafka.WriteErrors{nil, errors.New("error"), nil})
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+	err := writer.WriteBatch(msgs)
+
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("expected a *BatchError, got %v", err)
+	}
+	if failed := batchErr.Failed(); len(failed) != 1 || failed[0] != 1 {
+		t.Errorf("expected message 1 to fail, got %v", failed)
+	}
+}
+
+func TestMissyWriter_WriteContextCancel(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	// an unavailable broker blocks until the context is done
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	if err := writer.WriteContext(ctx, []byte("key"), []byte("value")); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("WriteContext should return promptly after the context has been cancelled")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := writer.WriteBatchContext(ctx, []Message{{Key: []byte("key")}}); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
 func TestMissyWriter_Close(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
@@ -80,6 +166,193 @@ func TestMissyWriter_Close(t *testing.T) {
 
 }
 
+func TestMissyWriter_Async(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	writeErr := errors.New("leader not available")
+
+	var mu sync.Mutex
+	delivered := make(map[string]error)
+	writer := missyWriter{pending: &pendingWrites{}, delivery: func(m Message, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered[string(m.Key)] = err
+	}}
+
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	// the kafka writer returns right away and reports the outcome once the batch has been written
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(2).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		var err error
+		if string(msgs[0].Key) == "fail" {
+			err = writeErr
+		}
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			writer.completed([]kafka.Message{toKafkaMessage(msgs[0])}, err)
+		}()
+		return nil
+	})
+	brokerWriterMock.EXPECT().Close().Return(nil)
+	writer.brokerWriter = brokerWriterMock
+
+	if err := writer.Write([]byte("ok"), []byte("value")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := writer.Write([]byte("fail"), []byte("value")); err != nil {
+		t.Errorf("errors of async writes should be reported to the callback, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := writer.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected Flush to give up once the context is done, got %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err, ok := delivered["ok"]; !ok || err != nil {
+		t.Errorf("expected the first message to be delivered before Close returns, got %v", err)
+	}
+	if err := delivered["fail"]; err != writeErr {
+		t.Errorf("expected the second message to fail with %v, got %v", writeErr, err)
+	}
+}
+
+func TestMissyWriter_AsyncWriteNotAccepted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(io.ErrClosedPipe)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, pending: &pendingWrites{}}
+
+	if err := writer.Write([]byte("key"), []byte("value")); err != io.ErrClosedPipe {
+		t.Errorf("expected messages which are not accepted to fail right away, got %v", err)
+	}
+	if err := writer.Flush(context.Background()); err != nil {
+		t.Errorf("messages which are not accepted should not be pending, got %v", err)
+	}
+}
+
+func TestMissyWriter_CloseWritesPendingMessages(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+
+	var mu sync.Mutex
+	var landed []string
+	writer := missyWriter{pending: &pendingWrites{}}
+
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Times(5).DoAndReturn(func(ctx context.Context, msgs ...Message) error {
+		go func() {
+			time.Sleep(time.Duration(len(msgs[0].Value)) * 10 * time.Millisecond)
+			mu.Lock()
+			landed = append(landed, string(msgs[0].Key))
+			mu.Unlock()
+			writer.completed([]kafka.Message{toKafkaMessage(msgs[0])}, nil)
+		}()
+		return nil
+	})
+	brokerWriterMock.EXPECT().Close().Return(nil)
+	writer.brokerWriter = brokerWriterMock
+
+	for i := 0; i < 5; i++ {
+		if err := writer.Write([]byte(fmt.Sprintf("key-%d", i)), make([]byte, 5-i)); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(landed) != 5 {
+		t.Errorf("expected all 5 messages to be written before Close returns, got %v", landed)
+	}
+}
+
+func TestMissyWriter_CloseTimeout(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	// the broker never acknowledges the message
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), gomock.Any()).Return(nil)
+	brokerWriterMock.EXPECT().Close().Return(nil)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, pending: &pendingWrites{}, topic: "test"}
+	WithWriterCloseTimeout(10 * time.Millisecond)(&writer)
+
+	if err := writer.Write([]byte("key"), []byte("value")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	start := time.Now()
+	err := writer.Close()
+	if err == nil {
+		t.Error("expected an error for the message which has not been written")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Close should give up waiting after the close timeout")
+	}
+}
+
+func TestMissyWriter_WriteTo(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	gomock.InOrder(
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Topic: "orders", Key: []byte("key"), Value: []byte("1")}).Return(nil),
+		brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Topic: "invoices", Key: []byte("key"), Value: []byte("2")}).Return(nil),
+	)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+
+	if err := writer.WriteTo("orders", []byte("key"), []byte("1")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := writer.WriteTo("invoices", []byte("key"), []byte("2")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	mockCtrl.Finish()
+}
+
+func TestMissyWriter_WriteToOwnTopic(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	// the topic is set on the kafka writer, it must not be set on the message as well
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Key: []byte("key"), Value: []byte("value")}).Return(nil)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, topic: "orders"}
+
+	if err := writer.WriteTo("orders", []byte("key"), []byte("value")); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := writer.WriteTo("invoices", []byte("key"), []byte("value")); err == nil {
+		t.Error("a writer bound to a topic should not write to other topics")
+	}
+	mockCtrl.Finish()
+}
+
+func TestNewMultiTopicWriter(t *testing.T) {
+	w := NewMultiTopicWriter([]string{"localhost:9091"}).(*missyWriter)
+
+	if topic := w.brokerWriter.(*writeBroker).Topic; topic != "" {
+		t.Errorf("the kafka writer should not be bound to a topic, got %s", topic)
+	}
+}
+
+func TestMissyWriter_Stats(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().Stats().Return(kafka.WriterStats{Messages: 3, Errors: 1})
+
+	writer := missyWriter{brokerWriter: brokerWriterMock}
+
+	if stats := writer.Stats(); stats.Messages != 3 || stats.Errors != 1 {
+		t.Errorf("expected the stats of the kafka writer, got %+v", stats)
+	}
+	mockCtrl.Finish()
+}
+
 func TestWriteBroker_WriteMessages(t *testing.T) {
 	kw := kafka.NewWriter(kafka.WriterConfig{
 		Brokers: []string{"localhost:9999"},
@@ -94,6 +367,9 @@ func TestWriteBroker_WriteMessages(t *testing.T) {
 		if len(msgs) != len(messages) {
 			t.Error(fmt.Sprintf("invalid messages length: expected: %v, got %v", len(msgs), len(messages)))
 		}
+		if messages[0].Topic != "topic" {
+			t.Error(expected(messages[0].Topic, "topic"))
+		}
 
 		exec = true
 		return nil
@@ -164,3 +440,26 @@ func TestWriteBroker_Close(t *testing.T) {
 	}
 
 }
+
+func TestMissyWriter_MaxMessageBytes(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	brokerWriterMock := NewMockBrokerWriter(mockCtrl)
+	brokerWriterMock.EXPECT().WriteMessages(gomock.Any(), Message{Key: []byte("key"), Value: make([]byte, 7)}).Return(nil)
+
+	writer := missyWriter{brokerWriter: brokerWriterMock, topic: "test"}
+	WithMaxMessageBytes(10)(&writer)
+
+	if err := writer.Write([]byte("key"), make([]byte, 7)); err != nil {
+		t.Errorf("a message of exactly the maximum size should be written, got %v", err)
+	}
+
+	err := writer.WriteBatch([]Message{{Value: []byte("small")}, {Key: []byte("key"), Value: make([]byte, 100)}})
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+	var tooLarge *MessageTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Size != 103 || tooLarge.MaxBytes != 10 {
+		t.Errorf("expected the actual and the maximum size, got %v", err)
+	}
+}
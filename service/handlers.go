@@ -3,6 +3,7 @@ package service
 import (
 	"crypto/rsa"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -101,3 +102,22 @@ func (s *Service) infoHandler(w http.ResponseWriter, r *http.Request) {
 func (s *Service) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
+
+// logLevelHandler returns the log level on GET and sets it to the level in the request body on PUT, PUT is only
+// registered behind the AuthHandler
+func (s *Service) logLevelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64))
+		if err != nil {
+			http.Error(w, "cannot read the log level", http.StatusBadRequest)
+			return
+		}
+		level := strings.TrimSpace(string(body))
+		if err := log.SetLevel(level); err != nil {
+			http.Error(w, fmt.Sprintf("unknown log level %q, allowed levels: debug, info, warn, error, fatal, panic", level), http.StatusBadRequest)
+			return
+		}
+		log.Warnf("Log level set to %s", log.GetLevel())
+	}
+	w.Write([]byte(log.GetLevel()))
+}
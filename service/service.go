@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"sync"
 	"syscall"
 	"time"
 
@@ -65,6 +67,11 @@ type Service struct {
 	Router     *mux.Router
 	Stop       chan os.Signal
 	ServeMux   *http.ServeMux
+	readers    []Shutdowner
+	writers    []io.Closer
+	// ShutdownGracePeriod is the time the registered readers get to finish and commit the messages being processed
+	// when the service is stopped, config.DefaultShutdownGracePeriod unless SHUTDOWN_GRACE_PERIOD is set
+	ShutdownGracePeriod time.Duration
 }
 
 var listenPort = "8080"
@@ -114,15 +121,25 @@ func New() *Service {
 		listenPort = os.Getenv("LISTEN_PORT")
 	}
 
+	gracePeriod := config.DefaultShutdownGracePeriod
+	if value, present := os.LookupEnv("SHUTDOWN_GRACE_PERIOD"); present {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid SHUTDOWN_GRACE_PERIOD %q, expected a positive duration like 25s", value)
+		}
+		gracePeriod = d
+	}
+
 	c := config.GetInstance()
 
 	s := &Service{
-		name:       c.Name,
-		Host:       listenHost,
-		Port:       listenPort,
-		Prometheus: NewPrometheus(c.Name),
-		Router:     mux.NewRouter(),
-		ServeMux:   http.NewServeMux(),
+		name:                c.Name,
+		Host:                listenHost,
+		Port:                listenPort,
+		Prometheus:          NewPrometheus(c.Name),
+		Router:              mux.NewRouter(),
+		ServeMux:            http.NewServeMux(),
+		ShutdownGracePeriod: gracePeriod,
 	}
 	s.prepareBeforeStart()
 	return s
@@ -151,7 +168,52 @@ func (s *Service) prepareShutdown(h Server) {
 	s.Stop = make(chan os.Signal, 1)
 	signal.Notify(s.Stop, os.Interrupt, syscall.SIGTERM)
 	<-s.Stop
+	s.teardown(h)
+}
+
+// RegisterReader shuts r, e.g. a messaging.Reader, down when the service is stopped. The service does not start
+// reading, call Read before Start.
+func (s *Service) RegisterReader(r Shutdowner) {
+	s.readers = append(s.readers, r)
+}
+
+// RegisterWriter closes w, e.g. a messaging.Writer, when the service is stopped
+func (s *Service) RegisterWriter(w io.Closer) {
+	s.writers = append(s.writers, w)
+}
+
+// teardown stops the HTTP server first, so no new requests produce messages, then the readers, which wait for the
+// messages being processed, and the writers last, so messages written by requests and readers are flushed
+func (s *Service) teardown(h Shutdowner) {
 	shutdown(h)
+
+	if len(s.readers) > 0 {
+		gracePeriod := s.ShutdownGracePeriod
+		if gracePeriod <= 0 {
+			gracePeriod = config.DefaultShutdownGracePeriod
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+		defer cancel()
+
+		log.Printf("Shutting down %d messaging readers with timeout: %s", len(s.readers), gracePeriod)
+		var wg sync.WaitGroup
+		for _, r := range s.readers {
+			wg.Add(1)
+			go func(r Shutdowner) {
+				defer wg.Done()
+				if err := r.Shutdown(ctx); err != nil {
+					log.Printf("Error: %v", err)
+				}
+			}(r)
+		}
+		wg.Wait()
+	}
+
+	for _, w := range s.writers {
+		if err := w.Close(); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
 }
 
 // Shutdown allows to stop the HTTP Server gracefully
@@ -224,6 +286,11 @@ func (s *Service) prepareBeforeStart() {
 	s.Router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 	s.Router.HandleFunc("/health", s.healthHandler).Methods("GET")
 	s.Router.HandleFunc("/info", s.infoHandler).Methods("GET")
+	s.Router.HandleFunc("/loglevel", s.logLevelHandler).Methods("GET")
+	// changing the log level requires a token, without a public key to check it the level is read-only
+	if config.GetInstance().Authorization.PublicKeyFile != "" {
+		s.SecureHandleFunc("/loglevel", s.logLevelHandler).Methods("PUT")
+	}
 
 	s.ServeMux.Handle("/", s.Router)
 
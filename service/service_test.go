@@ -2,6 +2,8 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -9,9 +11,12 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/microdevs/missy/config"
+	"github.com/microdevs/missy/log"
 )
 
 func runTestWithConfigFile(t *testing.T, f func(*testing.T)) {
@@ -145,3 +150,144 @@ func TestFailingHandler(t *testing.T) {
 		http.DefaultServeMux = nil
 	})
 }
+
+// lifecycleRecorder records the order in which the parts of a service are stopped
+type lifecycleRecorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (lr *lifecycleRecorder) record(name string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	lr.order = append(lr.order, name)
+}
+
+type recordingShutdowner struct {
+	name string
+	lr   *lifecycleRecorder
+}
+
+func (rs recordingShutdowner) Shutdown(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("shutdown without deadline")
+	}
+	rs.lr.record(rs.name)
+	return nil
+}
+
+func (rs recordingShutdowner) Close() error {
+	rs.lr.record(rs.name)
+	return nil
+}
+
+func TestTeardownOrder(t *testing.T) {
+	runTestWithConfigFile(t, func(t *testing.T) {
+		lr := &lifecycleRecorder{}
+		s := New()
+		s.RegisterWriter(recordingShutdowner{name: "writer", lr: lr})
+		s.RegisterReader(recordingShutdowner{name: "reader", lr: lr})
+
+		s.teardown(recordingShutdowner{name: "http", lr: lr})
+
+		if !reflect.DeepEqual(lr.order, []string{"http", "reader", "writer"}) {
+			t.Errorf("expected the HTTP server, then readers, then writers to be stopped, got %v", lr.order)
+		}
+		http.DefaultServeMux = nil
+	})
+}
+
+// deadlineShutdowner records the deadline it has been shut down with
+type deadlineShutdowner struct {
+	deadline time.Time
+}
+
+func (ds *deadlineShutdowner) Shutdown(ctx context.Context) error {
+	ds.deadline, _ = ctx.Deadline()
+	return nil
+}
+
+func TestTeardownGracePeriod(t *testing.T) {
+	runTestWithConfigFile(t, func(t *testing.T) {
+		s := New()
+		if s.ShutdownGracePeriod != config.DefaultShutdownGracePeriod {
+			t.Errorf("expected the default grace period of %s, got %s", config.DefaultShutdownGracePeriod, s.ShutdownGracePeriod)
+		}
+
+		os.Setenv("SHUTDOWN_GRACE_PERIOD", "40s")
+		defer os.Unsetenv("SHUTDOWN_GRACE_PERIOD")
+		s = New()
+		if s.ShutdownGracePeriod != 40*time.Second {
+			t.Errorf("expected the grace period of SHUTDOWN_GRACE_PERIOD, got %s", s.ShutdownGracePeriod)
+		}
+
+		r := &deadlineShutdowner{}
+		s.RegisterReader(r)
+		start := time.Now()
+		s.teardown(nil)
+
+		if remaining := r.deadline.Sub(start); remaining < 39*time.Second || remaining > 40*time.Second {
+			t.Errorf("expected the readers to get the grace period of 40s, got %s", remaining)
+		}
+		http.DefaultServeMux = nil
+	})
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	runTestWithConfigFile(t, func(t *testing.T) {
+		defer log.SetLevel(log.GetLevel())
+		s := New()
+		token := generateSignedTokenString(t)
+		put := func(body string) *http.Request {
+			r := httptest.NewRequest("PUT", "http://missy/loglevel", strings.NewReader(body))
+			r.Header.Set("Authorization", "Bearer "+token)
+			return r
+		}
+
+		w := httptest.NewRecorder()
+		s.Router.ServeHTTP(w, put("error\n"))
+		if w.Code != http.StatusOK || w.Body.String() != "error" {
+			t.Errorf("expected the level to be set to error, got %d %s", w.Code, w.Body.String())
+		}
+		if level := log.GetLevel(); level != "error" {
+			t.Errorf("expected log level error, got %s", level)
+		}
+
+		w = httptest.NewRecorder()
+		s.Router.ServeHTTP(w, httptest.NewRequest("GET", "http://missy/loglevel", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "error" {
+			t.Errorf("expected the current level, got %d %s", w.Code, w.Body.String())
+		}
+
+		w = httptest.NewRecorder()
+		s.Router.ServeHTTP(w, put("verbose"))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an unknown level, got %d", w.Code)
+		}
+		http.DefaultServeMux = nil
+	})
+}
+
+func TestLogLevelEndpoint_Unauthenticated(t *testing.T) {
+	runTestWithConfigFile(t, func(t *testing.T) {
+		defer log.SetLevel(log.GetLevel())
+		log.SetLevel("info")
+		s := New()
+
+		w := httptest.NewRecorder()
+		s.Router.ServeHTTP(w, httptest.NewRequest("PUT", "http://missy/loglevel", strings.NewReader("debug")))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 without a token, got %d", w.Code)
+		}
+		if level := log.GetLevel(); level != "info" {
+			t.Errorf("expected the log level to be unchanged, got %s", level)
+		}
+
+		w = httptest.NewRecorder()
+		s.Router.ServeHTTP(w, httptest.NewRequest("GET", "http://missy/loglevel", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "info" {
+			t.Errorf("expected the current level without a token, got %d %s", w.Code, w.Body.String())
+		}
+		http.DefaultServeMux = nil
+	})
+}